@@ -2,15 +2,21 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/Dorrrke/g3-bookly/internal/blob"
 	"github.com/Dorrrke/g3-bookly/internal/config"
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
 	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/metadata"
+	"github.com/Dorrrke/g3-bookly/internal/notify"
 	"github.com/Dorrrke/g3-bookly/internal/server"
 	"github.com/Dorrrke/g3-bookly/internal/storage"
+	"github.com/Dorrrke/g3-bookly/internal/tracing"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"golang.org/x/sync/errgroup"
@@ -21,7 +27,25 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	log := logger.Get(cfg.Debug)
+	if cfg.PrintConfig {
+		if err = config.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	logFile, err := logger.Setup(logger.Options{
+		Level:      cfg.Log.Level,
+		Format:     cfg.Log.Format,
+		File:       cfg.Log.File,
+		MaxSizeMB:  cfg.Log.MaxSizeMB,
+		MaxBackups: cfg.Log.MaxBackups,
+		MaxAgeDays: cfg.Log.MaxAgeDays,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logFile.Close()
+	log := logger.Get()
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		c := make(chan os.Signal, 1)
@@ -33,25 +57,50 @@ func main() {
 	}()
 
 	log.Debug().Any("cfg", cfg).Send()
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter: cfg.Trace.Exporter,
+		Endpoint: cfg.Trace.Endpoint,
+		File:     cfg.Trace.File,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("tracing init failed")
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.Error().Err(err).Msg("flush traces failed")
+		}
+	}()
 	var stor server.Storage
 
 	if err = storage.Migrations(cfg.DBDsn, cfg.MigratePath); err != nil {
 		log.Fatal().Err(err).Msg("migrations failed")
 	}
-	stor, err = storage.NewDB(context.TODO(), cfg.DBDsn)
+	stor, err = storage.NewDB(context.TODO(), cfg.DBDsn, cfg.DBTimeout)
 	if err != nil {
 		log.Error().Err(err).Msg("connecting to data base failed")
 		stor = storage.New()
 	}
-	serv := server.New(*cfg, stor)
+	blobs, err := newBlobStore(cfg.Blob)
+	if err != nil {
+		log.Fatal().Err(err).Msg("blob storage init failed")
+	}
+	meta, err := newMetadataProvider(cfg.Metadata)
+	if err != nil {
+		log.Fatal().Err(err).Msg("metadata provider init failed")
+	}
+	notifier := notify.New(stor, newMailer(cfg.Notify), notify.Config{
+		Interval:     cfg.Notify.Interval,
+		RemindBefore: cfg.Notify.RemindBefore,
+		OverdueAfter: cfg.Notify.OverdueAfter,
+	})
+	serv := server.New(*cfg, stor, blobs, meta)
+	go reloadOnHangup(ctx, serv)
 	group, gCtx := errgroup.WithContext(ctx)
 	group.Go(func() error {
 		return serv.Run(gCtx)
 	})
 	group.Go(func() error {
-		log.Debug().Msg("error chan listener started")
-		defer log.Debug().Msg("error chan listener - end")
-		return <-serv.ErrChan
+		return notifier.Run(gCtx)
 	})
 	group.Go(func() error {
 		<-gCtx.Done()
@@ -64,3 +113,71 @@ func main() {
 	}
 	log.Info().Msg("server stoped")
 }
+
+// reloadOnHangup reads the configuration again on SIGHUP and applies the settings which may
+// change while running: the log level and what server.Reload takes. An invalid configuration
+// is logged and the running one is kept.
+func reloadOnHangup(ctx context.Context, serv *server.Server) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		}
+		log := logger.Get()
+		cfg, err := config.ReadConfig()
+		if err != nil {
+			log.Error().Err(err).Msg("reload config failed, keeping the running one")
+			continue
+		}
+		if err = logger.SetLevel(cfg.Log.Level); err != nil {
+			log.Error().Err(err).Msg("reload log level failed")
+		}
+		serv.Reload(*cfg)
+		log.Info().Str("log_level", cfg.Log.Level).Dur("loan_period", cfg.Loan.Period).Msg("config reloaded")
+	}
+}
+
+func newBlobStore(cfg config.BlobConfig) (blob.BlobStore, error) {
+	if cfg.Backend == "s3" {
+		return blob.NewS3(blob.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Bucket:    cfg.S3Bucket,
+			Region:    cfg.S3Region,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		})
+	}
+	return blob.NewFS(cfg.Dir)
+}
+
+// newMailer returns nil when no smtp server is set, notices by email are skipped then.
+func newMailer(cfg config.NotifyConfig) notify.Mailer {
+	if cfg.SMTPAddr == "" {
+		return nil
+	}
+	return notify.NewSMTP(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUser, cfg.SMTPPass)
+}
+
+// newMetadataProvider returns nil when isbn lookup is turned off.
+func newMetadataProvider(cfg config.MetadataConfig) (metadata.MetadataProvider, error) {
+	var provider metadata.MetadataProvider
+	switch cfg.Provider {
+	case "off", "":
+		return nil, nil //nolint:nilnil //lookup is disabled
+	case "fixture":
+		fixture, err := metadata.NewFixture(cfg.FixtureDir)
+		if err != nil {
+			return nil, err
+		}
+		provider = fixture
+	case "openlibrary":
+		provider = metadata.NewOpenLibrary(cfg.URL)
+	default:
+		return nil, fmt.Errorf("unknown metadata provider %q", cfg.Provider)
+	}
+	return metadata.NewCache(provider, consts.MetadataCacheTTL, consts.MetadataCacheSize), nil
+}
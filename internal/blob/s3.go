@@ -0,0 +1,279 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/tracing"
+)
+
+const (
+	amzDateFormat  = "20060102T150405Z"
+	amzShortFormat = "20060102"
+	// emptyPayloadHash is the SHA-256 of an empty body.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// unsignedPayload leaves the body out of the signature, so that it is streamed without hashing it first.
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// s3Timeout bounds waiting for the response headers, not reading a large body which the
+	// request context bounds.
+	s3Timeout  = 30 * time.Second
+	maxErrBody = 512
+)
+
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Store talks to an S3 compatible service (AWS S3, MinIO) using path-style URLs and SigV4 signatures.
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+	now      func() time.Time
+}
+
+func NewS3(cfg S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, errors.New("s3 bucket is not set")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert //it is a *http.Transport
+	transport.ResponseHeaderTimeout = s3Timeout
+	return &S3Store{
+		cfg:      cfg,
+		endpoint: endpoint,
+		client:   &http.Client{Transport: tracing.Transport(transport)},
+		now:      time.Now,
+	}, nil
+}
+
+// Put streams r to the object. S3 needs the length of the body up front: readers which do not
+// tell it, like an upload being received, are spooled to a temporary file first.
+func (s3 *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	body, size, cleanup, err := sized(r)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s3.objectURL(key), body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := s3.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (s3 *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, Info, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s3.objectURL(key), nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	resp, err := s3.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, Info{}, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, Info{}, responseError(resp)
+	}
+	info := Info{
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modTime
+	}
+	return resp.Body, info, nil
+}
+
+func (s3 *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s3.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s3.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusNotFound {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// sized returns a reader of the content of r and its length, and a func removing what it took.
+func sized(r io.Reader) (io.Reader, int64, func(), error) {
+	if lr, ok := r.(interface{ Len() int }); ok {
+		return r, int64(lr.Len()), func() {}, nil
+	}
+	file, err := os.CreateTemp("", "bookly-s3-*")
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	size, err := io.Copy(file, r)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, err
+	}
+	return file, size, cleanup, nil
+}
+
+func (s3 *S3Store) objectURL(key string) string {
+	u := *s3.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s3.cfg.Bucket + "/" + strings.TrimPrefix(key, "/")
+	return u.String()
+}
+
+func (s3 *S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	sign(req, s3.cfg, payloadHash, s3.now().UTC())
+	return s3.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers. Host, Content-Type, Range and all x-amz-* headers are signed.
+func sign(req *http.Request, cfg S3Config, payloadHash string, now time.Time) {
+	amzDate := now.Format(amzDateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.EscapedPath()),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := now.Format(amzShortFormat) + "/" + cfg.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretKey), now.Format(amzShortFormat))
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+cfg.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode re-encodes an escaped path by the stricter SigV4 rules, keeping slashes.
+func uriEncode(escaped string) string {
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		path = escaped
+	}
+	if path == "" {
+		return "/"
+	}
+	var b strings.Builder
+	for i := range len(path) {
+		c := path[i]
+		if c == '/' || ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func canonicalQuery(values url.Values) string {
+	if len(values) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := values[k]
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+	return strings.ReplaceAll(strings.Join(parts, "&"), "+", "%20")
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrBody))
+	return fmt.Errorf("s3 %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status,
+		strings.TrimSpace(string(body)))
+}
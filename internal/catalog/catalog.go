@@ -0,0 +1,84 @@
+// Package catalog reads and writes bulk catalog files.
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+	FormatONIX   = "onix"
+)
+
+var ErrUnknownFormat = errors.New("unknown catalog format")
+
+// RowError reports a record that could not be read. Decoding may continue after it.
+type RowError struct {
+	Row int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Decoder reads books one record at a time. Next returns io.EOF after the last record,
+// a *RowError for a malformed record and any other error when the input can not be read further.
+type Decoder interface {
+	Next() (models.Book, error)
+	// Row is the number of the record returned by the last Next call.
+	Row() int
+}
+
+func NewDecoder(format string, r io.Reader) (Decoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVDecoder(r)
+	case FormatNDJSON:
+		return newNDJSONDecoder(r), nil
+	case FormatMARC21:
+		return newMARCDecoder(r), nil
+	case FormatONIX:
+		return newONIXDecoder(r), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// IsImportFormat reports whether NewDecoder can read the format.
+func IsImportFormat(format string) bool {
+	switch format {
+	case FormatCSV, FormatNDJSON, FormatMARC21, FormatONIX:
+		return true
+	default:
+		return false
+	}
+}
+
+// FormatByType maps a request content type to a catalog format.
+func FormatByType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/json-seq":
+		return FormatNDJSON
+	case "application/marc":
+		return FormatMARC21
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,69 @@
+package catalog
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, dec Decoder) ([]models.Book, []int) {
+	t.Helper()
+	var books []models.Book
+	var badRows []int
+	for {
+		book, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return books, badRows
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			badRows = append(badRows, rowErr.Row)
+			continue
+		}
+		require.NoError(t, err)
+		books = append(books, book)
+	}
+}
+
+func TestCSVDecoder(t *testing.T) {
+	input := "Author,Lable,Desc,Age,Publisher\n" +
+		"Leo Tolstoy,War and Peace,Epic novel about 1812,12,Penguin\n" +
+		"Fyodor Dostoevsky,Idiot,Novel about a good man,not-a-number,\n" +
+		"\"Anton Chekhov\",\"Three Sisters\",\"A play, in four acts\",14\n"
+	dec, err := NewDecoder(FormatCSV, strings.NewReader(input))
+	require.NoError(t, err)
+	books, badRows := readAll(t, dec)
+	assert.Equal(t, []models.Book{
+		{Lable: "War and Peace", Author: "Leo Tolstoy", Desc: "Epic novel about 1812", Age: 12},
+		{Lable: "Three Sisters", Author: "Anton Chekhov", Desc: "A play, in four acts", Age: 14},
+	}, books)
+	assert.Equal(t, []int{2}, badRows)
+}
+
+func TestCSVDecoderMissingColumn(t *testing.T) {
+	_, err := NewDecoder(FormatCSV, strings.NewReader("lable,author\nA,B\n"))
+	assert.EqualError(t, err, `csv column "desc" is missing`)
+}
+
+func TestNDJSONDecoder(t *testing.T) {
+	input := `{"lable":"War and Peace","author":"Leo Tolstoy","desc":"Epic novel","age":12}` + "\n\n" +
+		`{"lable":"broken"` + "\n" +
+		`{"lable":"Idiot","author":"Fyodor Dostoevsky","desc":"Novel","age":16}`
+	dec, err := NewDecoder(FormatNDJSON, strings.NewReader(input))
+	require.NoError(t, err)
+	books, badRows := readAll(t, dec)
+	assert.Len(t, books, 2)
+	assert.Equal(t, "Idiot", books[1].Lable)
+	assert.Equal(t, []int{2}, badRows)
+}
+
+func TestFormatByType(t *testing.T) {
+	assert.Equal(t, FormatCSV, FormatByType("text/csv; charset=utf-8"))
+	assert.Equal(t, FormatNDJSON, FormatByType("application/x-ndjson"))
+	assert.Equal(t, "", FormatByType("application/json"))
+}
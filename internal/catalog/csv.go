@@ -0,0 +1,82 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// csvColumns are the columns a CSV catalog must have. The genre column is optional, others are ignored.
+var csvColumns = []string{"lable", "author", "desc", "age"} //nolint:gochecknoglobals //file layout
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+	row     int
+}
+
+// newCSVDecoder reads the header row. Column names are matched case-insensitively.
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv header is missing")
+		}
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range csvColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("csv column %q is missing", name)
+		}
+	}
+	return &csvDecoder{r: reader, columns: columns}, nil
+}
+
+func (d *csvDecoder) Next() (models.Book, error) {
+	record, err := d.r.Read()
+	if errors.Is(err, io.EOF) {
+		return models.Book{}, io.EOF
+	}
+	d.row++
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return models.Book{}, &RowError{Row: d.row, Err: parseErr.Err}
+		}
+		return models.Book{}, err
+	}
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	book := models.Book{
+		Lable:  field("lable"),
+		Author: field("author"),
+		Desc:   field("desc"),
+		Genre:  field("genre"),
+	}
+	if age := field("age"); age != "" {
+		if book.Age, err = strconv.Atoi(age); err != nil {
+			return models.Book{}, &RowError{Row: d.row, Err: fmt.Errorf("invalid age %q", age)}
+		}
+	}
+	return book, nil
+}
+
+func (d *csvDecoder) Row() int {
+	return d.row
+}
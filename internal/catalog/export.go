@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+const (
+	FormatMARC21  = "marc21"
+	FormatMARCXML = "marcxml"
+)
+
+// Encoder writes books one at a time. Close writes what is left of the file and must be called once.
+type Encoder interface {
+	Encode(models.Book) error
+	Close() error
+}
+
+// ExportFormat describes how an export is served.
+type ExportFormat struct {
+	ContentType string
+	Ext         string
+}
+
+var ExportFormats = map[string]ExportFormat{ //nolint:gochecknoglobals //lookup table
+	FormatCSV:     {ContentType: "text/csv", Ext: ".csv"},
+	FormatNDJSON:  {ContentType: "application/x-ndjson", Ext: ".ndjson"},
+	FormatMARC21:  {ContentType: "application/marc", Ext: ".mrc"},
+	FormatMARCXML: {ContentType: "application/marcxml+xml", Ext: ".xml"},
+}
+
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVEncoder(w)
+	case FormatNDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}, nil
+	case FormatMARC21:
+		return &marcEncoder{w: w}, nil
+	case FormatMARCXML:
+		return newMARCXMLEncoder(w)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// csvExportColumns extend the import columns, so an exported file can be imported back.
+var csvExportColumns = []string{ //nolint:gochecknoglobals //file layout
+	"bid", "lable", "author", "desc", "age", "genre", "count", "available",
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func newCSVEncoder(w io.Writer) (*csvEncoder, error) {
+	enc := &csvEncoder{w: csv.NewWriter(w)}
+	if err := enc.w.Write(csvExportColumns); err != nil {
+		return nil, err
+	}
+	return enc, nil
+}
+
+func (e *csvEncoder) Encode(book models.Book) error {
+	return e.w.Write([]string{
+		book.BID, book.Lable, book.Author, book.Desc,
+		strconv.Itoa(book.Age), book.Genre, strconv.Itoa(book.Count), strconv.Itoa(book.Available),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(book models.Book) error {
+	return e.enc.Encode(book)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
@@ -0,0 +1,87 @@
+package catalog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBooks = []models.Book{ //nolint:gochecknoglobals //test data
+	{BID: "b1", Lable: "War and Peace", Author: "Tolstoy, Leo", Desc: "Epic novel, 1812", Age: 12, Count: 3, Available: 1},
+	{BID: "b2", Lable: "Идиот", Author: "Достоевский, Фёдор", Desc: "Роман"},
+}
+
+func encodeAll(t *testing.T, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	enc, err := NewEncoder(format, &buf)
+	require.NoError(t, err)
+	for _, book := range testBooks {
+		require.NoError(t, enc.Encode(book))
+	}
+	require.NoError(t, enc.Close())
+	return buf.String()
+}
+
+func TestCSVExportCanBeImported(t *testing.T) {
+	out := encodeAll(t, FormatCSV)
+	assert.True(t, strings.HasPrefix(out, "bid,lable,author,desc,age,genre,count,available\n"))
+	dec, err := NewDecoder(FormatCSV, strings.NewReader(out))
+	require.NoError(t, err)
+	books, badRows := readAll(t, dec)
+	assert.Empty(t, badRows)
+	assert.Equal(t, "War and Peace", books[0].Lable)
+	assert.Equal(t, "Epic novel, 1812", books[0].Desc)
+	assert.Equal(t, "Идиот", books[1].Lable)
+}
+
+func TestMARC21Export(t *testing.T) {
+	out := encodeAll(t, FormatMARC21)
+	records := strings.Split(strings.TrimSuffix(out, "\x1d"), "\x1d")
+	require.Len(t, records, 2)
+
+	rec := records[0] + "\x1d"
+	assert.Equal(t, "00153nam a2200085 u 4500", rec[:24])
+	assert.Equal(t, "001000300000"+"100001700003"+"245001800020"+"520002100038"+"521000800059"+"\x1e", rec[24:85])
+	assert.Equal(t, "b1\x1e"+
+		"1 \x1faTolstoy, Leo\x1e"+
+		"10\x1faWar and Peace\x1e"+
+		"  \x1faEpic novel, 1812\x1e"+
+		"1 \x1fa12+\x1e"+
+		"\x1d", rec[85:])
+	assert.Len(t, rec, 153)
+
+	// Lengths are counted in bytes, the second record has no 521 field.
+	rec = records[1] + "\x1d"
+	assert.Equal(t, len(rec), atoi(t, rec[:5]))
+	assert.Equal(t, 24+4*12+1, atoi(t, rec[12:17]))
+}
+
+func TestMARCXMLExport(t *testing.T) {
+	out := encodeAll(t, FormatMARCXML)
+	assert.True(t, strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+
+		`<collection xmlns="http://www.loc.gov/MARC21/slim"><record><leader>00000nam a2200000 u 4500</leader>`+
+		`<controlfield tag="001">b1</controlfield>`+
+		`<datafield tag="100" ind1="1" ind2=" "><subfield code="a">Tolstoy, Leo</subfield></datafield>`))
+	assert.Contains(t, out, `<datafield tag="521" ind1="1" ind2=" "><subfield code="a">12+</subfield></datafield></record>`)
+	assert.True(t, strings.HasSuffix(out, "</record></collection>"))
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "ab", truncate("abc", 2))
+	assert.Equal(t, "Д", truncate("Дом", 3))
+}
+
+func atoi(t *testing.T, s string) int {
+	t.Helper()
+	n := 0
+	for _, c := range s {
+		require.True(t, c >= '0' && c <= '9', s)
+		n = n*10 + int(c-'0')
+	}
+	return n
+}
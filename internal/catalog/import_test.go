@@ -0,0 +1,113 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMARC21Import(t *testing.T) {
+	exported := encodeAll(t, FormatMARC21)
+	vendor := "00000nam a2200049 u 4500" +
+		"245002300000" + "100001800023" + "\x1e" +
+		"10\x1faThe Trial /\x1fcKafka\x1e" +
+		"1 \x1faKafka, Franz.\x1e" + "\x1d"
+	input := exported + "\n" + "00010broken\x1d" + vendor
+
+	dec, err := NewDecoder(FormatMARC21, strings.NewReader(input))
+	require.NoError(t, err)
+	books, badRows := readAll(t, dec)
+	assert.Equal(t, []models.Book{
+		{Lable: "War and Peace", Author: "Tolstoy, Leo", Desc: "Epic novel, 1812", Age: 12},
+		{Lable: "Идиот", Author: "Достоевский, Фёдор", Desc: "Роман"},
+		{Lable: "The Trial", Author: "Kafka, Franz"},
+	}, books)
+	assert.Equal(t, []int{3}, badRows)
+}
+
+func TestMARC21ImportRejectsMARC8(t *testing.T) {
+	rec := "00000nam  2200037 u 4500" + "245000800000" + "\x1e" + "10\x1fa\xe2e\x1e" + "\x1d"
+	dec, err := NewDecoder(FormatMARC21, strings.NewReader(rec))
+	require.NoError(t, err)
+	_, err = dec.Next()
+	var rowErr *RowError
+	require.True(t, errors.As(err, &rowErr))
+	assert.ErrorIs(t, err, errMARC8)
+}
+
+func TestMARC21ImportRejectsBadDirectory(t *testing.T) {
+	type test struct {
+		name  string
+		entry string
+	}
+	tests := []test{
+		{name: "negative length", entry: "245-99900000"},
+		{name: "negative start", entry: "2450008-9999"},
+		{name: "signed start", entry: "2450008+0000"},
+		{name: "spaces", entry: "245 008 0000"},
+		{name: "past the end", entry: "245000899999"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := "00000nam a2200037 u 4500" + tc.entry + "\x1e" + "10\x1faTest\x1e" + "\x1d"
+			dec, err := NewDecoder(FormatMARC21, strings.NewReader(rec))
+			require.NoError(t, err)
+			_, err = dec.Next()
+			var rowErr *RowError
+			require.True(t, errors.As(err, &rowErr))
+			assert.ErrorIs(t, err, errMalformedMARC)
+		})
+	}
+}
+
+func TestONIXImport(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<ONIXMessage release="3.0" xmlns="http://ns.editeur.org/onix/3.0/reference">
+  <Header><Sender><SenderName>Publisher</SenderName></Sender></Header>
+  <Product>
+    <RecordReference>pub.0001</RecordReference>
+    <DescriptiveDetail>
+      <TitleDetail>
+        <TitleType>01</TitleType>
+        <TitleElement><TitleElementLevel>01</TitleElementLevel>
+          <TitlePrefix>The</TitlePrefix><TitleWithoutPrefix>Master and Margarita</TitleWithoutPrefix>
+        </TitleElement>
+      </TitleDetail>
+      <Contributor><ContributorRole>B06</ContributorRole><PersonName>Michael Glenny</PersonName></Contributor>
+      <Contributor><ContributorRole>A01</ContributorRole>
+        <NamesBeforeKey>Mikhail</NamesBeforeKey><KeyNames>Bulgakov</KeyNames></Contributor>
+      <Subject><SubjectSchemeIdentifier>10</SubjectSchemeIdentifier><SubjectCode>FIC019000</SubjectCode></Subject>
+      <Subject><SubjectSchemeIdentifier>20</SubjectSchemeIdentifier><SubjectHeadingText>Satire</SubjectHeadingText></Subject>
+      <AudienceRange><AudienceRangeQualifier>17</AudienceRangeQualifier>
+        <AudienceRangePrecision>03</AudienceRangePrecision><AudienceRangeValue>16</AudienceRangeValue>
+      </AudienceRange>
+    </DescriptiveDetail>
+    <CollateralDetail>
+      <TextContent><TextType>02</TextType><Text>Short one</Text></TextContent>
+      <TextContent><TextType>03</TextType><Text>The devil visits Moscow</Text></TextContent>
+    </CollateralDetail>
+  </Product>
+  <Product>
+    <RecordReference>pub.0002</RecordReference>
+    <Title><TitleType>01</TitleType><TitleText>Dead Souls</TitleText></Title>
+    <Contributor><ContributorRole>A01</ContributorRole><PersonName>Nikolai Gogol</PersonName></Contributor>
+    <OtherText><TextTypeCode>01</TextTypeCode><Text>A poem in prose</Text></OtherText>
+  </Product>
+</ONIXMessage>`
+	dec, err := NewDecoder(FormatONIX, strings.NewReader(input))
+	require.NoError(t, err)
+	books, badRows := readAll(t, dec)
+	assert.Empty(t, badRows)
+	assert.Equal(t, []models.Book{
+		{
+			Lable: "The Master and Margarita", Author: "Mikhail Bulgakov", Desc: "The devil visits Moscow",
+			Age: 16, Genre: "Satire",
+		},
+		{Lable: "Dead Souls", Author: "Nikolai Gogol", Desc: "A poem in prose"},
+	}, books)
+	assert.Equal(t, 2, dec.Row())
+}
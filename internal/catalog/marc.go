@@ -0,0 +1,143 @@
+package catalog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// Books are exported as MARC 21 bibliographic records with the following mapping:
+//
+//	Leader     record status "n", type "a" (language material), level "m" (monograph), UTF-8 ("a")
+//	001        BID
+//	100 1# $a  Author
+//	245 10 $a  Lable
+//	520 ## $a  Desc
+//	521 1# $a  Age as an interest age level, e.g. "12+"; omitted when age is 0
+//	655 #4 $a  Genre
+//
+// Copy counters and covers are local holdings data and are not exported.
+// Field data longer than maxFieldData bytes is cut on a rune boundary.
+
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+
+	leaderLen      = 24
+	dirEntryLen    = 12
+	maxFieldData   = 9000
+	maxRecordLen   = 99999
+	marcAgeSuffix  = "+"
+	defaultSubcode = 'a'
+)
+
+type subfield struct {
+	code  byte
+	value string
+}
+
+type marcField struct {
+	tag       string
+	ind1      byte
+	ind2      byte
+	control   string
+	subfields []subfield
+}
+
+func (f marcField) isControl() bool {
+	return f.tag < "010"
+}
+
+type marcRecord struct {
+	fields []marcField
+}
+
+func bookRecord(book models.Book) marcRecord {
+	rec := marcRecord{fields: []marcField{{tag: "001", control: book.BID}}}
+	add := func(tag string, ind1, ind2 byte, value string) {
+		if value == "" {
+			return
+		}
+		rec.fields = append(rec.fields, marcField{
+			tag: tag, ind1: ind1, ind2: ind2,
+			subfields: []subfield{{code: defaultSubcode, value: truncate(value, maxFieldData)}},
+		})
+	}
+	add("100", '1', ' ', book.Author)
+	add("245", '1', '0', book.Lable)
+	add("520", ' ', ' ', book.Desc)
+	if book.Age > 0 {
+		add("521", '1', ' ', strconv.Itoa(book.Age)+marcAgeSuffix)
+	}
+	add("655", ' ', '4', book.Genre)
+	return rec
+}
+
+// leader builds the record leader for the given record length and base address of data.
+func leader(recordLen, baseAddr int) string {
+	return fmt.Sprintf("%05dnam a22%05d u 4500", recordLen, baseAddr)
+}
+
+// marshal encodes the record in ISO 2709 exchange format.
+func (rec marcRecord) marshal() ([]byte, error) {
+	var dir, data bytes.Buffer
+	for _, f := range rec.fields {
+		start := data.Len()
+		if f.isControl() {
+			data.WriteString(f.control)
+		} else {
+			data.WriteByte(f.ind1)
+			data.WriteByte(f.ind2)
+			for _, sf := range f.subfields {
+				data.WriteByte(subfieldDelimiter)
+				data.WriteByte(sf.code)
+				data.WriteString(sf.value)
+			}
+		}
+		data.WriteByte(fieldTerminator)
+		fmt.Fprintf(&dir, "%s%04d%05d", f.tag, data.Len()-start, start)
+	}
+	dir.WriteByte(fieldTerminator)
+	baseAddr := leaderLen + dir.Len()
+	recordLen := baseAddr + data.Len() + 1
+	if recordLen > maxRecordLen {
+		return nil, fmt.Errorf("marc record is %d bytes long", recordLen)
+	}
+	out := make([]byte, 0, recordLen)
+	out = append(out, leader(recordLen, baseAddr)...)
+	out = append(out, dir.Bytes()...)
+	out = append(out, data.Bytes()...)
+	return append(out, recordTerminator), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+type marcEncoder struct {
+	w io.Writer
+}
+
+func (e *marcEncoder) Encode(book models.Book) error {
+	rec, err := bookRecord(book).marshal()
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(rec)
+	return err
+}
+
+func (e *marcEncoder) Close() error {
+	return nil
+}
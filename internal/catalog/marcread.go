@@ -0,0 +1,189 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// MARC 21 records are imported with the export mapping read backwards:
+//
+//	100 $a, else 110 $a, else 700 $a  Author
+//	245 $a                            Lable, the subtitle in $b is left out so titles match the dedup rule
+//	520 $a                            Desc
+//	521 $a                            Age, the first number of the note
+//	655 $a, else 650 $a               Genre
+//
+// The 001 control number belongs to the sending catalog and is not kept. Trailing ISBD punctuation is removed.
+// Only UTF-8 records are supported, MARC-8 records with non ASCII data are rejected.
+
+const isbdPunctuation = " /:;,.="
+
+var (
+	errMARC8         = errors.New("marc-8 encoded records are not supported")
+	errMalformedMARC = errors.New("malformed marc record")
+)
+
+type marcDecoder struct {
+	r   *bufio.Reader
+	row int
+}
+
+func newMARCDecoder(r io.Reader) *marcDecoder {
+	return &marcDecoder{r: bufio.NewReader(r)}
+}
+
+func (d *marcDecoder) Next() (models.Book, error) {
+	for {
+		data, err := d.r.ReadBytes(recordTerminator)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return models.Book{}, err
+		}
+		// Files are sometimes split into lines between records.
+		data = bytes.TrimLeft(data, "\r\n")
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(data)) == 0 {
+				return models.Book{}, io.EOF
+			}
+			d.row++
+			return models.Book{}, &RowError{Row: d.row, Err: errors.New("marc record is not terminated")}
+		}
+		if len(data) <= 1 {
+			continue
+		}
+		d.row++
+		rec, err := unmarshalRecord(data)
+		if err != nil {
+			return models.Book{}, &RowError{Row: d.row, Err: err}
+		}
+		return rec.book(), nil
+	}
+}
+
+func (d *marcDecoder) Row() int {
+	return d.row
+}
+
+// unmarshalRecord parses an ISO 2709 record including its record terminator.
+func unmarshalRecord(data []byte) (marcRecord, error) {
+	if len(data) < leaderLen+1 {
+		return marcRecord{}, errMalformedMARC
+	}
+	lead := data[:leaderLen]
+	if lead[9] != 'a' && !isASCII(data) {
+		return marcRecord{}, errMARC8
+	}
+	baseAddr, ok := number(lead[12:17])
+	if !ok || baseAddr <= leaderLen || baseAddr > len(data) || data[baseAddr-1] != fieldTerminator {
+		return marcRecord{}, errMalformedMARC
+	}
+	dir := data[leaderLen : baseAddr-1]
+	if len(dir)%dirEntryLen != 0 {
+		return marcRecord{}, errMalformedMARC
+	}
+	var rec marcRecord
+	for i := 0; i < len(dir); i += dirEntryLen {
+		entry := string(dir[i : i+dirEntryLen])
+		length, okLen := number([]byte(entry[3:7]))
+		start, okStart := number([]byte(entry[7:12]))
+		if !okLen || !okStart {
+			return marcRecord{}, errMalformedMARC
+		}
+		from, to := baseAddr+start, baseAddr+start+length
+		if length < 1 || from < baseAddr || to > len(data) {
+			return marcRecord{}, errMalformedMARC
+		}
+		rec.fields = append(rec.fields, parseField(entry[:3], data[from:to-1]))
+	}
+	return rec, nil
+}
+
+func parseField(tag string, data []byte) marcField {
+	f := marcField{tag: tag}
+	if f.isControl() {
+		f.control = string(data)
+		return f
+	}
+	if len(data) >= 2 {
+		f.ind1, f.ind2 = data[0], data[1]
+		data = data[2:]
+	}
+	for _, part := range bytes.Split(data, []byte{subfieldDelimiter}) {
+		if len(part) < 1 {
+			continue
+		}
+		f.subfields = append(f.subfields, subfield{code: part[0], value: string(part[1:])})
+	}
+	return f
+}
+
+// subfield returns the first non empty subfield of the first field with one of the tags, in the order of tags.
+func (rec marcRecord) subfield(code byte, tags ...string) string {
+	for _, tag := range tags {
+		for _, f := range rec.fields {
+			if f.tag != tag {
+				continue
+			}
+			for _, sf := range f.subfields {
+				if sf.code == code && strings.TrimSpace(sf.value) != "" {
+					return strings.TrimRight(strings.TrimSpace(sf.value), isbdPunctuation)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func (rec marcRecord) book() models.Book {
+	return models.Book{
+		Lable:  rec.subfield('a', "245"),
+		Author: rec.subfield('a', "100", "110", "700"),
+		Desc:   rec.subfield('a', "520"),
+		Age:    firstNumber(rec.subfield('a', "521")),
+		Genre:  rec.subfield('a', "655", "650"),
+	}
+}
+
+func firstNumber(s string) int {
+	start := strings.IndexFunc(s, unicode.IsDigit)
+	if start < 0 {
+		return 0
+	}
+	end := start
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(s[start:end])
+	return n
+}
+
+// number reads a fixed width field of ASCII digits, signs and spaces are not numbers here.
+func number(data []byte) (int, bool) {
+	if len(data) == 0 {
+		return 0, false
+	}
+	var n int
+	for _, c := range data {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+func isASCII(data []byte) bool {
+	for _, c := range data {
+		if c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+const marcxmlNamespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+// marcxmlEncoder writes a MARCXML collection, one record element per book. Records use the mapping
+// of the binary MARC 21 export, the leader keeps zero record length and base address.
+type marcxmlEncoder struct {
+	enc *xml.Encoder
+}
+
+func newMARCXMLEncoder(w io.Writer) (*marcxmlEncoder, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+	enc := xml.NewEncoder(w)
+	start := xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: marcxmlNamespace}},
+	}
+	if err := enc.EncodeToken(start); err != nil {
+		return nil, err
+	}
+	return &marcxmlEncoder{enc: enc}, nil
+}
+
+func (e *marcxmlEncoder) Encode(book models.Book) error {
+	rec := xmlRecord{Leader: leader(0, 0)}
+	for _, f := range bookRecord(book).fields {
+		if f.isControl() {
+			rec.ControlFields = append(rec.ControlFields, xmlControlField{Tag: f.tag, Value: f.control})
+			continue
+		}
+		df := xmlDataField{Tag: f.tag, Ind1: string(f.ind1), Ind2: string(f.ind2)}
+		for _, sf := range f.subfields {
+			df.Subfields = append(df.Subfields, xmlSubfield{Code: string(sf.code), Value: sf.value})
+		}
+		rec.DataFields = append(rec.DataFields, df)
+	}
+	return e.enc.Encode(rec)
+}
+
+func (e *marcxmlEncoder) Close() error {
+	if err := e.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "collection"}}); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
@@ -0,0 +1,47 @@
+package catalog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+const maxLineSize = 1 << 20
+
+type ndjsonDecoder struct {
+	s   *bufio.Scanner
+	row int
+}
+
+func newNDJSONDecoder(r io.Reader) *ndjsonDecoder {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLineSize)
+	return &ndjsonDecoder{s: s}
+}
+
+// Next skips blank lines, every other line is a JSON encoded book.
+func (d *ndjsonDecoder) Next() (models.Book, error) {
+	for d.s.Scan() {
+		line := bytes.TrimSpace(d.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		d.row++
+		var book models.Book
+		if err := json.Unmarshal(line, &book); err != nil {
+			return models.Book{}, &RowError{Row: d.row, Err: err}
+		}
+		return book, nil
+	}
+	if err := d.s.Err(); err != nil {
+		return models.Book{}, err
+	}
+	return models.Book{}, io.EOF
+}
+
+func (d *ndjsonDecoder) Row() int {
+	return d.row
+}
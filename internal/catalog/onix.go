@@ -0,0 +1,226 @@
+package catalog
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// ONIX for Books messages with reference tag names are imported, both 3.0 and the older 2.1 layout:
+//
+//	Lable   title of TitleType 01 (distinctive title): TitleText or TitlePrefix + TitleWithoutPrefix
+//	Author  first contributor with role A01 (by author), else the first contributor:
+//	        PersonName, else NamesBeforeKey + KeyNames, else CorporateName
+//	Desc    TextContent of TextType 03 (description), else 02 (short description);
+//	        OtherText of TextTypeCode 01 in 2.1
+//	Age     AudienceRange with qualifier 17 (interest age, years): the "from" or "exact" value
+//	Genre   first SubjectHeadingText
+//
+// Short tag messages are not supported.
+
+const (
+	onixTitleDistinctive = "01"
+	onixRoleAuthor       = "A01"
+	onixQualifierAge     = "17"
+	onixPrecisionExact   = "01"
+	onixPrecisionFrom    = "03"
+)
+
+var (
+	onixDescriptionTypes   = []string{"03", "02"} //nolint:gochecknoglobals //lookup table
+	onix21DescriptionTypes = []string{"01", "02"} //nolint:gochecknoglobals //lookup table
+)
+
+type onixTitle struct {
+	TitleType          string             `xml:"TitleType"`
+	TitleText          string             `xml:"TitleText"`
+	TitlePrefix        string             `xml:"TitlePrefix"`
+	TitleWithoutPrefix string             `xml:"TitleWithoutPrefix"`
+	Elements           []onixTitleElement `xml:"TitleElement"`
+}
+
+type onixTitleElement struct {
+	TitleText          string `xml:"TitleText"`
+	TitlePrefix        string `xml:"TitlePrefix"`
+	TitleWithoutPrefix string `xml:"TitleWithoutPrefix"`
+}
+
+type onixContributor struct {
+	Roles          []string `xml:"ContributorRole"`
+	PersonName     string   `xml:"PersonName"`
+	NamesBeforeKey string   `xml:"NamesBeforeKey"`
+	KeyNames       string   `xml:"KeyNames"`
+	CorporateName  string   `xml:"CorporateName"`
+}
+
+type onixText struct {
+	// TextType is the 3.0 element, TextTypeCode the 2.1 one.
+	TextType     string `xml:"TextType"`
+	TextTypeCode string `xml:"TextTypeCode"`
+	Text         string `xml:"Text"`
+}
+
+type onixSubject struct {
+	SubjectHeadingText string `xml:"SubjectHeadingText"`
+}
+
+type onixAudienceRange struct {
+	Qualifier  string   `xml:"AudienceRangeQualifier"`
+	Precisions []string `xml:"AudienceRangePrecision"`
+	Values     []string `xml:"AudienceRangeValue"`
+}
+
+// onixDetail holds the elements that sit in DescriptiveDetail in 3.0 and right in Product in 2.1.
+type onixDetail struct {
+	Titles       []onixTitle         `xml:"TitleDetail"`
+	Titles21     []onixTitle         `xml:"Title"`
+	Contributors []onixContributor   `xml:"Contributor"`
+	Subjects     []onixSubject       `xml:"Subject"`
+	Audience     []onixAudienceRange `xml:"AudienceRange"`
+}
+
+type onixProduct struct {
+	onixDetail
+	Descriptive onixDetail `xml:"DescriptiveDetail"`
+	Texts       []onixText `xml:"CollateralDetail>TextContent"`
+	OtherTexts  []onixText `xml:"OtherText"`
+}
+
+type onixDecoder struct {
+	dec *xml.Decoder
+	row int
+}
+
+func newONIXDecoder(r io.Reader) *onixDecoder {
+	return &onixDecoder{dec: xml.NewDecoder(r)}
+}
+
+// Next decodes the next Product element of the message.
+func (d *onixDecoder) Next() (models.Book, error) {
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return models.Book{}, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Product" {
+			continue
+		}
+		d.row++
+		var product onixProduct
+		if err = d.dec.DecodeElement(&product, &start); err != nil {
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				return models.Book{}, err
+			}
+			return models.Book{}, &RowError{Row: d.row, Err: err}
+		}
+		return product.book(), nil
+	}
+}
+
+func (d *onixDecoder) Row() int {
+	return d.row
+}
+
+func (p onixProduct) book() models.Book {
+	detail := p.Descriptive
+	detail.Titles = append(detail.Titles, p.Titles...)
+	detail.Titles = append(detail.Titles, p.Titles21...)
+	detail.Contributors = append(detail.Contributors, p.Contributors...)
+	detail.Subjects = append(detail.Subjects, p.Subjects...)
+	detail.Audience = append(detail.Audience, p.Audience...)
+	book := models.Book{
+		Lable:  detail.title(),
+		Author: detail.author(),
+		Desc:   description(append(p.Texts, p.OtherTexts...)),
+		Age:    detail.age(),
+	}
+	for _, subject := range detail.Subjects {
+		if book.Genre = strings.TrimSpace(subject.SubjectHeadingText); book.Genre != "" {
+			break
+		}
+	}
+	return book
+}
+
+func (d onixDetail) title() string {
+	for _, title := range d.Titles {
+		if title.TitleType != "" && title.TitleType != onixTitleDistinctive {
+			continue
+		}
+		elements := append([]onixTitleElement{{
+			TitleText: title.TitleText, TitlePrefix: title.TitlePrefix, TitleWithoutPrefix: title.TitleWithoutPrefix,
+		}}, title.Elements...)
+		for _, el := range elements {
+			text := strings.TrimSpace(el.TitleText)
+			if text == "" {
+				text = strings.TrimSpace(el.TitlePrefix + " " + el.TitleWithoutPrefix)
+			}
+			if text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+func (d onixDetail) author() string {
+	if len(d.Contributors) == 0 {
+		return ""
+	}
+	chosen := d.Contributors[0]
+	for _, c := range d.Contributors {
+		if slices.Contains(c.Roles, onixRoleAuthor) {
+			chosen = c
+			break
+		}
+	}
+	switch {
+	case strings.TrimSpace(chosen.PersonName) != "":
+		return strings.TrimSpace(chosen.PersonName)
+	case chosen.KeyNames != "":
+		return strings.TrimSpace(chosen.NamesBeforeKey + " " + chosen.KeyNames)
+	default:
+		return strings.TrimSpace(chosen.CorporateName)
+	}
+}
+
+func (d onixDetail) age() int {
+	for _, audience := range d.Audience {
+		if audience.Qualifier != onixQualifierAge {
+			continue
+		}
+		for i, precision := range audience.Precisions {
+			if (precision == onixPrecisionFrom || precision == onixPrecisionExact) && i < len(audience.Values) {
+				age, err := strconv.Atoi(strings.TrimSpace(audience.Values[i]))
+				if err == nil {
+					return age
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func description(texts []onixText) string {
+	pick := func(types []string, textType func(onixText) string) string {
+		for _, t := range types {
+			for _, text := range texts {
+				if textType(text) == t && strings.TrimSpace(text.Text) != "" {
+					return strings.TrimSpace(text.Text)
+				}
+			}
+		}
+		return ""
+	}
+	if desc := pick(onixDescriptionTypes, func(t onixText) string { return t.TextType }); desc != "" {
+		return desc
+	}
+	return pick(onix21DescriptionTypes, func(t onixText) string { return t.TextTypeCode })
+}
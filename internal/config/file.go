@@ -0,0 +1,152 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const masked = "******"
+
+// secretFlags are masked by Print.
+var secretFlags = map[string]bool{ //nolint:gochecknoglobals //constant set
+	"s3-access-key": true,
+	"s3-secret-key": true,
+	"smtp-pass":     true,
+	"jwt-secret":    true,
+}
+
+// mapFlags take a section of the file, its keys and values are joined into key=value pairs.
+var mapFlags = map[string]bool{ //nolint:gochecknoglobals //constant set
+	"rate-limits": true,
+}
+
+// loadFile sets the flags from a YAML or TOML file. The keys are the flag names, sections
+// are joined to the keys inside them with a dash, so "log: {level: debug}" sets -log-level.
+func loadFile(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	values := make(map[string]any)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("config file %s: unknown format %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	settings := make(map[string]string)
+	if err = flatten("", values, settings); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "config" || name == "print-config" || fs.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		if err = fs.Set(name, settings[name]); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, name, err)
+		}
+	}
+	return nil
+}
+
+func flatten(prefix string, values map[string]any, out map[string]string) error {
+	for key, value := range values {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			if mapFlags[name] {
+				pairs := make([]string, 0, len(value))
+				for key, part := range value {
+					pairs = append(pairs, key+"="+fmt.Sprint(part))
+				}
+				sort.Strings(pairs)
+				out[name] = strings.Join(pairs, ",")
+				continue
+			}
+			if err := flatten(name, value, out); err != nil {
+				return err
+			}
+		case []any:
+			parts := make([]string, 0, len(value))
+			for _, part := range value {
+				parts = append(parts, fmt.Sprint(part))
+			}
+			out[name] = strings.Join(parts, ",")
+		case nil:
+			return fmt.Errorf("%s: no value", name)
+		default:
+			out[name] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// Print writes the configuration ReadConfig would read as a YAML config file, secrets masked.
+func Print(w io.Writer) error {
+	_, fs, err := load(os.Args[1:])
+	if err != nil {
+		return err
+	}
+	settings := make(map[string]any)
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		var value any = f.Value.String()
+		if getter, ok := f.Value.(flag.Getter); ok {
+			value = getter.Get()
+		}
+		if str, ok := value.(string); ok && str != "" && secretFlags[f.Name] {
+			value = masked
+		}
+		if f.Name == "db" {
+			value = maskURL(f.Value.String())
+		}
+		if d, ok := value.(fmt.Stringer); ok {
+			value = d.String()
+		}
+		settings[f.Name] = value
+	})
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	if err = enc.Encode(settings); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// maskURL masks the password of the URL, like the one of the database DSN.
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), masked)
+	}
+	return u.String()
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const minJWTSecret = 16
+
+// Validate checks the settings and their combinations. The error lists every problem found,
+// one per line, named after the flags.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			fail("-%s: %q is not one of %v", name, value, allowed)
+		}
+	}
+	notNegative := func(name string, d time.Duration) {
+		if d < 0 {
+			fail("-%s: must not be negative, got %s", name, d)
+		}
+	}
+	positive := func(name string, d time.Duration) {
+		if d <= 0 {
+			fail("-%s: must be positive, got %s", name, d)
+		}
+	}
+
+	if _, p, err := net.SplitHostPort(c.Addr); err != nil {
+		fail("-addr: %w", err)
+	} else if port, err := strconv.Atoi(p); err != nil || port < 1 || port > 65535 {
+		fail("-port: must be between 1 and 65535, got %s", p)
+	}
+	if c.DBDsn == "" {
+		fail("-db: must be set")
+	}
+	positive("db-timeout", c.DBTimeout)
+	if (c.HTTP.CertFile == "") != (c.HTTP.KeyFile == "") {
+		fail("-tls-cert and -tls-key: must be set together")
+	}
+	if c.HTTP.ClientCA != "" && c.HTTP.CertFile == "" {
+		fail("-tls-client-ca: client certificates need -tls-cert and -tls-key")
+	}
+	notNegative("read-header-timeout", c.HTTP.ReadHeaderTimeout)
+	notNegative("read-timeout", c.HTTP.ReadTimeout)
+	notNegative("write-timeout", c.HTTP.WriteTimeout)
+	notNegative("idle-timeout", c.HTTP.IdleTimeout)
+	oneOf("rate-limit-store", c.RateLimit.Store, "memory", "postgres")
+	if c.ImportQueue <= 0 {
+		fail("-import-queue: must be positive, got %d", c.ImportQueue)
+	}
+	oneOf("blob", c.Blob.Backend, "fs", "s3")
+	if c.Blob.Backend == "fs" && c.Blob.Dir == "" {
+		fail("-blob-dir: must be set for the fs blob storage")
+	}
+	if c.Blob.Backend == "s3" && (c.Blob.S3Endpoint == "" || c.Blob.S3Bucket == "") {
+		fail("-s3-endpoint and -s3-bucket: must be set for the s3 blob storage")
+	}
+	oneOf("metadata", c.Metadata.Provider, "openlibrary", "fixture", "off")
+	if c.Metadata.Provider == "fixture" && c.Metadata.FixtureDir == "" {
+		fail("-metadata-fixtures: must be set for the fixture provider")
+	}
+	if c.Metadata.Provider == "openlibrary" && c.Metadata.URL == "" {
+		fail("-metadata-url: must be set for the openlibrary provider")
+	}
+	notNegative("purge-retention", c.Purge.Retention)
+	notNegative("purge-interval", c.Purge.Interval)
+	notNegative("audit-retention", c.Audit.Retention)
+	notNegative("notify-interval", c.Notify.Interval)
+	notNegative("remind-before", c.Notify.RemindBefore)
+	notNegative("notification-retention", c.Notify.Retention)
+	if c.Notify.SMTPAddr != "" && c.Notify.SMTPFrom == "" {
+		fail("-smtp-from: must be set together with -smtp-addr")
+	}
+	oneOf("trace", c.Trace.Exporter, "otlp", "stdout", "file", "off")
+	if c.Trace.Exporter == "file" && c.Trace.File == "" {
+		fail("-trace-file: must be set for the file exporter")
+	}
+	oneOf("log-level", c.Log.Level, "trace", "debug", "info", "warn", "error")
+	oneOf("log-format", c.Log.Format, "json", "console")
+	if c.Log.File != "" && c.Log.MaxSizeMB <= 0 {
+		fail("-log-max-size: must be positive, got %d", c.Log.MaxSizeMB)
+	}
+	if len(c.Auth.Secret) < minJWTSecret {
+		fail("-jwt-secret: must be at least %d characters long", minJWTSecret)
+	}
+	if c.Auth.Secret == defaultJWTSecret && !c.Debug {
+		fail("-jwt-secret: must be set, the built-in secret is only allowed with -debug")
+	}
+	positive("jwt-ttl", c.Auth.TokenTTL)
+	positive("loan-period", c.Loan.Period)
+	return errors.Join(errs...)
+}
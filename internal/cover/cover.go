@@ -0,0 +1,130 @@
+// Package cover validates uploaded book covers and renders their thumbnails.
+package cover
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+
+	// Registers decoders for image.Decode.
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	SizeOriginal = "original"
+	SizeSmall    = "small"
+	SizeMedium   = "medium"
+	SizeLarge    = "large"
+
+	thumbQuality = 85
+	versionLen   = 16
+	// MaxUploadSize limits the size of an uploaded cover.
+	MaxUploadSize = 10 << 20
+	// MaxPixels limits the dimensions of an uploaded cover, a small file may declare a huge image
+	// whose decoding would take gigabytes of memory.
+	MaxPixels = 40_000_000
+)
+
+var (
+	ErrUnsupportedType = errors.New("cover must be a JPEG, PNG or WebP image")
+	ErrTooLarge        = errors.New("cover must not be larger than 40 megapixels")
+)
+
+// Boxes bound the width and the height of generated thumbnails. Thumbnails keep the aspect ratio
+// of the original, fit into their box and are never upscaled.
+var Boxes = map[string]image.Point{ //nolint:gochecknoglobals //lookup table
+	SizeSmall:  {X: 96, Y: 144},
+	SizeMedium: {X: 240, Y: 360},
+	SizeLarge:  {X: 480, Y: 720},
+}
+
+var extensions = map[string]string{ //nolint:gochecknoglobals //lookup table
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+type Image struct {
+	Size        string
+	ContentType string
+	Data        []byte
+}
+
+// Cover is a processed upload: the original image and its JPEG thumbnails.
+type Cover struct {
+	// Version is derived from the original content and changes with every new cover.
+	Version string
+	Images  []Image
+}
+
+// Process sniffs the content type of the upload, checks its dimensions, decodes it and renders
+// all thumbnail sizes.
+func Process(data []byte) (Cover, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := extensions[contentType]; !ok {
+		return Cover{}, ErrUnsupportedType
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Cover{}, err
+	}
+	if cfg.Width < 1 || cfg.Height < 1 || cfg.Width > MaxPixels/cfg.Height {
+		return Cover{}, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Cover{}, err
+	}
+	sum := sha256.Sum256(data)
+	cover := Cover{
+		Version: hex.EncodeToString(sum[:])[:versionLen],
+		Images:  []Image{{Size: SizeOriginal, ContentType: contentType, Data: data}},
+	}
+	for _, size := range []string{SizeSmall, SizeMedium, SizeLarge} {
+		thumb, err := thumbnail(src, Boxes[size])
+		if err != nil {
+			return Cover{}, err
+		}
+		cover.Images = append(cover.Images, Image{Size: size, ContentType: "image/jpeg", Data: thumb})
+	}
+	return cover, nil
+}
+
+// Key builds the blob key of a cover image.
+func Key(bid, version, size, contentType string) string {
+	ext := extensions[contentType]
+	if size != SizeOriginal {
+		ext = ".jpg"
+	}
+	return "covers/" + bid + "/" + version + "/" + size + ext
+}
+
+func thumbnail(src image.Image, box image.Point) ([]byte, error) {
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	// The image is wider than the box in proportion, so the width limits the scale, otherwise the height does.
+	if w*box.Y > h*box.X {
+		if w > box.X {
+			h = h * box.X / w
+			w = box.X
+		}
+	} else if h > box.Y {
+		w = w * box.Y / h
+		h = box.Y
+	}
+	w, h = max(w, 1), max(h, 1)
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Src, nil)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,87 @@
+package cover
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcess(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 600, 900))
+	for x := range 600 {
+		for y := range 900 {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+
+	cover, err := Process(buf.Bytes())
+	require.NoError(t, err)
+	assert.Len(t, cover.Version, 16)
+	require.Len(t, cover.Images, 4)
+	assert.Equal(t, SizeOriginal, cover.Images[0].Size)
+	assert.Equal(t, "image/png", cover.Images[0].ContentType)
+
+	for _, img := range cover.Images[1:] {
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(img.Data))
+		require.NoError(t, err)
+		assert.Equal(t, Boxes[img.Size].X, cfg.Width, img.Size)
+		assert.Equal(t, Boxes[img.Size].Y, cfg.Height, img.Size)
+	}
+	assert.Equal(t, "covers/b1/"+cover.Version+"/original.png", Key("b1", cover.Version, SizeOriginal, "image/png"))
+	assert.Equal(t, "covers/b1/"+cover.Version+"/small.jpg", Key("b1", cover.Version, SizeSmall, "image/jpeg"))
+}
+
+func TestProcessSmallImageIsNotUpscaled(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 50, 80)), nil))
+	cover, err := Process(buf.Bytes())
+	require.NoError(t, err)
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(cover.Images[3].Data))
+	require.NoError(t, err)
+	assert.Equal(t, 50, cfg.Width)
+	assert.Equal(t, 80, cfg.Height)
+}
+
+func TestProcessFitsTallImageIntoBox(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 300, 1800)), nil))
+	cover, err := Process(buf.Bytes())
+	require.NoError(t, err)
+	for _, img := range cover.Images[1:] {
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(img.Data))
+		require.NoError(t, err)
+		assert.Equal(t, Boxes[img.Size].Y, cfg.Height, img.Size)
+		assert.Equal(t, Boxes[img.Size].Y/6, cfg.Width, img.Size)
+	}
+}
+
+func TestProcessRejectsOtherTypes(t *testing.T) {
+	_, err := Process([]byte("GIF89a......"))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = Process([]byte("<html><body>not an image</body></html>"))
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestProcessRejectsHugeDimensions(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1))))
+	data := buf.Bytes()
+	// Declare 100000x100000 pixels in the IHDR chunk and fix its checksum.
+	ihdr := data[12:29]
+	binary.BigEndian.PutUint32(ihdr[4:8], 100000)
+	binary.BigEndian.PutUint32(ihdr[8:12], 100000)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(ihdr))
+
+	_, err := Process(data)
+	assert.ErrorIs(t, err, ErrTooLarge)
+}
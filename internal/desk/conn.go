@@ -0,0 +1,109 @@
+package desk
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gorilla/websocket"
+)
+
+// Serve runs the desk connection of the librarian uid at the branch until either side closes it
+// or done is closed. Desks are pinged every consts.DeskPingInterval and dropped when nothing,
+// not even a pong, comes from them for consts.DeskPongWait.
+func (h *Hub) Serve(done <-chan struct{}, conn *websocket.Conn, branch, uid string) {
+	log := logger.Get().With().Str("branch", branch).Str("uid", uid).Logger()
+	defer conn.Close()
+	d := h.join(branch)
+	defer h.leave(d)
+	h.sendList(d)
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		h.read(conn, d, uid)
+	}()
+
+	ping := time.NewTicker(consts.DeskPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case msg := <-d.send:
+			_ = conn.SetWriteDeadline(time.Now().Add(consts.DeskWriteWait))
+			if err := conn.WriteJSON(msg); err != nil {
+				log.Debug().Err(err).Msg("write to desk failed")
+				return
+			}
+		case <-ping.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(consts.DeskWriteWait))
+			if err != nil {
+				log.Debug().Err(err).Msg("ping desk failed")
+				return
+			}
+		case <-d.slow:
+			log.Warn().Msg("desk is too slow, dropping it")
+			closeWith(conn, websocket.CloseTryAgainLater, "too slow")
+			return
+		case <-readDone:
+			return
+		case <-done:
+			closeWith(conn, websocket.CloseGoingAway, "server is stopping")
+			return
+		}
+	}
+}
+
+// read handles the messages of the desk until the connection breaks.
+func (h *Hub) read(conn *websocket.Conn, d *desk, uid string) {
+	log := logger.Get()
+	conn.SetReadLimit(consts.DeskMaxMessage)
+	_ = conn.SetReadDeadline(time.Now().Add(consts.DeskPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(consts.DeskPongWait))
+	})
+	for {
+		var msg Message
+		if err := conn.ReadJSON(&msg); err != nil {
+			var closeErr *websocket.CloseError
+			if !errors.As(err, &closeErr) {
+				log.Debug().Err(err).Msg("read from desk failed")
+			}
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(consts.DeskPongWait))
+		switch msg.Type {
+		case TypeAck:
+			alert, err := h.store.AckDeskAlert(msg.ID, d.branch, uid)
+			if err != nil {
+				if !errors.Is(err, storerrros.ErrAlertNoExist) {
+					log.Error().Err(err).Int64("alert", msg.ID).Msg("ack desk alert failed")
+				}
+				d.push(Message{Type: TypeError, ID: msg.ID, Error: err.Error()})
+				continue
+			}
+			h.Broadcast(d.branch, Message{Type: TypeAcked, ID: alert.ID, Alert: &alert})
+		case TypeList:
+			h.sendList(d)
+		case TypePing:
+			d.push(Message{Type: TypePong})
+		default:
+			d.push(Message{Type: TypeError, Error: "unknown message type " + msg.Type})
+		}
+	}
+}
+
+func (h *Hub) sendList(d *desk) {
+	alerts, err := h.store.GetDeskAlerts(d.branch)
+	if err != nil {
+		d.push(Message{Type: TypeError, Error: err.Error()})
+		return
+	}
+	d.push(Message{Type: TypeAlerts, Alerts: alerts})
+}
+
+func closeWith(conn *websocket.Conn, code int, text string) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text),
+		time.Now().Add(consts.DeskWriteWait))
+}
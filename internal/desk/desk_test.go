@@ -0,0 +1,143 @@
+package desk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/storage"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setup(t *testing.T) (*storage.MemStorage, *Hub, string) {
+	t.Helper()
+	logger.Get(false)
+	stor := storage.New()
+	require.NoError(t, stor.SaveBranch(models.Branch{ID: "B1", Name: "Central"}))
+	hub := NewHub(stor, 8)
+	done := make(chan struct{})
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		hub.Serve(done, conn, r.URL.Query().Get("branch"), "UID1")
+	}))
+	t.Cleanup(func() {
+		close(done)
+		srv.Close()
+	})
+	return stor, hub, "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func dial(t *testing.T, url, branch string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?branch="+branch, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	var msg Message
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func holdReady(id int64, hid, branch string) models.Event {
+	data, _ := json.Marshal(models.Hold{HID: hid, BID: "BID1", UID: "UID9", PickupBranch: branch})
+	return models.Event{ID: id, Type: models.EventHoldReady, Subject: hid, Data: data}
+}
+
+func TestDesk(t *testing.T) {
+	stor, hub, url := setup(t)
+	conn := dial(t, url, "B1")
+	assert.Equal(t, Message{Type: TypeAlerts}, read(t, conn))
+	other := dial(t, url, "B2")
+	assert.Equal(t, Message{Type: TypeAlerts}, read(t, other))
+
+	require.NoError(t, hub.Publish(context.Background(), []models.Event{holdReady(1, "HID1", "B1")}))
+	msg := read(t, conn)
+	assert.Equal(t, TypeAlert, msg.Type)
+	require.NotNil(t, msg.Alert)
+	assert.Equal(t, models.DeskPull, msg.Alert.Kind)
+	assert.Equal(t, "HID1", msg.Alert.Subject)
+	alertID := msg.Alert.ID
+
+	// The same event published again opens no second alert, and the other branch hears nothing.
+	require.NoError(t, hub.Publish(context.Background(), []models.Event{holdReady(1, "HID1", "B1")}))
+	for _, c := range []*websocket.Conn{conn, other} {
+		require.NoError(t, c.WriteJSON(Message{Type: TypePing}))
+		assert.Equal(t, Message{Type: TypePong}, read(t, c))
+	}
+
+	require.NoError(t, conn.WriteJSON(Message{Type: TypeList}))
+	msg = read(t, conn)
+	assert.Equal(t, TypeAlerts, msg.Type)
+	assert.Len(t, msg.Alerts, 1)
+
+	require.NoError(t, conn.WriteJSON(Message{Type: TypeAck, ID: alertID}))
+	msg = read(t, conn)
+	assert.Equal(t, TypeAcked, msg.Type)
+	assert.Equal(t, alertID, msg.ID)
+	assert.Equal(t, "UID1", msg.Alert.AckedBy)
+	alerts, err := stor.GetDeskAlerts("B1")
+	require.NoError(t, err)
+	assert.Empty(t, alerts)
+
+	require.NoError(t, other.WriteJSON(Message{Type: TypeAck, ID: alertID}))
+	assert.Equal(t, Message{Type: TypeError, ID: alertID, Error: "alert does not exists"}, read(t, other))
+	require.NoError(t, other.WriteJSON(Message{Type: "shout"}))
+	assert.Equal(t, Message{Type: TypeError, Error: "unknown message type shout"}, read(t, other))
+}
+
+func TestOverdueAlert(t *testing.T) {
+	stor, hub, url := setup(t)
+	require.NoError(t, stor.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", Desc: "Desert planet", Age: 12},
+		models.AuditEntry{}))
+	books, err := stor.GetBooks()
+	require.NoError(t, err)
+	barcode, err := stor.SaveItem(models.Item{BID: books[0].BID, HomeBranch: "B1"}, models.AuditEntry{})
+	require.NoError(t, err)
+	conn := dial(t, url, "B1")
+	read(t, conn)
+
+	data, _ := json.Marshal(models.Loan{LID: "LID1", Barcode: barcode, BID: books[0].BID, UID: "UID9"})
+	require.NoError(t, hub.Publish(context.Background(), []models.Event{
+		{ID: 2, Type: models.EventBookAdded, Data: json.RawMessage(`{}`)},
+		{ID: 3, Type: models.EventLoanOverdue, Subject: "LID1", Data: data},
+	}))
+	msg := read(t, conn)
+	require.NotNil(t, msg.Alert)
+	assert.Equal(t, models.DeskOverdue, msg.Alert.Kind)
+	assert.Equal(t, "LID1", msg.Alert.Subject)
+	assert.Equal(t, int64(3), msg.Alert.EventID)
+}
+
+func TestSlowDesk(t *testing.T) {
+	logger.Get(false)
+	hub := NewHub(storage.New(), 2)
+	d := hub.join("B1")
+	for i := range 3 {
+		hub.Broadcast("B1", Message{Type: TypeAcked, ID: int64(i)})
+	}
+	select {
+	case <-d.slow:
+	default:
+		t.Fatal("slow desk was not marked")
+	}
+	assert.Len(t, d.send, 2)
+	hub.leave(d)
+	assert.Empty(t, hub.desks)
+}
@@ -0,0 +1,163 @@
+// Package desk keeps the circulation desks of the branches up to date over WebSocket:
+// copies to pull for ready holds and overdue loans, each open until a librarian acknowledges it.
+package desk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+// Message types. A desk gets the open alerts of its branch as a list on connect and on request,
+// then every new alert and every acknowledgement made at the branch.
+const (
+	TypeAlerts = "alerts"
+	TypeAlert  = "alert"
+	TypeAcked  = "acked"
+	TypeError  = "error"
+	TypePong   = "pong"
+
+	// Sent by desks.
+	TypeAck  = "ack"
+	TypeList = "list"
+	TypePing = "ping"
+)
+
+// Message is one JSON message of the desk channel in both directions.
+type Message struct {
+	Type   string             `json:"type"`
+	ID     int64              `json:"id,omitempty"`
+	Alert  *models.DeskAlert  `json:"alert,omitempty"`
+	Alerts []models.DeskAlert `json:"alerts,omitempty"`
+	Error  string             `json:"error,omitempty"`
+}
+
+// Store keeps the desk alerts.
+type Store interface {
+	SaveDeskAlert(models.DeskAlert) (models.DeskAlert, error)
+	GetDeskAlerts(branch string) ([]models.DeskAlert, error)
+	AckDeskAlert(id int64, branch, uid string) (models.DeskAlert, error)
+	GetItem(barcode string) (models.Item, error)
+}
+
+// Hub routes messages to the desks connected to a branch. A desk which does not keep up
+// is dropped instead of slowing the others down, it gets the open alerts again on reconnect.
+type Hub struct {
+	store  Store
+	buffer int
+
+	mu    sync.Mutex
+	desks map[string]map[*desk]struct{}
+}
+
+type desk struct {
+	branch string
+	send   chan Message
+	// slow is closed when the desk fell behind and has to go.
+	slow chan struct{}
+	once sync.Once
+}
+
+func NewHub(store Store, buffer int) *Hub {
+	return &Hub{store: store, buffer: buffer, desks: make(map[string]map[*desk]struct{})}
+}
+
+func (h *Hub) join(branch string) *desk {
+	d := &desk{branch: branch, send: make(chan Message, h.buffer), slow: make(chan struct{})}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.desks[branch] == nil {
+		h.desks[branch] = make(map[*desk]struct{})
+	}
+	h.desks[branch][d] = struct{}{}
+	return d
+}
+
+func (h *Hub) leave(d *desk) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.desks[d.branch], d)
+	if len(h.desks[d.branch]) == 0 {
+		delete(h.desks, d.branch)
+	}
+}
+
+// Broadcast sends the message to every desk of the branch.
+func (h *Hub) Broadcast(branch string, msg Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for d := range h.desks[branch] {
+		d.push(msg)
+	}
+}
+
+// push queues the message without waiting. A full queue marks the desk as slow.
+func (d *desk) push(msg Message) bool {
+	select {
+	case d.send <- msg:
+		return true
+	default:
+		d.once.Do(func() { close(d.slow) })
+		return false
+	}
+}
+
+// Publish is the events.Sink turning ready holds and overdue loans into desk alerts.
+// Events seen again are skipped, their alerts were sent already.
+func (h *Hub) Publish(_ context.Context, events []models.Event) error {
+	log := logger.Get()
+	for _, event := range events {
+		alert, ok, err := h.alertOf(event)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		alert, err = h.store.SaveDeskAlert(alert)
+		if errors.Is(err, storerrros.ErrAlertExists) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Debug().Int64("alert", alert.ID).Str("branch", alert.Branch).Msg("desk alert opened")
+		h.Broadcast(alert.Branch, Message{Type: TypeAlert, Alert: &alert})
+	}
+	return nil
+}
+
+// alertOf makes the alert for the desk which has to act on the event. A ready hold is pulled at
+// the pickup branch, an overdue loan is chased by the home branch of the copy.
+func (h *Hub) alertOf(event models.Event) (models.DeskAlert, bool, error) {
+	alert := models.DeskAlert{EventID: event.ID, Data: event.Data}
+	switch event.Type {
+	case models.EventHoldReady:
+		var hold models.Hold
+		if err := json.Unmarshal(event.Data, &hold); err != nil {
+			return models.DeskAlert{}, false, err
+		}
+		alert.Kind, alert.Subject, alert.Branch = models.DeskPull, hold.HID, hold.PickupBranch
+	case models.EventLoanOverdue:
+		var loan models.Loan
+		if err := json.Unmarshal(event.Data, &loan); err != nil {
+			return models.DeskAlert{}, false, err
+		}
+		item, err := h.store.GetItem(loan.Barcode)
+		if errors.Is(err, storerrros.ErrItemNoExist) {
+			return models.DeskAlert{}, false, nil
+		}
+		if err != nil {
+			return models.DeskAlert{}, false, err
+		}
+		alert.Kind, alert.Subject, alert.Branch = models.DeskOverdue, loan.LID, item.HomeBranch
+	default:
+		return models.DeskAlert{}, false, nil
+	}
+	return alert, true, nil
+}
@@ -2,4 +2,108 @@ package consts
 
 import "time"
 
-const DBCtxTimeout = 5 * time.Second
+const (
+	DefaultCondition = "good"
+)
+
+const (
+	MaxImportSize = 512 << 20
+	// ImportFlushRows is how often an import job saves its progress and row errors.
+	ImportFlushRows = 500
+)
+
+// ExportPageSize is the number of books an export reads from the storage at once.
+const ExportPageSize = 500
+
+// PreviewRows is the number of records an import dry run describes one by one.
+const PreviewRows = 200
+
+const (
+	MetadataCacheTTL  = 24 * time.Hour
+	MetadataCacheSize = 10000
+)
+
+const (
+	// PurgeBatchSize is the number of deleted books the purge job removes in one statement.
+	PurgeBatchSize   = 500
+	JobRetryInitial  = 5 * time.Second
+	JobRetryMax      = time.Minute
+	JobRetryAttempts = 5
+)
+
+const (
+	// AuditLimit is the number of latest audit entries the audit log returns.
+	AuditLimit         = 1000
+	AuditPurgeInterval = 24 * time.Hour
+)
+
+const (
+	OutboxPollInterval = time.Second
+	OutboxBatchSize    = 100
+	// OutboxSinkAttempts is how many times a sink is offered the same events before the relay
+	// gives up on them for that sink, so that one failing sink does not hold the others back.
+	OutboxSinkAttempts = 5
+)
+
+const (
+	// StreamBuffer is the number of events an event stream client may fall behind before it misses some.
+	StreamBuffer = 64
+	// StreamReplayLimit is the number of published events a resumed stream catches up on.
+	StreamReplayLimit = 1000
+	StreamKeepAlive   = 15 * time.Second
+)
+
+// OverdueCheckInterval is how often loans past their due date are looked for.
+const OverdueCheckInterval = 15 * time.Minute
+
+const (
+	WebhookPollInterval = time.Second
+	WebhookBatchSize    = 50
+	// WebhookWorkers is the number of deliveries sent at the same time.
+	WebhookWorkers = 8
+	WebhookTimeout = 10 * time.Second
+	// WebhookLease is how long a delivery taken for sending is hidden from other dispatchers. It outlasts
+	// sending a whole batch, ceil(WebhookBatchSize/WebhookWorkers) rounds of requests of up to WebhookTimeout
+	// each, with a minute to spare, so a slow batch is not taken and sent twice.
+	WebhookLease        = (WebhookBatchSize+WebhookWorkers-1)/WebhookWorkers*WebhookTimeout + time.Minute
+	WebhookRetryInitial = 10 * time.Second
+	WebhookRetryMax     = time.Hour
+	WebhookMaxAttempts  = 8
+	// DeliveriesLimit is the number of latest deliveries the delivery history returns.
+	DeliveriesLimit = 100
+)
+
+const (
+	// DeskBuffer is the number of messages a desk connection may fall behind before it is dropped.
+	DeskBuffer       = 64
+	DeskPingInterval = 30 * time.Second
+	// DeskPongWait is how long a desk connection may stay silent, it must be longer than DeskPingInterval.
+	DeskPongWait   = time.Minute
+	DeskWriteWait  = 10 * time.Second
+	DeskMaxMessage = 4096
+)
+
+const (
+	// NoticeMaxAttempts is how many times a notice which failed to send is tried.
+	NoticeMaxAttempts = 3
+	// NoticeLogLimit is the number of latest notices the delivery log returns.
+	NoticeLogLimit = 200
+	// DefaultLocale is the language of notices for users who did not choose one.
+	DefaultLocale = "en"
+)
+
+const (
+	NotificationsPageSize = 20
+	// NotificationsMaxPage is the largest page of the inbox a client may ask for.
+	NotificationsMaxPage      = 100
+	NotificationPurgeInterval = 24 * time.Hour
+)
+
+// MetricsReadTimeout limits reading the request headers on the metrics port.
+const MetricsReadTimeout = 5 * time.Second
+
+// CertCheckInterval is how often the TLS certificate files are looked at for changes.
+const CertCheckInterval = 10 * time.Second
+
+// RateLimitPurgeInterval is how often the rate buckets which filled up again are dropped.
+const RateLimitPurgeInterval = 10 * time.Minute
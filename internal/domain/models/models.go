@@ -1,17 +1,449 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type User struct {
 	UID   string `json:"uuid,omitempty"`
 	Email string `json:"email" validate:"required,email"`
 	Pass  string `json:"pass" validate:"required,min=8"`
 	Age   int    `json:"age" validate:"required,gte=16"`
+	Role  string `json:"role,omitempty"`
 }
 
+const (
+	RoleMember    = "member"
+	RoleLibrarian = "librarian"
+	RoleAdmin     = "admin"
+)
+
 type Book struct {
-	BID    string `json:"bid,omitempty"`
-	Lable  string `json:"lable" validate:"required,min=3"`
-	Author string `json:"author" validate:"required,min=5"`
-	Desc   string `json:"desc" validate:"required,min=10"`
-	Age    int    `json:"age" validate:"required"`
-	Count  int    `json:"count,omitempty"`
+	BID       string `json:"bid,omitempty"`
+	Lable     string `json:"lable" validate:"required,min=3"`
+	Author    string `json:"author" validate:"required,min=5"`
+	Desc      string `json:"desc" validate:"required,min=10"`
+	Age       int    `json:"age" validate:"required"`
+	Genre     string `json:"genre,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Available int    `json:"available,omitempty"`
+	// Cover is the version of the current cover image, empty when the book has none.
+	Cover     string `json:"cover,omitempty"`
+	CoverType string `json:"-"`
+}
+
+// TrashedBook is a soft deleted book waiting in the trash to be restored or purged.
+type TrashedBook struct {
+	Book
+	DeletedBy string    `json:"deleted_by,omitempty"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+type ItemStatus string
+
+const (
+	ItemAvailable  ItemStatus = "available"
+	ItemCheckedOut ItemStatus = "checked_out"
+	ItemDamaged    ItemStatus = "damaged"
+	ItemLost       ItemStatus = "lost"
+	ItemOnHold     ItemStatus = "on_hold"
+	ItemInTransit  ItemStatus = "in_transit"
+)
+
+// Item is a single physical copy of a book identified by its barcode.
+type Item struct {
+	Barcode    string     `json:"barcode"`
+	BID        string     `json:"bid,omitempty"`
+	AcquiredAt time.Time  `json:"acquired_at"`
+	Condition  string     `json:"condition" validate:"omitempty,oneof=new good fair poor"`
+	Location   string     `json:"location"`
+	Status     ItemStatus `json:"status" validate:"omitempty,oneof=available checked_out damaged lost"`
+	// HomeBranch owns the copy, CurrentBranch is where the copy is shelved right now.
+	HomeBranch    string `json:"home_branch,omitempty"`
+	CurrentBranch string `json:"current_branch,omitempty"`
+}
+
+type Loan struct {
+	LID        string     `json:"lid"`
+	Barcode    string     `json:"barcode"`
+	BID        string     `json:"bid"`
+	UID        string     `json:"uid"`
+	IssuedAt   time.Time  `json:"issued_at"`
+	DueAt      time.Time  `json:"due_at"`
+	ReturnedAt *time.Time `json:"returned_at,omitempty"`
+}
+
+type Branch struct {
+	ID      string `json:"id" validate:"required,max=36"`
+	Name    string `json:"name" validate:"required,min=3"`
+	Address string `json:"address"`
+}
+
+// Availability holds copy counters of a book at a single branch.
+type Availability struct {
+	Branch    string `json:"branch"`
+	Count     int    `json:"count"`
+	Available int    `json:"available"`
+}
+
+type HoldStatus string
+
+const (
+	HoldWaiting   HoldStatus = "waiting"
+	HoldInTransit HoldStatus = "in_transit"
+	HoldReady     HoldStatus = "ready"
+	HoldFulfilled HoldStatus = "fulfilled"
+	HoldCancelled HoldStatus = "cancelled"
+)
+
+type Hold struct {
+	HID          string     `json:"hid"`
+	BID          string     `json:"bid"`
+	UID          string     `json:"uid"`
+	PickupBranch string     `json:"pickup_branch" validate:"required"`
+	Barcode      string     `json:"barcode,omitempty"`
+	Status       HoldStatus `json:"status"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ReadyAt      *time.Time `json:"ready_at,omitempty"`
+}
+
+type TransferStatus string
+
+const (
+	TransferRequested TransferStatus = "requested"
+	TransferInTransit TransferStatus = "in_transit"
+	TransferReceived  TransferStatus = "received"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+// Transfer moves a copy between branches, either on librarian request or to fill a hold.
+type Transfer struct {
+	TID         string         `json:"tid"`
+	Barcode     string         `json:"barcode" validate:"required"`
+	FromBranch  string         `json:"from_branch"`
+	ToBranch    string         `json:"to_branch" validate:"required"`
+	HID         string         `json:"hid,omitempty"`
+	Status      TransferStatus `json:"status"`
+	RequestedBy string         `json:"requested_by,omitempty"`
+	RequestedAt time.Time      `json:"requested_at"`
+	ShippedAt   *time.Time     `json:"shipped_at,omitempty"`
+	ReceivedAt  *time.Time     `json:"received_at,omitempty"`
+}
+
+type ImportStatus string
+
+const (
+	ImportQueued  ImportStatus = "queued"
+	ImportRunning ImportStatus = "running"
+	ImportDone    ImportStatus = "done"
+	ImportFailed  ImportStatus = "failed"
+)
+
+// ImportJob tracks a bulk catalog upload processed in the background.
+type ImportJob struct {
+	ID         string       `json:"id"`
+	Format     string       `json:"format"`
+	Status     ImportStatus `json:"status"`
+	Processed  int          `json:"processed"`
+	Imported   int          `json:"imported"`
+	Failed     int          `json:"failed"`
+	Error      string       `json:"error,omitempty"`
+	CreatedBy  string       `json:"created_by,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	FinishedAt *time.Time   `json:"finished_at,omitempty"`
+}
+
+// ImportError describes a rejected row of an import. Rows are numbered from 1 not counting the header.
+type ImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type PreviewAction string
+
+const (
+	PreviewCreate PreviewAction = "create"
+	PreviewMerge  PreviewAction = "merge"
+	PreviewError  PreviewAction = "error"
+)
+
+// PreviewRow tells what an import would do with a record. Merged records add a copy to the book BID,
+// empty when the book itself is created earlier in the same file.
+type PreviewRow struct {
+	Row    int           `json:"row"`
+	Action PreviewAction `json:"action"`
+	BID    string        `json:"bid,omitempty"`
+	Book   *Book         `json:"book,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// ImportPreview is the result of a dry run. Counters cover the whole file, Rows only the first records.
+type ImportPreview struct {
+	Format    string       `json:"format"`
+	Processed int          `json:"processed"`
+	Create    int          `json:"create"`
+	Merge     int          `json:"merge"`
+	Failed    int          `json:"failed"`
+	Rows      []PreviewRow `json:"rows"`
+	Truncated bool         `json:"truncated,omitempty"`
+}
+
+// BookDraft is book data found by ISBN. It pre-fills the form a librarian saves as a book.
+type BookDraft struct {
+	ISBN     string `json:"isbn"`
+	Lable    string `json:"lable"`
+	Author   string `json:"author"`
+	Desc     string `json:"desc"`
+	Genre    string `json:"genre,omitempty"`
+	CoverURL string `json:"cover_url,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditCount   AuditAction = "count"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+	AuditPurge   AuditAction = "purge"
+)
+
+const (
+	AuditBook = "book"
+	AuditItem = "item"
+	AuditUser = "user"
+)
+
+// FieldChange is one field of an audited entity, Before is empty for created entities.
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before,omitempty"`
+	After  any    `json:"after,omitempty"`
+}
+
+// AuditEntry records who changed a book, a copy or a user, when and how. Entries are never changed,
+// they are only removed once older than the audit retention period.
+type AuditEntry struct {
+	ID        int64         `json:"id"`
+	Entity    string        `json:"entity"`
+	EntityID  string        `json:"entity_id"`
+	Action    AuditAction   `json:"action"`
+	Actor     string        `json:"actor,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	At        time.Time     `json:"at"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+type EventType string
+
+const (
+	EventBookAdded       EventType = "BookAdded"
+	EventCopyAdded       EventType = "CopyAdded"
+	EventBookDeleted     EventType = "BookDeleted"
+	EventBookRestored    EventType = "BookRestored"
+	EventBookPurged      EventType = "BookPurged"
+	EventLoanCreated     EventType = "LoanCreated"
+	EventLoanReturned    EventType = "LoanReturned"
+	EventLoanOverdue     EventType = "LoanOverdue"
+	EventHoldReady       EventType = "HoldReady"
+	EventUserRegistered  EventType = "UserRegistered"
+	EventUserRoleChanged EventType = "UserRoleChanged"
+)
+
+// Event is a domain event written to the outbox together with the change it describes.
+// Subject is the ID of the changed book, loan or user.
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      EventType       `json:"type"`
+	Subject   string          `json:"subject"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Webhook subscribes a URL to events of the listed types. Deliveries are signed with the secret,
+// it is shown only in the response to the registration.
+type Webhook struct {
+	ID        string      `json:"id"`
+	URL       string      `json:"url" validate:"required,url"`
+	Secret    string      `json:"secret,omitempty" validate:"omitempty,min=16"`
+	Events    []EventType `json:"events" validate:"required,min=1,dive,oneof=BookAdded CopyAdded BookDeleted BookRestored BookPurged LoanCreated LoanReturned LoanOverdue HoldReady UserRegistered UserRoleChanged"`
+	CreatedBy string      `json:"created_by,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+// Delivery is an event sent to a webhook. Pending deliveries are retried at NextAttemptAt,
+// failed ones gave up after the last attempt and wait for a replay.
+type Delivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       int64           `json:"event_id"`
+	EventType     EventType       `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// DeliveryAttempt is one request sent for a delivery. StatusCode is zero when no response came.
+type DeliveryAttempt struct {
+	ID         int64         `json:"id"`
+	DeliveryID int64         `json:"delivery_id"`
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// DeliveryTask is a due delivery together with where to send it and how to sign it.
+type DeliveryTask struct {
+	Delivery
+	URL    string
+	Secret string
+}
+
+type DeskAlertKind string
+
+const (
+	// DeskPull asks the desk to take the copy of a ready hold off the shelf.
+	DeskPull    DeskAlertKind = "pull"
+	DeskOverdue DeskAlertKind = "overdue"
+)
+
+// DeskAlert is a task for the circulation desk of a branch. It stays open until a librarian acknowledges it.
+// Subject is the ID of the hold or the loan, Data is the event the alert was made from.
+type DeskAlert struct {
+	ID        int64           `json:"id"`
+	EventID   int64           `json:"event_id"`
+	Branch    string          `json:"branch"`
+	Kind      DeskAlertKind   `json:"kind"`
+	Subject   string          `json:"subject"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+	AckedBy   string          `json:"acked_by,omitempty"`
+	AckedAt   *time.Time      `json:"acked_at,omitempty"`
+}
+
+type NotifyChannel string
+
+const (
+	NotifyEmail NotifyChannel = "email"
+	NotifyInApp NotifyChannel = "in_app"
+	NotifyNone  NotifyChannel = "none"
+)
+
+// NotifyPrefs is how a user wants to get notices and in which language. Every notice but with
+// NotifyNone lands in the inbox of the app, NotifyEmail mails loan notices as well.
+// Users who never set them get emails in English.
+type NotifyPrefs struct {
+	UID     string        `json:"uid,omitempty"`
+	Channel NotifyChannel `json:"channel" validate:"required,oneof=email in_app none"`
+	Locale  string        `json:"locale" validate:"required,oneof=en ru"`
+}
+
+type NoticeKind string
+
+const (
+	NoticeDueSoon NoticeKind = "due_soon"
+	NoticeOverdue NoticeKind = "overdue"
+	// NoticeHoldReady tells a member the copy they held waits for them at the pickup branch.
+	NoticeHoldReady NoticeKind = "hold_ready"
+)
+
+// LoanDue is an open loan due soon or overdue together with what is needed to notify the borrower.
+type LoanDue struct {
+	Loan
+	Email string
+	Lable string
+	Prefs NotifyPrefs
+}
+
+type NoticeStatus string
+
+const (
+	NoticePending NoticeStatus = "pending"
+	NoticeSent    NoticeStatus = "sent"
+	NoticeFailed  NoticeStatus = "failed"
+	NoticeSkipped NoticeStatus = "skipped"
+)
+
+// NoticeLog records a notice about a loan. Stage is zero for the reminder and counts the overdue
+// notices from one, every loan gets at most one notice of a kind and stage.
+type NoticeLog struct {
+	ID        int64         `json:"id"`
+	UID       string        `json:"uid"`
+	LID       string        `json:"lid"`
+	Kind      NoticeKind    `json:"kind"`
+	Stage     int           `json:"stage"`
+	Channel   NotifyChannel `json:"channel"`
+	Status    NoticeStatus  `json:"status"`
+	Attempts  int           `json:"attempts"`
+	Subject   string        `json:"subject,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	SentAt    *time.Time    `json:"sent_at,omitempty"`
+}
+
+// Notification is a message in the inbox of the user in the app. Source names what the notification
+// was made from, e.g. the event or the loan notice, a source makes a single notification.
+type Notification struct {
+	ID        int64      `json:"id"`
+	UID       string     `json:"uid"`
+	Kind      NoticeKind `json:"kind"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Source    string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+}
+
+// Inbox is a page of the notifications of the user, the newest first. Next is the cursor
+// of the following page, empty on the last one.
+type Inbox struct {
+	Unread        int            `json:"unread"`
+	Notifications []Notification `json:"notifications"`
+	Next          string         `json:"next,omitempty"`
+}
+
+// LibraryStats are the counters of the whole library exported as metrics.
+type LibraryStats struct {
+	Titles          int
+	Copies          int
+	AvailableCopies int
+	ActiveLoans     int
+	OverdueLoans    int
+}
+
+// HealthStatus is the result of a readiness check. A degraded instance still serves requests.
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"
+	HealthDegraded HealthStatus = "degraded"
+	HealthFail     HealthStatus = "fail"
+)
+
+type HealthCheck struct {
+	Status    HealthStatus `json:"status"`
+	LatencyMS float64      `json:"latency_ms"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// Health is the readiness of the instance, the worst of its checks.
+type Health struct {
+	Status HealthStatus           `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
 }
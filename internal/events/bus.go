@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+)
+
+// Bus is the in-process sink. Every subscriber gets every event published after it subscribed.
+// Publishing never waits for subscribers: a subscriber with no room for an event is unsubscribed
+// and its channel closed, so it learns that it missed events.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[chan models.Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan models.Event]struct{})}
+}
+
+// Subscribe returns the channel of events and the function that ends the subscription and closes the channel.
+func (b *Bus) Subscribe(buffer int) (<-chan models.Event, func()) {
+	ch := make(chan models.Event, buffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.drop(ch)
+	}
+}
+
+// drop ends the subscription of ch unless it is already ended. b.mu must be held.
+func (b *Bus) drop(ch chan models.Event) {
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+func (b *Bus) Publish(_ context.Context, events []models.Event) error {
+	log := logger.Get()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		for _, event := range events {
+			select {
+			case ch <- event:
+				continue
+			default:
+			}
+			log.Warn().Int64("event", event.ID).Msg("subscriber is too slow, subscription dropped")
+			b.drop(ch)
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Package events relays the domain events written to the outbox to the sinks other systems read them from.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+)
+
+// Sink receives published events in outbox order. A sink which failed is offered its events
+// again on the next runs, and after a restart events not marked published are offered again:
+// consumers must be ready for duplicates.
+type Sink interface {
+	Publish(ctx context.Context, events []models.Event) error
+}
+
+// Source is the outbox the relay reads.
+type Source interface {
+	PendingEvents(limit int) ([]models.Event, error)
+	MarkPublished(ids []int64) error
+}
+
+type Relay struct {
+	source Source
+	sinks  []Sink
+	batch  int
+	// sent is the ID of the last event each sink accepted or was given up on, failures is the
+	// number of failed attempts of each sink to take the events after it.
+	sent     []int64
+	failures []int
+}
+
+func NewRelay(source Source, batch int, sinks ...Sink) *Relay {
+	return &Relay{
+		source:   source,
+		sinks:    sinks,
+		batch:    batch,
+		sent:     make([]int64, len(sinks)),
+		failures: make([]int, len(sinks)),
+	}
+}
+
+// Run publishes the pending events batch by batch until the outbox is drained. Every sink gets
+// an event once: a failing sink keeps its place and is offered the events again on the next run
+// while the others go on. After consts.OutboxSinkAttempts failures the events are dropped for
+// that sink. Events are marked as published once every sink is past them.
+func (r *Relay) Run(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	for ctx.Err() == nil {
+		events, err := r.source.PendingEvents(r.batch)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		var errs []error
+		for i, sink := range r.sinks {
+			todo := slices.DeleteFunc(slices.Clone(events), func(event models.Event) bool { return event.ID <= r.sent[i] })
+			if len(todo) == 0 {
+				continue
+			}
+			last := todo[len(todo)-1].ID
+			if err = sink.Publish(ctx, todo); err != nil {
+				errs = append(errs, fmt.Errorf("%T: %w", sink, err))
+				if r.failures[i]++; r.failures[i] < consts.OutboxSinkAttempts {
+					continue
+				}
+				log.Error().Err(err).Str("sink", fmt.Sprintf("%T", sink)).Int64("from", todo[0].ID).Int64("to", last).
+					Msg("sink failed too many times, its events are dropped")
+			}
+			r.failures[i] = 0
+			r.sent[i] = last
+		}
+		done := slices.Min(r.sent)
+		ids := make([]int64, 0, len(events))
+		for _, event := range events {
+			if event.ID <= done {
+				ids = append(ids, event.ID)
+			}
+		}
+		if len(ids) > 0 {
+			if err = r.source.MarkPublished(ids); err != nil {
+				return err
+			}
+		}
+		if err = errors.Join(errs...); err != nil {
+			return err
+		}
+		if len(events) < r.batch {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,112 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memSource struct {
+	events    []models.Event
+	published []int64
+}
+
+func (m *memSource) PendingEvents(limit int) ([]models.Event, error) {
+	return m.events[:min(limit, len(m.events))], nil
+}
+
+func (m *memSource) MarkPublished(ids []int64) error {
+	m.published = append(m.published, ids...)
+	m.events = m.events[len(ids):]
+	return nil
+}
+
+type failSink struct{}
+
+func (failSink) Publish(context.Context, []models.Event) error { return errors.New("sink is down") }
+
+func testEvents(n int) []models.Event {
+	events := make([]models.Event, 0, n)
+	for i := 1; i <= n; i++ {
+		events = append(events, models.Event{
+			ID:      int64(i),
+			Type:    models.EventBookAdded,
+			Subject: "BID",
+			Data:    json.RawMessage(`{"bid":"BID"}`),
+		})
+	}
+	return events
+}
+
+func TestRelay(t *testing.T) {
+	logger.Get(false)
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	bus := NewBus()
+	sub, cancel := bus.Subscribe(10)
+	defer cancel()
+	source := &memSource{events: testEvents(5)}
+
+	require.NoError(t, NewRelay(source, 2, bus, NewFileSink(path)).Run(context.Background()))
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, source.published)
+	assert.Len(t, sub, 5)
+	assert.Equal(t, int64(1), (<-sub).ID)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event models.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		lines++
+		assert.Equal(t, int64(lines), event.ID)
+	}
+	assert.Equal(t, 5, lines)
+}
+
+func TestRelayKeepsEventsWhenSinkFails(t *testing.T) {
+	logger.Get(false)
+	bus := NewBus()
+	sub, cancel := bus.Subscribe(10)
+	defer cancel()
+	source := &memSource{events: testEvents(3)}
+	relay := NewRelay(source, 10, bus, failSink{})
+
+	for range consts.OutboxSinkAttempts - 1 {
+		err := relay.Run(context.Background())
+		assert.ErrorContains(t, err, "sink is down")
+		assert.Empty(t, source.published)
+		assert.Len(t, source.events, 3)
+	}
+	assert.Len(t, sub, 3)
+
+	assert.ErrorContains(t, relay.Run(context.Background()), "sink is down")
+	assert.Equal(t, []int64{1, 2, 3}, source.published)
+	assert.Len(t, sub, 3)
+}
+
+func TestBusDropsSlowSubscriber(t *testing.T) {
+	logger.Get(false)
+	bus := NewBus()
+	sub, cancel := bus.Subscribe(1)
+	require.NoError(t, bus.Publish(context.Background(), testEvents(3)))
+	assert.Len(t, sub, 1)
+	_, open := <-sub
+	assert.True(t, open)
+	_, open = <-sub
+	assert.False(t, open)
+	cancel()
+	cancel()
+	require.NoError(t, bus.Publish(context.Background(), testEvents(1)))
+}
@@ -0,0 +1,34 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// FileSink appends events to a file as NDJSON, one event per line. The file is opened
+// for every batch, so it can be rotated or removed while the server runs.
+type FileSink struct {
+	path string
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+func (f *FileSink) Publish(_ context.Context, events []models.Event) error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644) //nolint:mnd //file mode
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	for _, event := range events {
+		if err = enc.Encode(event); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
@@ -1,43 +1,147 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"sync"
 
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
 )
 
 var once sync.Once //nolin:gochecknoglobals //singletone
 
 var log zerolog.Logger //nolin:gochecknoglobals //singletone
 
+// Options set up the global logger. Level is the initial level, it can be changed at runtime
+// with SetLevel. Format is "json" or "console". File is where the lines go, stdout when empty;
+// it is rotated when it grows over MaxSizeMB, keeping MaxBackups old files for MaxAgeDays.
+type Options struct {
+	Level      string
+	Format     string
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+}
+
+// Get returns the global logger. Without Setup the first call sets it up: with flags[0] at the
+// debug level on the console, otherwise at the info level as JSON.
 func Get(flags ...bool) zerolog.Logger {
 	once.Do(func() {
-		zerolog.TimestampFieldName = "Time"
-		zerolog.LevelFieldName = "Level"
-		zerolog.CallerMarshalFunc = func(_ uintptr, file string, line int) string {
-			short := file
-			for i := len(file) - 1; i > 0; i-- {
-				if file[i] == '/' {
-					short = file[i+1:]
-					break
-				}
-			}
-			file = short
-			return file + ":" + strconv.Itoa(line)
-		}
-		if flags[0] {
-			log = zerolog.New(os.Stdout).
-				Level(zerolog.DebugLevel).
-				With().
-				Timestamp().
-				Caller().
-				Logger().
-				Output(zerolog.ConsoleWriter{Out: os.Stderr})
-		} else {
-			log = zerolog.New(os.Stdout).Level(zerolog.InfoLevel).With().Timestamp().Caller().Logger()
+		opts := Options{Level: zerolog.InfoLevel.String(), Format: FormatJSON}
+		if len(flags) > 0 && flags[0] {
+			opts = Options{Level: zerolog.DebugLevel.String(), Format: FormatConsole}
 		}
+		log, _, _ = build(opts)
 	})
 	return log
 }
+
+// Setup sets up the global logger, it has to be called before the first Get.
+// The returned closer closes the log file.
+func Setup(opts Options) (io.Closer, error) {
+	var closer io.Closer = io.NopCloser(nil)
+	var err error
+	once.Do(func() {
+		log, closer, err = build(opts)
+	})
+	return closer, err
+}
+
+func build(opts Options) (zerolog.Logger, io.Closer, error) {
+	zerolog.TimestampFieldName = "Time"
+	zerolog.LevelFieldName = "Level"
+	zerolog.CallerMarshalFunc = func(_ uintptr, file string, line int) string {
+		short := file
+		for i := len(file) - 1; i > 0; i-- {
+			if file[i] == '/' {
+				short = file[i+1:]
+				break
+			}
+		}
+		file = short
+		return file + ":" + strconv.Itoa(line)
+	}
+	if err := SetLevel(opts.Level); err != nil {
+		return zerolog.Logger{}, nil, err
+	}
+	var out io.Writer = os.Stdout
+	var closer io.Closer = io.NopCloser(nil)
+	if opts.File != "" {
+		file := &lumberjack.Logger{
+			Filename:   opts.File,
+			MaxSize:    opts.MaxSizeMB,
+			MaxBackups: opts.MaxBackups,
+			MaxAge:     opts.MaxAgeDays,
+		}
+		out, closer = file, file
+	}
+	switch opts.Format {
+	case FormatJSON, "":
+	case FormatConsole:
+		if opts.File == "" {
+			out = os.Stderr
+		}
+		out = zerolog.ConsoleWriter{Out: out, NoColor: opts.File != ""}
+	default:
+		return zerolog.Logger{}, nil, fmt.Errorf("unknown log format %q", opts.Format)
+	}
+	l := zerolog.New(redactor{out: out}).With().Timestamp().Caller().Logger().Hook(traceHook{})
+	return l, closer, nil
+}
+
+// SetLevel changes the level of every logger, e.g. "debug" or "warn".
+func SetLevel(level string) error {
+	lvl, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	if lvl == zerolog.NoLevel {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	zerolog.SetGlobalLevel(lvl)
+	return nil
+}
+
+// Level returns the current level.
+func Level() string {
+	return zerolog.GlobalLevel().String()
+}
+
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx carrying l, see FromContext.
+func WithContext(ctx context.Context, l zerolog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger put into ctx by WithContext, usually the one of the request
+// with its ID, route and user. Without one it is the global logger.
+func FromContext(ctx context.Context) zerolog.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(zerolog.Logger); ok {
+		return l
+	}
+	return Get()
+}
+
+// traceHook adds the trace and span IDs of the span in the event context, so that the lines
+// logged with Ctx can be found from a trace and the other way around.
+type traceHook struct{}
+
+func (traceHook) Run(e *zerolog.Event, _ zerolog.Level, _ string) {
+	span := trace.SpanContextFromContext(e.GetCtx())
+	if !span.IsValid() {
+		return
+	}
+	e.Str("trace_id", span.TraceID().String()).Str("span_id", span.SpanID().String())
+}
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceHook(t *testing.T) {
+	var buf bytes.Buffer
+	log := zerolog.New(&buf).Hook(traceHook{})
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	log.Info().Ctx(ctx).Msg("traced")
+	assert.JSONEq(t,
+		`{"level":"info","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736","span_id":"00f067aa0ba902b7","message":"traced"}`,
+		buf.String())
+
+	buf.Reset()
+	log.Info().Msg("untraced")
+	assert.JSONEq(t, `{"level":"info","message":"untraced"}`, buf.String())
+}
@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are parts of the field names whose values never get into the log, whatever they hold.
+var sensitiveKeys = []string{ //nolint:gochecknoglobals //constant set
+	"pass", "hash", "token", "secret", "authorization", "accesskey",
+}
+
+var (
+	emailRe  = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	jwtRe    = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`)
+	bcryptRe = regexp.MustCompile(`\$2[abxy]?\$\d{2}\$[./A-Za-z0-9]{53}`)
+	// urlPassRe finds the password in the user info of a URL, like the one of the database DSN.
+	urlPassRe = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.\-]*://[^:/@\s]*:)[^@\s]+@`)
+)
+
+// redactor scrubs passwords, hashes, tokens and emails from the log lines before they are written.
+// Fields named like a secret lose their values, the values of the other fields are searched for
+// emails, JWTs, bcrypt hashes and passwords in URLs.
+type redactor struct {
+	out io.Writer
+}
+
+func (r redactor) Write(p []byte) (int, error) {
+	if !suspicious(p) {
+		return r.out.Write(p)
+	}
+	var line map[string]any
+	dec := json.NewDecoder(bytes.NewReader(p))
+	dec.UseNumber()
+	if err := dec.Decode(&line); err != nil {
+		if _, err = r.out.Write([]byte(scrub(string(p)))); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redact(line)); err != nil {
+		return 0, err
+	}
+	if _, err := r.out.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// suspicious tells cheaply whether the line may hold something to scrub, most lines do not.
+func suspicious(p []byte) bool {
+	lower := bytes.ToLower(p)
+	for _, key := range sensitiveKeys {
+		if bytes.Contains(lower, []byte(key)) {
+			return true
+		}
+	}
+	return bytes.ContainsAny(p, "@$") || bytes.Contains(p, []byte("eyJ"))
+}
+
+func redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if sensitiveKey(key) {
+				if value != nil && value != "" {
+					v[key] = redacted
+				}
+				continue
+			}
+			v[key] = redact(value)
+		}
+		return v
+	case []any:
+		for i, value := range v {
+			v[i] = redact(value)
+		}
+		return v
+	case string:
+		return scrub(v)
+	default:
+		return v
+	}
+}
+
+func sensitiveKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, part := range sensitiveKeys {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrub masks the secrets found in s. Emails keep their domain.
+func scrub(s string) string {
+	s = jwtRe.ReplaceAllString(s, redacted)
+	s = bcryptRe.ReplaceAllString(s, redacted)
+	s = urlPassRe.ReplaceAllString(s, "${1}"+redacted+"@")
+	return emailRe.ReplaceAllString(s, "***@$1")
+}
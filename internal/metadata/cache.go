@@ -0,0 +1,72 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+type cacheEntry struct {
+	draft   models.BookDraft
+	err     error
+	expires time.Time
+}
+
+// Cache keeps lookup results in memory. Misses are remembered for a shorter time than hits,
+// provider failures are not cached. When full, the entry closest to expiry is dropped.
+type Cache struct {
+	provider MetadataProvider
+	ttl      time.Duration
+	missTTL  time.Duration
+	size     int
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func NewCache(provider MetadataProvider, ttl time.Duration, size int) *Cache {
+	return &Cache{
+		provider: provider,
+		ttl:      ttl,
+		missTTL:  ttl / 10, //nolint:mnd //misses are retried sooner
+		size:     size,
+		now:      time.Now,
+		entries:  make(map[string]cacheEntry),
+	}
+}
+
+func (c *Cache) Lookup(ctx context.Context, isbn string) (models.BookDraft, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[isbn]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.draft, entry.err
+	}
+	draft, err := c.provider.Lookup(ctx, isbn)
+	switch {
+	case err == nil:
+		c.put(isbn, cacheEntry{draft: draft, expires: c.now().Add(c.ttl)})
+	case errors.Is(err, ErrNotFound):
+		c.put(isbn, cacheEntry{err: err, expires: c.now().Add(c.missTTL)})
+	}
+	return draft, err
+}
+
+func (c *Cache) put(isbn string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[isbn]; !ok && len(c.entries) >= c.size {
+		var oldest string
+		for key, e := range c.entries {
+			if oldest == "" || e.expires.Before(c.entries[oldest].expires) {
+				oldest = key
+			}
+		}
+		delete(c.entries, oldest)
+	}
+	c.entries[isbn] = entry
+}
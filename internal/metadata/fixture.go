@@ -0,0 +1,53 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+const fixtureSource = "fixture"
+
+// Fixture reads drafts from <ISBN-13>.json files of a directory. It serves offline installations and tests.
+type Fixture struct {
+	fsys fs.FS
+}
+
+func NewFixture(dir string) (*Fixture, error) {
+	st, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !st.IsDir() {
+		return nil, errors.New(dir + " is not a directory")
+	}
+	return &Fixture{fsys: os.DirFS(dir)}, nil
+}
+
+// NewFixtureFS is NewFixture over any file system, e.g. an embedded one.
+func NewFixtureFS(fsys fs.FS) *Fixture {
+	return &Fixture{fsys: fsys}
+}
+
+func (f *Fixture) Lookup(_ context.Context, isbn string) (models.BookDraft, error) {
+	data, err := fs.ReadFile(f.fsys, isbn+".json")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return models.BookDraft{}, ErrNotFound
+		}
+		return models.BookDraft{}, err
+	}
+	var draft models.BookDraft
+	if err = json.Unmarshal(data, &draft); err != nil {
+		return models.BookDraft{}, err
+	}
+	draft.ISBN = isbn
+	if draft.Source == "" {
+		draft.Source = fixtureSource
+	}
+	return draft, nil
+}
@@ -0,0 +1,86 @@
+// Package metadata looks up book data by ISBN in external catalogs.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+var (
+	ErrNotFound    = errors.New("no book found for this isbn")
+	ErrInvalidISBN = errors.New("invalid isbn")
+)
+
+type MetadataProvider interface {
+	// Lookup returns the draft of the book with the normalized ISBN-13 or ErrNotFound.
+	Lookup(ctx context.Context, isbn string) (models.BookDraft, error)
+}
+
+// NormalizeISBN strips hyphens and spaces, checks the check digit and converts ISBN-10 to ISBN-13.
+func NormalizeISBN(raw string) (string, error) {
+	isbn := strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw)))
+	switch len(isbn) {
+	case 10:
+		if !validISBN10(isbn) {
+			return "", ErrInvalidISBN
+		}
+		isbn13 := "978" + isbn[:9]
+		return isbn13 + string(isbn13CheckDigit(isbn13)), nil
+	case 13:
+		if !allDigits(isbn) || isbn13CheckDigit(isbn[:12]) != isbn[12] {
+			return "", ErrInvalidISBN
+		}
+		return isbn, nil
+	default:
+		return "", ErrInvalidISBN
+	}
+}
+
+func validISBN10(isbn string) bool {
+	if !allDigits(isbn[:9]) {
+		return false
+	}
+	sum := 0
+	for i := range 9 {
+		sum += int(isbn[i]-'0') * (10 - i)
+	}
+	switch c := isbn[9]; {
+	case c == 'X':
+		sum += 10
+	case c >= '0' && c <= '9':
+		sum += int(c - '0')
+	default:
+		return false
+	}
+	return sum%11 == 0
+}
+
+// isbn13CheckDigit computes the check digit for the first 12 digits of an ISBN-13.
+func isbn13CheckDigit(digits string) byte {
+	sum := 0
+	for i := range 12 {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func allDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
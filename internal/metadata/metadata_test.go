@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		err  error
+	}{
+		{raw: "978-0-14-044793-4", want: "9780140447934"},
+		{raw: "0-14-044793-X", err: ErrInvalidISBN},
+		{raw: "0140447938", want: "9780140447934"},
+		{raw: "080442957X", want: "9780804429573"},
+		{raw: "9780140447935", err: ErrInvalidISBN},
+		{raw: "12345", err: ErrInvalidISBN},
+	}
+	for _, tc := range tests {
+		t.Run(tc.raw, func(t *testing.T) {
+			isbn, err := NormalizeISBN(tc.raw)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.want, isbn)
+		})
+	}
+}
+
+func TestOpenLibrary(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/books", r.URL.Path)
+		assert.Equal(t, "data", r.URL.Query().Get("jscmd"))
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("bibkeys") != "ISBN:9780140447934" {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ISBN:9780140447934": {
+			"title": "War and Peace", "subtitle": "Volume one",
+			"authors": [{"name": "Leo Tolstoy"}, {"name": "Rosemary Edmonds"}],
+			"subjects": [{"name": "Historical fiction"}, {"name": "Napoleonic Wars"}],
+			"notes": {"type": "/type/text", "value": "Translated from the Russian"},
+			"cover": {"medium": "https://covers.example/b/id/1-M.jpg", "large": "https://covers.example/b/id/1-L.jpg"}
+		}}`))
+	}))
+	defer srv.Close()
+	ol := NewOpenLibrary(srv.URL)
+
+	draft, err := ol.Lookup(context.Background(), "9780140447934")
+	require.NoError(t, err)
+	assert.Equal(t, models.BookDraft{
+		ISBN:     "9780140447934",
+		Lable:    "War and Peace: Volume one",
+		Author:   "Leo Tolstoy, Rosemary Edmonds",
+		Desc:     "Translated from the Russian",
+		Genre:    "Historical fiction",
+		CoverURL: "https://covers.example/b/id/1-L.jpg",
+		Source:   "openlibrary",
+	}, draft)
+
+	_, err = ol.Lookup(context.Background(), "9780804429573")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFixture(t *testing.T) {
+	f := NewFixtureFS(fstest.MapFS{
+		"9780140447934.json": {Data: []byte(`{"lable":"War and Peace","author":"Leo Tolstoy","desc":"Epic"}`)},
+	})
+	draft, err := f.Lookup(context.Background(), "9780140447934")
+	require.NoError(t, err)
+	assert.Equal(t, models.BookDraft{
+		ISBN: "9780140447934", Lable: "War and Peace", Author: "Leo Tolstoy", Desc: "Epic", Source: "fixture",
+	}, draft)
+	_, err = f.Lookup(context.Background(), "9780804429573")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+type countingProvider struct {
+	calls int
+	draft models.BookDraft
+	err   error
+}
+
+func (p *countingProvider) Lookup(_ context.Context, isbn string) (models.BookDraft, error) {
+	p.calls++
+	if p.err != nil {
+		return models.BookDraft{}, p.err
+	}
+	d := p.draft
+	d.ISBN = isbn
+	return d, nil
+}
+
+func TestCache(t *testing.T) {
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	provider := &countingProvider{draft: models.BookDraft{Lable: "War and Peace"}}
+	cache := NewCache(provider, time.Hour, 2)
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for range 3 {
+		draft, err := cache.Lookup(ctx, "1")
+		require.NoError(t, err)
+		assert.Equal(t, "War and Peace", draft.Lable)
+	}
+	assert.Equal(t, 1, provider.calls)
+
+	now = now.Add(2 * time.Hour)
+	_, _ = cache.Lookup(ctx, "1")
+	assert.Equal(t, 2, provider.calls)
+
+	// Size is bounded, the entry closest to expiry is evicted.
+	now = now.Add(time.Minute)
+	_, _ = cache.Lookup(ctx, "2")
+	_, _ = cache.Lookup(ctx, "3")
+	assert.Len(t, cache.entries, 2)
+	assert.NotContains(t, cache.entries, "1")
+
+	provider.err = ErrNotFound
+	_, err := cache.Lookup(ctx, "4")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = cache.Lookup(ctx, "4")
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 5, provider.calls)
+}
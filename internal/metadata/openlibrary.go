@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/tracing"
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	DefaultOpenLibraryURL = "https://openlibrary.org"
+	openLibrarySource     = "openlibrary"
+	openLibraryTimeout    = 10 * time.Second
+)
+
+// OpenLibrary queries the Open Library books API (/api/books with jscmd=data) or a service with the same API.
+type OpenLibrary struct {
+	client *resty.Client
+}
+
+func NewOpenLibrary(baseURL string) *OpenLibrary {
+	client := resty.New().
+		SetTransport(tracing.Transport(nil)).
+		SetBaseURL(strings.TrimSuffix(baseURL, "/")).
+		SetTimeout(openLibraryTimeout).
+		SetHeader("Accept", "application/json").
+		SetHeader("User-Agent", "bookly")
+	return &OpenLibrary{client: client}
+}
+
+type olName struct {
+	Name string `json:"name"`
+}
+
+// olText is a note that comes either as a plain string or as {"type": ..., "value": ...}.
+type olText string
+
+func (t *olText) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = olText(s)
+		return nil
+	}
+	var obj struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*t = olText(obj.Value)
+	return nil
+}
+
+type olBook struct {
+	Title    string   `json:"title"`
+	Subtitle string   `json:"subtitle"`
+	Authors  []olName `json:"authors"`
+	Subjects []olName `json:"subjects"`
+	Notes    olText   `json:"notes"`
+	Excerpts []struct {
+		Text string `json:"text"`
+	} `json:"excerpts"`
+	Cover struct {
+		Small  string `json:"small"`
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"cover"`
+}
+
+func (ol *OpenLibrary) Lookup(ctx context.Context, isbn string) (models.BookDraft, error) {
+	key := "ISBN:" + isbn
+	var result map[string]olBook
+	resp, err := ol.client.R().
+		SetContext(ctx).
+		SetQueryParams(map[string]string{"bibkeys": key, "format": "json", "jscmd": "data"}).
+		SetResult(&result).
+		Get("/api/books")
+	if err != nil {
+		return models.BookDraft{}, err
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return models.BookDraft{}, ErrNotFound
+	}
+	if resp.IsError() {
+		return models.BookDraft{}, fmt.Errorf("open library responded %s", resp.Status())
+	}
+	book, ok := result[key]
+	if !ok {
+		return models.BookDraft{}, ErrNotFound
+	}
+	draft := models.BookDraft{
+		ISBN:     isbn,
+		Lable:    book.Title,
+		Desc:     string(book.Notes),
+		CoverURL: book.Cover.Large,
+		Source:   openLibrarySource,
+	}
+	if book.Subtitle != "" {
+		draft.Lable += ": " + book.Subtitle
+	}
+	names := make([]string, 0, len(book.Authors))
+	for _, author := range book.Authors {
+		names = append(names, author.Name)
+	}
+	draft.Author = strings.Join(names, ", ")
+	if draft.Desc == "" && len(book.Excerpts) > 0 {
+		draft.Desc = book.Excerpts[0].Text
+	}
+	if len(book.Subjects) > 0 {
+		draft.Genre = book.Subjects[0].Name
+	}
+	if draft.CoverURL == "" {
+		draft.CoverURL = book.Cover.Medium
+	}
+	return draft, nil
+}
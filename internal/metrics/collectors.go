@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func desc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, nil, nil)
+}
+
+// PoolCollector exports the statistics of the pgx connection pool.
+type PoolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquired, idle, constructing, total, max  *prometheus.Desc
+	acquires, emptyAcquires, canceledAcquires *prometheus.Desc
+	acquireDuration                           *prometheus.Desc
+}
+
+func NewPoolCollector(stat func() *pgxpool.Stat) *PoolCollector {
+	return &PoolCollector{
+		stat:             stat,
+		acquired:         desc("db_pool_acquired_conns", "Connections in use."),
+		idle:             desc("db_pool_idle_conns", "Idle connections."),
+		constructing:     desc("db_pool_constructing_conns", "Connections being opened."),
+		total:            desc("db_pool_total_conns", "Open connections."),
+		max:              desc("db_pool_max_conns", "Largest size of the pool."),
+		acquires:         desc("db_pool_acquires_total", "Connections taken from the pool."),
+		emptyAcquires:    desc("db_pool_empty_acquires_total", "Acquires which waited because the pool was empty."),
+		canceledAcquires: desc("db_pool_canceled_acquires_total", "Acquires canceled before a connection was got."),
+		acquireDuration:  desc("db_pool_acquire_duration_seconds_total", "Time spent waiting for connections."),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.acquired, c.idle, c.constructing, c.total, c.max,
+		c.acquires, c.emptyAcquires, c.canceledAcquires, c.acquireDuration} {
+		ch <- d
+	}
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.constructing, prometheus.GaugeValue, float64(stat.ConstructingConns()))
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.canceledAcquires, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+}
+
+// LibraryCollector exports the counters of the library. They are read from the storage on every scrape,
+// when reading fails the scrape goes without them.
+type LibraryCollector struct {
+	stats func() (models.LibraryStats, error)
+
+	titles, copies, available, loans, overdue *prometheus.Desc
+}
+
+func NewLibraryCollector(stats func() (models.LibraryStats, error)) *LibraryCollector {
+	return &LibraryCollector{
+		stats:     stats,
+		titles:    desc("titles", "Books in the catalog."),
+		copies:    desc("copies", "Copies of the books which are not lost."),
+		available: desc("available_copies", "Copies on the shelves ready to be lent."),
+		loans:     desc("active_loans", "Copies lent and not returned yet."),
+		overdue:   desc("overdue_loans", "Active loans past their due date."),
+	}
+}
+
+func (c *LibraryCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.titles, c.copies, c.available, c.loans, c.overdue} {
+		ch <- d
+	}
+}
+
+func (c *LibraryCollector) Collect(ch chan<- prometheus.Metric) {
+	log := logger.Get()
+	stats, err := c.stats()
+	if err != nil {
+		log.Error().Err(err).Msg("collect library stats failed")
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.titles, prometheus.GaugeValue, float64(stats.Titles))
+	ch <- prometheus.MustNewConstMetric(c.copies, prometheus.GaugeValue, float64(stats.Copies))
+	ch <- prometheus.MustNewConstMetric(c.available, prometheus.GaugeValue, float64(stats.AvailableCopies))
+	ch <- prometheus.MustNewConstMetric(c.loans, prometheus.GaugeValue, float64(stats.ActiveLoans))
+	ch <- prometheus.MustNewConstMetric(c.overdue, prometheus.GaugeValue, float64(stats.OverdueLoans))
+}
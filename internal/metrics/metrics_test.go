@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMiddleware(t *testing.T) {
+	m := New()
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/books/:id", func(ctx *gin.Context) { ctx.String(http.StatusOK, ctx.Param("id")) })
+	for _, path := range []string{"/books/1", "/books/2", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `bookly_http_requests_total{method="GET",route="/books/:id",status="200"} 2`)
+	assert.Contains(t, body, `bookly_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `bookly_http_request_duration_seconds_count{method="GET",route="/books/:id",status="200"} 2`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestObserve(t *testing.T) {
+	m := New()
+	m.ObserveStorage("GetBook", time.Now(), nil)
+	m.ObserveStorage("GetBook", time.Now(), errors.New("connection refused"))
+	m.ObserveJob("purge-books", time.Second, nil)
+	m.ObserveJob("purge-books", time.Second, errors.New("connection refused"))
+	m.ObserveJob("purge-books", time.Second, nil)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `bookly_storage_duration_seconds_count{method="GetBook"} 2`)
+	assert.Contains(t, body, `bookly_storage_errors_total{method="GetBook"} 1`)
+	assert.Contains(t, body, `bookly_job_runs_total{job="purge-books",result="success"} 2`)
+	assert.Contains(t, body, `bookly_job_runs_total{job="purge-books",result="failure"} 1`)
+	assert.Contains(t, body, `bookly_job_duration_seconds_sum{job="purge-books"} 3`)
+}
+
+func TestLibraryCollector(t *testing.T) {
+	logger.Get(false)
+	m := New()
+	var err error
+	m.MustRegister(NewLibraryCollector(func() (models.LibraryStats, error) {
+		return models.LibraryStats{Titles: 3, Copies: 7, AvailableCopies: 4, ActiveLoans: 3, OverdueLoans: 1}, err
+	}))
+
+	body := scrape(t, m)
+	assert.Contains(t, body, "bookly_titles 3")
+	assert.Contains(t, body, "bookly_copies 7")
+	assert.Contains(t, body, "bookly_available_copies 4")
+	assert.Contains(t, body, "bookly_active_loans 3")
+	assert.Contains(t, body, "bookly_overdue_loans 1")
+
+	err = errors.New("connection refused")
+	body = scrape(t, m)
+	assert.NotContains(t, body, "bookly_titles", "a failed read leaves the gauges out")
+	assert.Contains(t, body, "go_goroutines")
+}
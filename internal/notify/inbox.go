@@ -0,0 +1,83 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+// InboxStore is what the inbox needs to turn events into notifications.
+type InboxStore interface {
+	GetBook(string) (models.Book, error)
+	GetBranches() ([]models.Branch, error)
+	GetNotifyPrefs(uid string) (models.NotifyPrefs, error)
+	SaveNotification(models.Notification) error
+}
+
+// Inbox is the events.Sink putting notifications about ready holds into the inboxes of their members.
+// An event redelivered by the relay makes no second notification.
+type Inbox struct {
+	store InboxStore
+}
+
+func NewInbox(store InboxStore) *Inbox {
+	return &Inbox{store: store}
+}
+
+func (i *Inbox) Publish(_ context.Context, events []models.Event) error {
+	for _, event := range events {
+		if event.Type != models.EventHoldReady {
+			continue
+		}
+		if err := i.holdReady(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *Inbox) holdReady(event models.Event) error {
+	var hold models.Hold
+	if err := json.Unmarshal(event.Data, &hold); err != nil {
+		return err
+	}
+	prefs, err := i.store.GetNotifyPrefs(hold.UID)
+	if errors.Is(err, storerrros.ErrUserNotFound) || prefs.Channel == models.NotifyNone {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	book, err := i.store.GetBook(hold.BID)
+	if errors.Is(err, storerrros.ErrBookNoExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	data := noticeData{Lable: book.Lable, Branch: hold.PickupBranch}
+	branches, err := i.store.GetBranches()
+	if err != nil {
+		return err
+	}
+	for _, branch := range branches {
+		if branch.ID == hold.PickupBranch {
+			data.Branch = branch.Name
+		}
+	}
+	mail, err := noticeTemplates.render(prefs.Locale, models.NoticeHoldReady, data)
+	if err != nil {
+		return err
+	}
+	return i.store.SaveNotification(models.Notification{
+		UID:    hold.UID,
+		Kind:   models.NoticeHoldReady,
+		Title:  mail.Subject,
+		Body:   mail.Text,
+		Source: "event:" + strconv.FormatInt(event.ID, 10),
+	})
+}
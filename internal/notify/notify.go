@@ -0,0 +1,151 @@
+// Package notify reminds members of loans coming due and sends them escalating notices once
+// the loans are overdue, by email or in the app as each member prefers.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+// Store keeps the loans and the log of the notices sent about them.
+type Store interface {
+	LoansDue(before time.Time) ([]models.LoanDue, error)
+	ClaimNotice(models.NoticeLog) (models.NoticeLog, error)
+	FinishNotice(id int64, status models.NoticeStatus, errText string) error
+	SaveNotification(models.Notification) error
+}
+
+// Config sets when notices are sent: a reminder RemindBefore the due date and an overdue notice
+// as each of OverdueAfter passes after it. Loans are checked every Interval, zero turns notices off.
+type Config struct {
+	Interval     time.Duration
+	RemindBefore time.Duration
+	OverdueAfter []time.Duration
+}
+
+// Notifier sends the notices. Every notice is claimed in the log before it is sent, so a loan
+// gets each notice once however many notifiers run. When a loan misses some overdue stages,
+// e.g. while the service was down, only the latest one is sent.
+type Notifier struct {
+	store  Store
+	mailer Mailer
+	cfg    Config
+}
+
+// New returns the notifier. With a nil mailer the notices of members who chose email are skipped.
+func New(store Store, mailer Mailer, cfg Config) *Notifier {
+	return &Notifier{store: store, mailer: mailer, cfg: cfg}
+}
+
+// Run sends the notices every interval until the context is done.
+func (n *Notifier) Run(ctx context.Context) error {
+	log := logger.Get()
+	if n.cfg.Interval <= 0 {
+		log.Debug().Msg("loan notices are turned off")
+		<-ctx.Done()
+		return nil
+	}
+	defer log.Debug().Msg("notifier was ended")
+	ticker := time.NewTicker(n.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := n.Notify(ctx, time.Now()); err != nil {
+			log.Error().Err(err).Msg("send loan notices failed")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Notify sends the notices due at the time.
+func (n *Notifier) Notify(ctx context.Context, now time.Time) error {
+	loans, err := n.store.LoansDue(now.Add(n.cfg.RemindBefore))
+	if err != nil {
+		return err
+	}
+	for _, loan := range loans {
+		if ctx.Err() != nil {
+			return nil
+		}
+		kind, stage, ok := n.stage(loan.DueAt, now)
+		if !ok || loan.Prefs.Channel == models.NotifyNone {
+			continue
+		}
+		if err = n.send(ctx, loan, kind, stage, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stage tells which notice the loan due at the time is up for: the reminder before the due date,
+// afterwards the overdue notice of the latest passed stage.
+func (n *Notifier) stage(due, now time.Time) (models.NoticeKind, int, bool) {
+	late := now.Sub(due)
+	if late < 0 {
+		return models.NoticeDueSoon, 0, -late <= n.cfg.RemindBefore
+	}
+	stage := 0
+	for _, after := range n.cfg.OverdueAfter {
+		if late >= after {
+			stage++
+		}
+	}
+	return models.NoticeOverdue, stage, stage > 0
+}
+
+// send claims, renders and sends one notice. The notice always lands in the inbox, members who chose
+// email get it mailed as well. Only a failure to record it is returned, a notice which could not be
+// sent is logged as failed and tried again by the next runs.
+func (n *Notifier) send(ctx context.Context, loan models.LoanDue, kind models.NoticeKind, stage int, now time.Time) error {
+	log := logger.Get().With().Str("lid", loan.LID).Str("kind", string(kind)).Int("stage", stage).Logger()
+	mail, err := noticeTemplates.render(loan.Prefs.Locale, kind, newNoticeData(loan, stage, len(n.cfg.OverdueAfter), now))
+	if err != nil {
+		log.Error().Err(err).Msg("render notice failed")
+		return nil
+	}
+	mail.To = loan.Email
+	notice, err := n.store.ClaimNotice(models.NoticeLog{
+		UID:     loan.UID,
+		LID:     loan.LID,
+		Kind:    kind,
+		Stage:   stage,
+		Channel: loan.Prefs.Channel,
+		Subject: mail.Subject,
+	})
+	if err != nil {
+		if errors.Is(err, storerrros.ErrNoticeExists) {
+			return nil
+		}
+		return err
+	}
+	status, errText := models.NoticeSent, ""
+	err = n.store.SaveNotification(models.Notification{
+		UID:    loan.UID,
+		Kind:   kind,
+		Title:  mail.Subject,
+		Body:   mail.Text,
+		Source: fmt.Sprintf("notice:%s:%s:%d", loan.LID, kind, stage),
+	})
+	if err == nil && loan.Prefs.Channel == models.NotifyEmail {
+		if n.mailer == nil {
+			status, errText = models.NoticeSkipped, "email is not configured"
+		} else {
+			err = n.mailer.Send(ctx, mail)
+		}
+	}
+	if err != nil {
+		log.Warn().Err(err).Msg("send notice failed")
+		status, errText = models.NoticeFailed, err.Error()
+	}
+	return n.store.FinishNotice(notice.ID, status, errText)
+}
@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMailer keeps the sent mails, failing the first fails sends.
+type fakeMailer struct {
+	mu    sync.Mutex
+	fails int
+	mails []Mail
+}
+
+func (m *fakeMailer) Send(_ context.Context, mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fails > 0 {
+		m.fails--
+		return errors.New("mailbox unavailable")
+	}
+	m.mails = append(m.mails, mail)
+	return nil
+}
+
+var testConfig = Config{
+	Interval:     time.Minute,
+	RemindBefore: 48 * time.Hour,
+	OverdueAfter: []time.Duration{24 * time.Hour, 7 * 24 * time.Hour, 14 * 24 * time.Hour},
+}
+
+// setup lends a copy of a book to a new member and returns the loan.
+func setup(t *testing.T, prefs *models.NotifyPrefs) (*storage.MemStorage, models.Loan) {
+	t.Helper()
+	logger.Get(false)
+	stor := storage.New()
+	uid, err := stor.SaveUser(models.User{Email: "reader@bookly.ru", Pass: "password", Age: 20}, models.AuditEntry{})
+	require.NoError(t, err)
+	if prefs != nil {
+		prefs.UID = uid
+		require.NoError(t, stor.SetNotifyPrefs(*prefs))
+	}
+	require.NoError(t, stor.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", Desc: "Desert planet", Age: 12},
+		models.AuditEntry{}))
+	book, err := stor.FindBook("Dune", "Frank Herbert")
+	require.NoError(t, err)
+	items, err := stor.GetItems(book.BID)
+	require.NoError(t, err)
+	loan, err := stor.CheckoutItem(items[0].Barcode, uid, 14*24*time.Hour)
+	require.NoError(t, err)
+	return stor, loan
+}
+
+func TestNotifyEmail(t *testing.T) {
+	stor, loan := setup(t, nil)
+	mailer := &fakeMailer{}
+	n := New(stor, mailer, testConfig)
+	ctx := context.Background()
+
+	require.NoError(t, n.Notify(ctx, loan.DueAt.Add(-72*time.Hour)))
+	assert.Empty(t, mailer.mails, "too early for a reminder")
+
+	require.NoError(t, n.Notify(ctx, loan.DueAt.Add(-24*time.Hour)))
+	require.NoError(t, n.Notify(ctx, loan.DueAt.Add(-23*time.Hour)))
+	require.Len(t, mailer.mails, 1, "the reminder is sent once")
+	assert.Equal(t, "reader@bookly.ru", mailer.mails[0].To)
+	assert.Equal(t, `"Dune" is due on `+loan.DueAt.Format(time.DateOnly), mailer.mails[0].Subject)
+	assert.Contains(t, mailer.mails[0].HTML, "<b>Dune</b>")
+
+	require.NoError(t, n.Notify(ctx, loan.DueAt.Add(2*time.Hour)))
+	assert.Len(t, mailer.mails, 1, "no notice before the first overdue stage")
+
+	require.NoError(t, n.Notify(ctx, loan.DueAt.Add(25*time.Hour)))
+	require.Len(t, mailer.mails, 2)
+	assert.Equal(t, `"Dune" is overdue`, mailer.mails[1].Subject)
+	assert.Contains(t, mailer.mails[1].Text, "1 day(s) overdue")
+
+	// The second stage was missed, only the final notice is sent.
+	require.NoError(t, n.Notify(ctx, loan.DueAt.Add(15*24*time.Hour)))
+	require.NoError(t, n.Notify(ctx, loan.DueAt.Add(16*24*time.Hour)))
+	require.Len(t, mailer.mails, 3)
+	assert.Equal(t, `Final notice: "Dune" is overdue`, mailer.mails[2].Subject)
+
+	notices, err := stor.GetNoticeLog(loan.UID, 10)
+	require.NoError(t, err)
+	require.Len(t, notices, 3)
+	inbox, err := stor.GetNotifications(loan.UID, 0, 10, false)
+	require.NoError(t, err)
+	assert.Len(t, inbox, 3, "emailed notices land in the inbox as well")
+	assert.Equal(t, models.NoticeOverdue, notices[0].Kind)
+	assert.Equal(t, 3, notices[0].Stage)
+	assert.Equal(t, models.NoticeSent, notices[0].Status)
+	assert.NotNil(t, notices[0].SentAt)
+	assert.Equal(t, models.NoticeDueSoon, notices[2].Kind)
+}
+
+func TestNotifyRetry(t *testing.T) {
+	stor, loan := setup(t, nil)
+	mailer := &fakeMailer{fails: 4}
+	n := New(stor, mailer, testConfig)
+	now := loan.DueAt.Add(-time.Hour)
+
+	for range 4 {
+		require.NoError(t, n.Notify(context.Background(), now))
+	}
+	assert.Empty(t, mailer.mails)
+	notices, err := stor.GetNoticeLog(loan.UID, 10)
+	require.NoError(t, err)
+	require.Len(t, notices, 1)
+	assert.Equal(t, models.NoticeFailed, notices[0].Status)
+	assert.Equal(t, 3, notices[0].Attempts, "a failed notice is given up after three attempts")
+	assert.Equal(t, "mailbox unavailable", notices[0].Error)
+	inbox, err := stor.GetNotifications(loan.UID, 0, 10, false)
+	require.NoError(t, err)
+	assert.Len(t, inbox, 1, "retries make no more notifications")
+}
+
+func TestNotifyPrefs(t *testing.T) {
+	t.Run("in app", func(t *testing.T) {
+		stor, loan := setup(t, &models.NotifyPrefs{Channel: models.NotifyInApp, Locale: "ru"})
+		mailer := &fakeMailer{}
+		require.NoError(t, New(stor, mailer, testConfig).Notify(context.Background(), loan.DueAt.Add(-time.Hour)))
+		assert.Empty(t, mailer.mails)
+		notices, err := stor.GetNoticeLog(loan.UID, 10)
+		require.NoError(t, err)
+		require.Len(t, notices, 1)
+		assert.Equal(t, models.NotifyInApp, notices[0].Channel)
+		assert.Equal(t, models.NoticeSent, notices[0].Status)
+		assert.Equal(t, "Срок возврата «Dune» — "+loan.DueAt.Format(time.DateOnly), notices[0].Subject)
+		inbox, err := stor.GetNotifications(loan.UID, 0, 10, false)
+		require.NoError(t, err)
+		require.Len(t, inbox, 1)
+		assert.Equal(t, notices[0].Subject, inbox[0].Title)
+	})
+	t.Run("none", func(t *testing.T) {
+		stor, loan := setup(t, &models.NotifyPrefs{Channel: models.NotifyNone, Locale: "en"})
+		mailer := &fakeMailer{}
+		require.NoError(t, New(stor, mailer, testConfig).Notify(context.Background(), loan.DueAt.Add(-time.Hour)))
+		assert.Empty(t, mailer.mails)
+		notices, err := stor.GetNoticeLog(loan.UID, 10)
+		require.NoError(t, err)
+		assert.Empty(t, notices)
+	})
+	t.Run("no mailer", func(t *testing.T) {
+		stor, loan := setup(t, nil)
+		require.NoError(t, New(stor, nil, testConfig).Notify(context.Background(), loan.DueAt.Add(-time.Hour)))
+		notices, err := stor.GetNoticeLog(loan.UID, 10)
+		require.NoError(t, err)
+		require.Len(t, notices, 1)
+		assert.Equal(t, models.NoticeSkipped, notices[0].Status)
+	})
+}
+
+func TestRender(t *testing.T) {
+	templates, err := loadTemplates()
+	require.NoError(t, err)
+	data := noticeData{Lable: "<Dune>", Due: "2024-05-01", Days: 3, Stage: 2}
+	mail, err := templates.render("de", models.NoticeOverdue, data)
+	require.NoError(t, err)
+	assert.Equal(t, `Reminder: "<Dune>" is overdue`, mail.Subject, "unknown locales fall back to english")
+	assert.Contains(t, mail.HTML, "&lt;Dune&gt;")
+	assert.Contains(t, mail.Text, "3 day(s) overdue")
+}
+
+func TestInbox(t *testing.T) {
+	stor, loan := setup(t, &models.NotifyPrefs{Channel: models.NotifyEmail, Locale: "en"})
+	require.NoError(t, stor.SaveBranch(models.Branch{ID: "central", Name: "Central"}))
+	data, err := json.Marshal(models.Hold{HID: "HID1", BID: loan.BID, UID: loan.UID, PickupBranch: "central"})
+	require.NoError(t, err)
+	event := models.Event{ID: 7, Type: models.EventHoldReady, Subject: "HID1", Data: data}
+	inbox := NewInbox(stor)
+
+	require.NoError(t, inbox.Publish(context.Background(), []models.Event{event}))
+	require.NoError(t, inbox.Publish(context.Background(), []models.Event{event, {ID: 8, Type: models.EventBookAdded}}))
+	notifications, err := stor.GetNotifications(loan.UID, 0, 10, false)
+	require.NoError(t, err)
+	require.Len(t, notifications, 1, "a redelivered event makes no second notification")
+	assert.Equal(t, models.NoticeHoldReady, notifications[0].Kind)
+	assert.Equal(t, `"Dune" is ready for pickup`, notifications[0].Title)
+	assert.Contains(t, notifications[0].Body, "at the Central branch")
+}
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// Every locale has a <locale>.txt file defining the "<kind>.subject" and "<kind>.text" templates
+// and a <locale>.html file defining the "<kind>.html" templates for each notice kind.
+//
+//go:embed templates
+var templateFS embed.FS
+
+// noticeData is what the notice templates are executed with.
+type noticeData struct {
+	Lable   string
+	Barcode string
+	Due     string
+	// Days is how many days are left till the due date or how many days the loan is overdue.
+	Days  int
+	Stage int
+	Final bool
+	// Branch is where a ready hold waits.
+	Branch string
+}
+
+func newNoticeData(loan models.LoanDue, stage, stages int, now time.Time) noticeData {
+	days := loan.DueAt.Sub(now)
+	if days < 0 {
+		days = -days
+	}
+	return noticeData{
+		Lable:   loan.Lable,
+		Barcode: loan.Barcode,
+		Due:     loan.DueAt.Format(time.DateOnly),
+		Days:    int(days / (24 * time.Hour)),
+		Stage:   stage,
+		Final:   stage > 0 && stage == stages,
+	}
+}
+
+// noticeTemplates are parsed once, they are embedded so failing to parse them is a bug.
+var noticeTemplates = mustLoadTemplates()
+
+// templates render the notices in the languages there are templates for.
+type templates struct {
+	text map[string]*texttemplate.Template
+	html map[string]*htmltemplate.Template
+}
+
+func mustLoadTemplates() *templates {
+	t, err := loadTemplates()
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// loadTemplates parses the embedded templates of every locale.
+func loadTemplates() (*templates, error) {
+	t := &templates{text: make(map[string]*texttemplate.Template), html: make(map[string]*htmltemplate.Template)}
+	files, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		locale := strings.TrimSuffix(strings.TrimPrefix(file, "templates/"), ".txt")
+		if t.text[locale], err = texttemplate.ParseFS(templateFS, file); err != nil {
+			return nil, err
+		}
+		if t.html[locale], err = htmltemplate.ParseFS(templateFS, "templates/"+locale+".html"); err != nil {
+			return nil, err
+		}
+	}
+	if t.text[consts.DefaultLocale] == nil {
+		return nil, fmt.Errorf("no templates for the default locale %q", consts.DefaultLocale)
+	}
+	return t, nil
+}
+
+// render makes the mail of the notice in the locale, in the default one when there are no templates for it.
+func (t *templates) render(locale string, kind models.NoticeKind, data noticeData) (Mail, error) {
+	if t.text[locale] == nil {
+		locale = consts.DefaultLocale
+	}
+	var mail Mail
+	var buf bytes.Buffer
+	for _, part := range []struct {
+		name string
+		dst  *string
+	}{{"subject", &mail.Subject}, {"text", &mail.Text}} {
+		buf.Reset()
+		if err := t.text[locale].ExecuteTemplate(&buf, string(kind)+"."+part.name, data); err != nil {
+			return Mail{}, err
+		}
+		*part.dst = strings.TrimSpace(buf.String())
+	}
+	buf.Reset()
+	if err := t.html[locale].ExecuteTemplate(&buf, string(kind)+".html", data); err != nil {
+		return Mail{}, err
+	}
+	mail.HTML = buf.String()
+	return mail, nil
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// MemStore keeps the buckets in memory, the limits hold for this process only.
+type MemStore struct {
+	mu      sync.Mutex
+	buckets map[string]Bucket
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{buckets: make(map[string]Bucket)}
+}
+
+func (ms *MemStore) TakeToken(key string, limit Limit, now time.Time) (Bucket, bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	bucket, allowed := Take(ms.buckets[key], limit, now)
+	ms.buckets[key] = bucket
+	return bucket, allowed, nil
+}
+
+func (ms *MemStore) PurgeRateBuckets(before time.Time) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var purged int
+	for key, bucket := range ms.buckets {
+		if bucket.Updated.Before(before) {
+			delete(ms.buckets, key)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,156 @@
+// Package ratelimit limits how often a client may call a route with token buckets: every client
+// gets a bucket per route policy which holds up to Limit.Requests tokens and refills at
+// Limit.Requests per Limit.Per, a request takes a token or is refused. The buckets are kept by a
+// Store, in memory for a single replica or in the database for limits shared across replicas.
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRoute is the policy of the routes without one of their own.
+const DefaultRoute = "default"
+
+var ErrBadLimit = errors.New(`limit must look like "10/1m"`)
+
+// Limit lets Requests through per Per, all of them at once at most.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// ParseLimit reads a limit written as requests/period, like "10/1m".
+func ParseLimit(s string) (Limit, error) {
+	requests, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, ErrBadLimit
+	}
+	var l Limit
+	var err error
+	if l.Requests, err = strconv.Atoi(strings.TrimSpace(requests)); err != nil || l.Requests <= 0 {
+		return Limit{}, ErrBadLimit
+	}
+	if l.Per, err = time.ParseDuration(strings.TrimSpace(per)); err != nil || l.Per <= 0 {
+		return Limit{}, ErrBadLimit
+	}
+	return l, nil
+}
+
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + l.Per.String()
+}
+
+// rate is the number of tokens the bucket gets back per second.
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// Bucket is the state of a token bucket, the zero one is full.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Take refills b for the time passed since its last update and takes a token when there is one.
+func Take(b Bucket, l Limit, now time.Time) (Bucket, bool) {
+	capacity := float64(l.Requests)
+	switch {
+	case b.Updated.IsZero():
+		b.Tokens = capacity
+	case now.After(b.Updated):
+		b.Tokens += now.Sub(b.Updated).Seconds() * l.rate()
+	}
+	// The policy may have been lowered since the bucket was filled.
+	b.Tokens = min(b.Tokens, capacity)
+	if now.After(b.Updated) {
+		b.Updated = now
+	}
+	if b.Tokens < 1 {
+		return b, false
+	}
+	b.Tokens--
+	return b, true
+}
+
+// Store keeps the buckets. TakeToken takes a token from the bucket of key in one step, so that
+// concurrent requests of a client do not take the same token. Buckets not used since before are
+// full and may be dropped by PurgeRateBuckets.
+type Store interface {
+	TakeToken(key string, limit Limit, now time.Time) (Bucket, bool, error)
+	PurgeRateBuckets(before time.Time) (int, error)
+}
+
+// Decision is what the limiter made of a request.
+type Decision struct {
+	Limit   Limit
+	Allowed bool
+	// Remaining is the number of requests the client may still make right away.
+	Remaining int
+	// Reset is how long the bucket takes to fill up again.
+	Reset time.Duration
+	// RetryAfter is how long a refused client has to wait for the next token.
+	RetryAfter time.Duration
+}
+
+// Limiter applies the route policies. They may be replaced while it runs.
+type Limiter struct {
+	store  Store
+	routes atomic.Pointer[map[string]Limit]
+}
+
+// New returns a limiter of routes, keyed by "METHOD /route" or DefaultRoute.
+func New(store Store, routes map[string]Limit) *Limiter {
+	l := &Limiter{store: store}
+	l.SetRoutes(routes)
+	return l
+}
+
+// SetRoutes replaces the route policies. The buckets are kept, lowering a limit cuts them down.
+func (l *Limiter) SetRoutes(routes map[string]Limit) {
+	l.routes.Store(&routes)
+}
+
+// Allow takes a token of subject for route. The second result is false when the route has no
+// policy. When the store fails the request is let through and the error returned.
+func (l *Limiter) Allow(route, subject string, now time.Time) (Decision, bool, error) {
+	routes := *l.routes.Load()
+	limit, ok := routes[route]
+	if !ok {
+		if limit, ok = routes[DefaultRoute]; !ok {
+			return Decision{}, false, nil
+		}
+		route = DefaultRoute
+	}
+	bucket, allowed, err := l.store.TakeToken(route+" "+subject, limit, now)
+	if err != nil {
+		return Decision{Limit: limit, Allowed: true, Remaining: limit.Requests}, true, err
+	}
+	d := Decision{
+		Limit:     limit,
+		Allowed:   allowed,
+		Remaining: int(math.Floor(bucket.Tokens)),
+		Reset:     seconds((float64(limit.Requests) - bucket.Tokens) / limit.rate()),
+	}
+	if !allowed {
+		d.RetryAfter = seconds((1 - bucket.Tokens) / limit.rate())
+	}
+	return d, true, nil
+}
+
+// Purge drops the buckets which have been full for a while.
+func (l *Limiter) Purge(now time.Time) (int, error) {
+	var longest time.Duration
+	for _, limit := range *l.routes.Load() {
+		longest = max(longest, limit.Per)
+	}
+	return l.store.PurgeRateBuckets(now.Add(-longest))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
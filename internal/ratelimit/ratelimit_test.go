@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	type test struct {
+		value string
+		limit Limit
+		err   error
+	}
+	tests := []test{
+		{value: "10/1m", limit: Limit{Requests: 10, Per: time.Minute}},
+		{value: " 5 / 1h30m ", limit: Limit{Requests: 5, Per: 90 * time.Minute}},
+		{value: "10", err: ErrBadLimit},
+		{value: "0/1m", err: ErrBadLimit},
+		{value: "10/0s", err: ErrBadLimit},
+		{value: "ten/1m", err: ErrBadLimit},
+	}
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			limit, err := ParseLimit(tc.value)
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.limit, limit)
+		})
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	store := NewMemStore()
+	l := New(store, map[string]Limit{
+		"POST /add-books": {Requests: 2, Per: time.Minute},
+		DefaultRoute:      {Requests: 100, Per: time.Minute},
+	})
+	now := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	d, limited, err := l.Allow("POST /add-books", "user:1", now)
+	require.NoError(t, err)
+	assert.True(t, limited)
+	assert.Equal(t, Decision{Limit: Limit{2, time.Minute}, Allowed: true, Remaining: 1, Reset: 30 * time.Second}, d)
+	d, _, _ = l.Allow("POST /add-books", "user:1", now)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 0, d.Remaining)
+	d, _, _ = l.Allow("POST /add-books", "user:1", now.Add(10*time.Second))
+	assert.False(t, d.Allowed, "bucket is empty")
+	assert.Equal(t, 20*time.Second, d.RetryAfter)
+
+	d, _, _ = l.Allow("POST /add-books", "user:2", now)
+	assert.True(t, d.Allowed, "other clients have their own buckets")
+	d, _, _ = l.Allow("GET /books/", "user:1", now)
+	assert.True(t, d.Allowed, "other routes fall back to the default policy")
+	assert.Equal(t, 99, d.Remaining)
+
+	d, _, _ = l.Allow("POST /add-books", "user:1", now.Add(30*time.Second))
+	assert.True(t, d.Allowed, "a token is back after half a minute")
+
+	// Lowering a policy cuts the buckets down.
+	l.SetRoutes(map[string]Limit{DefaultRoute: {Requests: 1, Per: time.Hour}})
+	d, _, _ = l.Allow("GET /books/", "user:1", now)
+	assert.True(t, d.Allowed)
+	d, _, _ = l.Allow("GET /books/", "user:1", now)
+	assert.False(t, d.Allowed)
+
+	l.SetRoutes(map[string]Limit{"POST /add-books": {Requests: 2, Per: time.Minute}})
+	_, limited, err = l.Allow("GET /books/", "user:1", now)
+	require.NoError(t, err)
+	assert.False(t, limited, "no default policy")
+
+	purged, err := l.Purge(now.Add(90 * time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 2, purged, "buckets full for a minute are dropped")
+	assert.Len(t, store.buckets, 1)
+}
+
+type failingStore struct{ MemStore }
+
+func (*failingStore) TakeToken(string, Limit, time.Time) (Bucket, bool, error) {
+	return Bucket{}, false, errors.New("db is down")
+}
+
+func TestLimiterStoreError(t *testing.T) {
+	l := New(&failingStore{}, map[string]Limit{DefaultRoute: {Requests: 1, Per: time.Minute}})
+	d, limited, err := l.Allow("GET /books/", "ip:127.0.0.1", time.Now())
+	assert.Error(t, err)
+	assert.True(t, limited)
+	assert.True(t, d.Allowed, "requests pass when the store fails")
+}
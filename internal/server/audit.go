@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+const auditJob = "purge-audit"
+
+// auditOrigin returns the actor and the request of the change made by the request.
+// The storage records the change with them in the transaction which makes it.
+func auditOrigin(ctx *gin.Context) models.AuditEntry {
+	return models.AuditEntry{Actor: ctx.GetString("uid"), RequestID: ctx.GetString(requestIDKey)}
+}
+
+// auditLog lists the latest audit entries, optionally of one entity type and id.
+func (s *Server) auditLog(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	entity := ctx.Query("entity")
+	if entity != "" && entity != models.AuditBook && entity != models.AuditItem && entity != models.AuditUser {
+		ctx.String(http.StatusBadRequest, "unknown audit entity")
+		return
+	}
+	entries, err := s.db(ctx).GetAudit(entity, ctx.Query("id"), consts.AuditLimit)
+	if err != nil {
+		log.Error().Err(err).Msg("get audit failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// purgeAudit removes the audit entries older than the retention period.
+func (s *Server) purgeAudit(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	before := time.Now().Add(-s.auditRetention)
+	purged, err := s.db(ctx).PurgeAudit(before)
+	if err != nil {
+		return err
+	}
+	log.Info().Int("purged", purged).Time("before", before).Msg("old audit entries purged")
+	return ctx.Err()
+}
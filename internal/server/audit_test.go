@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestAuditLog(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.Use(requestID())
+	r.GET("/audit", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleAdmin), srv.auditLog)
+	r.DELETE("/books/:id", srv.JWTAuthMiddleware(), srv.removeBook)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	at := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name   string
+		role   string
+		query  string
+		entity string
+		id     string
+		mock   bool
+		want   want
+	}
+	tests := []test{
+		{
+			name:   "book history",
+			role:   models.RoleAdmin,
+			query:  "?entity=book&id=BID1",
+			entity: models.AuditBook,
+			id:     "BID1",
+			mock:   true,
+			want: want{
+				body: `[{"id":1,"entity":"book","entity_id":"BID1","action":"delete","actor":"test-uid",` +
+					`"request_id":"req-1","at":"2024-10-01T12:00:00Z",` +
+					`"changes":[{"field":"deleted","before":false,"after":true}]}]`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:  "unknown entity",
+			role:  models.RoleAdmin,
+			query: "?entity=branch",
+			want: want{
+				body:       `unknown audit entity`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:  "librarian is forbidden",
+			role:  models.RoleLibrarian,
+			query: "?entity=book",
+			want: want{
+				body:       `access denied`,
+				statusCode: http.StatusForbidden,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: tc.role}, nil)
+			if tc.mock {
+				storMock.On("GetAudit", tc.entity, tc.id, consts.AuditLimit).Return([]models.AuditEntry{{
+					ID:        1,
+					Entity:    models.AuditBook,
+					EntityID:  "BID1",
+					Action:    models.AuditDelete,
+					Actor:     "test-uid",
+					RequestID: "req-1",
+					At:        at,
+					Changes:   []models.FieldChange{{Field: "deleted", Before: false, After: true}},
+				}}, nil)
+			}
+			srv.storage = storMock
+			resp, err := resty.New().R().SetHeader("Authorization", jwt).Get(httpSrv.URL + "/audit" + tc.query)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+
+	t.Run("request id is recorded", func(t *testing.T) {
+		storMock := mocks.NewStorage(t)
+		storMock.On("SetDeleteStatus", "BID1", mock.MatchedBy(func(origin models.AuditEntry) bool {
+			return origin.RequestID == "req-42" && origin.Actor == "test-uid"
+		})).Return(nil)
+		srv.storage = storMock
+		resp, err := resty.New().R().SetHeader("Authorization", jwt).SetHeader(requestIDHeader, "req-42").
+			Delete(httpSrv.URL + "/books/BID1")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, "req-42", resp.Header().Get(requestIDHeader))
+	})
+}
@@ -1,25 +1,47 @@
 package server
 
 import (
-	"context"
+	"bufio"
+	"cmp"
 	"errors"
 	"net/http"
+	"time"
 
+	"github.com/Dorrrke/g3-bookly/internal/catalog"
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
 	"github.com/Dorrrke/g3-bookly/internal/domain/models"
 	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/metadata"
 	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
 	"github.com/gin-gonic/gin"
 )
 
 func (s *Server) allBooks(ctx *gin.Context) {
-	log := logger.Get()
+	log := logger.FromContext(ctx)
 	_, exist := ctx.Get("uid")
 	if !exist {
 		log.Error().Msg("user ID not found")
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
 		return
 	}
-	books, err := s.storage.GetBooks()
+	at, err := asOf(ctx)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	var books []models.Book
+	branch := ctx.Query("branch")
+	switch {
+	case branch != "" && !at.IsZero():
+		ctx.String(http.StatusBadRequest, "as_of can not be combined with branch")
+		return
+	case branch != "":
+		books, err = s.db(ctx).GetBranchBooks(branch)
+	case !at.IsZero():
+		books, err = s.db(ctx).GetBooksAt(at)
+	default:
+		books, err = s.db(ctx).GetBooks()
+	}
 	if err != nil {
 		if errors.Is(err, storerrros.ErrEmptyBooksList) {
 			ctx.String(http.StatusNotFound, err.Error())
@@ -32,15 +54,25 @@ func (s *Server) allBooks(ctx *gin.Context) {
 }
 
 func (s *Server) bookInfo(ctx *gin.Context) {
-	log := logger.Get()
+	log := logger.FromContext(ctx)
 	_, exist := ctx.Get("uid")
 	if !exist {
 		log.Error().Msg("user ID not found")
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
 		return
 	}
+	at, err := asOf(ctx)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
 	id := ctx.Param("id")
-	book, err := s.storage.GetBook(id)
+	var book models.Book
+	if at.IsZero() {
+		book, err = s.db(ctx).GetBook(id)
+	} else {
+		book, err = s.db(ctx).GetBookAt(id, at)
+	}
 	if err != nil {
 		if errors.Is(err, storerrros.ErrBookNoExist) {
 			ctx.String(http.StatusNotFound, err.Error())
@@ -52,8 +84,22 @@ func (s *Server) bookInfo(ctx *gin.Context) {
 	ctx.JSON(http.StatusFound, book)
 }
 
+// asOf reads the optional "as_of" query parameter, an RFC 3339 time the catalog is shown at.
+// The zero time means the current state.
+func asOf(ctx *gin.Context) (time.Time, error) {
+	value := ctx.Query("as_of")
+	if value == "" {
+		return time.Time{}, nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("as_of must be an RFC 3339 time")
+	}
+	return at, nil
+}
+
 func (s *Server) addBook(ctx *gin.Context) {
-	log := logger.Get()
+	log := logger.FromContext(ctx)
 	_, exist := ctx.Get("uid")
 	if !exist {
 		log.Error().Msg("user ID not found")
@@ -67,7 +113,7 @@ func (s *Server) addBook(ctx *gin.Context) {
 		return
 	}
 	book.Count = 1
-	if err := s.storage.SaveBook(book); err != nil {
+	if err := s.db(ctx).SaveBook(book, auditOrigin(ctx)); err != nil {
 		log.Error().Err(err).Msg("save user failed")
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -76,7 +122,7 @@ func (s *Server) addBook(ctx *gin.Context) {
 }
 
 func (s *Server) addBooks(ctx *gin.Context) {
-	log := logger.Get()
+	log := logger.FromContext(ctx)
 	_, exist := ctx.Get("uid")
 	if !exist {
 		log.Error().Msg("user ID not found")
@@ -89,7 +135,7 @@ func (s *Server) addBooks(ctx *gin.Context) {
 		ctx.String(http.StatusBadRequest, "incorrectly entered data")
 		return
 	}
-	if err := s.storage.SaveBooks(books); err != nil {
+	if err := s.db(ctx).SaveBooks(books, auditOrigin(ctx)); err != nil {
 		log.Error().Err(err).Msg("save user failed")
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -97,47 +143,128 @@ func (s *Server) addBooks(ctx *gin.Context) {
 	ctx.String(http.StatusOK, "%s books was added", len(books))
 }
 
+// removeBook moves the book to the trash. It stays there until restored or purged.
 func (s *Server) removeBook(ctx *gin.Context) {
-	log := logger.Get()
-	_, exist := ctx.Get("uid")
-	if !exist {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
 		log.Error().Msg("user ID not found")
 		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
 		return
 	}
 	id := ctx.Param("id")
-	if err := s.storage.SetDeleteStatus(id); err != nil {
-		log.Error().Msg("user ID not found")
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+	if err := s.db(ctx).SetDeleteStatus(id, auditOrigin(ctx)); err != nil {
+		log.Error().Err(err).Msg("delete book failed")
+		if errors.Is(err, storerrros.ErrBookNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	s.delChan <- struct{}{}
-	log.Debug().Int("chan len", len(s.delChan)).Msg("book send into delChan")
 	ctx.String(http.StatusOK, "book "+id+" was deleted")
 }
 
-func (s *Server) deleter(ctx context.Context) {
-	log := logger.Get()
-	defer log.Debug().Msg("deleter was ended")
-	for {
-		select {
-		case <-ctx.Done():
-			log.Debug().Msg("deleter context done")
-			return
-		default:
-			if len(s.delChan) == cap(s.delChan) {
-				log.Debug().Int("cap", cap(s.delChan)).Int("len", cap(s.delChan)).Msg("start deleting")
-				for i := 0; i < cap(s.delChan); i++ { //nolint:intrange //todo
-					<-s.delChan
-				}
-				if err := s.storage.DeleteBooks(); err != nil {
-					log.Error().Err(err).Msg("deleting books failed")
-					s.ErrChan <- err
-					return
-				}
+// exportBooks streams the whole catalog page by page in the format from the "format" query parameter.
+// Once the first page is written errors can only be logged, the client sees a truncated file.
+func (s *Server) exportBooks(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	format := cmp.Or(ctx.Query("format"), catalog.FormatCSV)
+	exportFormat, ok := catalog.ExportFormats[format]
+	if !ok {
+		ctx.String(http.StatusBadRequest, catalog.ErrUnknownFormat.Error())
+		return
+	}
+	books, err := s.db(ctx).GetBooksPage("", consts.ExportPageSize)
+	if err != nil {
+		log.Error().Err(err).Msg("export books failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Content-Type", exportFormat.ContentType)
+	ctx.Header("Content-Disposition", `attachment; filename="catalog`+exportFormat.Ext+`"`)
+	ctx.Status(http.StatusOK)
+	w := bufio.NewWriter(ctx.Writer)
+	enc, err := catalog.NewEncoder(format, w)
+	if err != nil {
+		log.Error().Err(err).Msg("start export failed")
+		return
+	}
+	for len(books) > 0 {
+		for _, book := range books {
+			if err = enc.Encode(book); err != nil {
+				log.Error().Err(err).Str("bid", book.BID).Msg("export book failed")
+				return
 			}
 		}
+		if err = w.Flush(); err != nil {
+			log.Error().Err(err).Msg("export write failed")
+			return
+		}
+		ctx.Writer.Flush()
+		books, err = s.db(ctx).GetBooksPage(books[len(books)-1].BID, consts.ExportPageSize)
+		if err != nil {
+			log.Error().Err(err).Msg("export books failed")
+			return
+		}
+	}
+	if err = enc.Close(); err != nil {
+		log.Error().Err(err).Msg("finish export failed")
+		return
 	}
+	if err = w.Flush(); err != nil {
+		log.Error().Err(err).Msg("export write failed")
+	}
+}
+
+type lookupRequest struct {
+	ISBN string `json:"isbn" validate:"required"`
 }
 
-func (s *Server) bookReturn(_ *gin.Context) {}
+// lookupBook finds book data by ISBN and returns it as a draft to complete and save, nothing is stored.
+func (s *Server) lookupBook(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	if s.meta == nil {
+		ctx.String(http.StatusNotImplemented, "isbn lookup is turned off")
+		return
+	}
+	var req lookupRequest
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(req); err != nil {
+		log.Error().Err(err).Msg("validate lookup request failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	isbn, err := metadata.NormalizeISBN(req.ISBN)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	draft, err := s.meta.Lookup(ctx, isbn)
+	if err != nil {
+		log.Error().Err(err).Str("isbn", isbn).Msg("isbn lookup failed")
+		if errors.Is(err, metadata.ErrNotFound) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, draft)
+}
@@ -2,15 +2,22 @@ package server
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
 	"github.com/Dorrrke/g3-bookly/internal/domain/models"
 	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/metadata"
 	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
 	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
 )
@@ -88,7 +95,7 @@ func TestAllBooks(t *testing.T) {
 			mockFlag: false,
 			request:  "/books",
 			want: want{
-				body:       `invalid token{"error":"User ID not found"}`,
+				body:       `invalid token`,
 				statusCode: http.StatusUnauthorized,
 			},
 		},
@@ -191,3 +198,228 @@ func BenchmarkAllBooks(b *testing.B) {
 		req.Send()
 	}
 }
+
+func TestExportBooks(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/books/export", srv.JWTAuthMiddleware(), srv.exportBooks)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test")
+	assert.NoError(t, err)
+
+	firstPage := make([]models.Book, consts.ExportPageSize)
+	for i := range firstPage {
+		firstPage[i] = models.Book{BID: fmt.Sprintf("b%04d", i), Lable: "Lable", Author: "Author", Desc: "Desc"}
+	}
+	lastBID := firstPage[len(firstPage)-1].BID
+
+	type want struct {
+		contentType string
+		statusCode  int
+	}
+	type test struct {
+		name     string
+		format   string
+		mockFlag bool
+		want     want
+	}
+	tests := []test{
+		{
+			name:     "csv by default",
+			mockFlag: true,
+			want:     want{contentType: "text/csv", statusCode: http.StatusOK},
+		},
+		{
+			name:     "marc21",
+			format:   "marc21",
+			mockFlag: true,
+			want:     want{contentType: "application/marc", statusCode: http.StatusOK},
+		},
+		{
+			name:   "unknown format",
+			format: "pdf",
+			want:   want{contentType: "text/plain; charset=utf-8", statusCode: http.StatusBadRequest},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.mockFlag {
+				storMock.On("GetBooksPage", "", consts.ExportPageSize).Return(firstPage, nil)
+				storMock.On("GetBooksPage", lastBID, consts.ExportPageSize).
+					Return([]models.Book{{BID: "c1", Lable: "Last", Author: "Author", Desc: "Desc"}}, nil)
+				storMock.On("GetBooksPage", "c1", consts.ExportPageSize).Return(nil, nil)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = http.MethodGet
+			req.URL = httpSrv.URL + "/books/export"
+			req.SetHeader("Authorization", jwt)
+			req.SetQueryParam("format", tc.format)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.contentType, resp.Header().Get("Content-Type"))
+			if tc.format == "" {
+				lines := strings.Split(strings.TrimSpace(string(resp.Body())), "\n")
+				assert.Len(t, lines, consts.ExportPageSize+2)
+				assert.Equal(t, "c1,Last,Author,Desc,0,,0,0", lines[len(lines)-1])
+			}
+		})
+	}
+}
+
+func TestLookupBook(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	srv.meta = metadata.NewFixtureFS(fstest.MapFS{
+		"9780140447934.json": {Data: []byte(`{"lable":"War and Peace","author":"Leo Tolstoy",` +
+			`"desc":"Epic novel","cover_url":"https://covers.example/1.jpg"}`)},
+	})
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/books/lookup", srv.JWTAuthMiddleware(), srv.lookupBook)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test")
+	assert.NoError(t, err)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name string
+		body string
+		want want
+	}
+	tests := []test{
+		{
+			name: "found by isbn-10",
+			body: `{"isbn":"0-14-044793-8"}`,
+			want: want{
+				body: `{"isbn":"9780140447934","lable":"War and Peace","author":"Leo Tolstoy",` +
+					`"desc":"Epic novel","cover_url":"https://covers.example/1.jpg","source":"fixture"}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "unknown isbn",
+			body: `{"isbn":"9780804429573"}`,
+			want: want{
+				body:       `no book found for this isbn`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name: "bad check digit",
+			body: `{"isbn":"9780140447935"}`,
+			want: want{
+				body:       `invalid isbn`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/books/lookup"
+			req.SetHeader("Authorization", jwt)
+			req.SetBody(tc.body)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
+
+func TestBooksAsOf(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/books", srv.JWTAuthMiddleware(), srv.allBooks)
+	r.GET("/books/:id", srv.JWTAuthMiddleware(), srv.bookInfo)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test")
+	assert.NoError(t, err)
+	at := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	book := models.Book{BID: "BID1", Lable: "Dune", Author: "Frank Herbert", Desc: "Desert planet", Age: 12, Count: 2}
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name    string
+		request string
+		mock    func(*mocks.Storage)
+		want    want
+	}
+	tests := []test{
+		{
+			name:    "catalog at time",
+			request: "/books?as_of=2024-10-01T12:00:00Z",
+			mock:    func(m *mocks.Storage) { m.On("GetBooksAt", at).Return([]models.Book{book}, nil) },
+			want: want{
+				body:       `[{"bid":"BID1","lable":"Dune","author":"Frank Herbert","desc":"Desert planet","age":12,"count":2}]`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:    "book at time",
+			request: "/books/BID1?as_of=2024-10-01T12:00:00Z",
+			mock:    func(m *mocks.Storage) { m.On("GetBookAt", "BID1", at).Return(book, nil) },
+			want: want{
+				body:       `{"bid":"BID1","lable":"Dune","author":"Frank Herbert","desc":"Desert planet","age":12,"count":2}`,
+				statusCode: http.StatusFound,
+			},
+		},
+		{
+			name:    "book did not exist yet",
+			request: "/books/BID1?as_of=2024-10-01T12:00:00Z",
+			mock: func(m *mocks.Storage) {
+				m.On("GetBookAt", "BID1", at).Return(models.Book{}, storerrros.ErrBookNoExist)
+			},
+			want: want{
+				body:       `book does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:    "invalid time",
+			request: "/books?as_of=yesterday",
+			want: want{
+				body:       `as_of must be an RFC 3339 time`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:    "time with branch",
+			request: "/books?as_of=2024-10-01T12:00:00Z&branch=central",
+			want: want{
+				body:       `as_of can not be combined with branch`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.mock != nil {
+				tc.mock(storMock)
+			}
+			srv.storage = storMock
+			resp, err := resty.New().R().SetHeader("Authorization", jwt).Get(httpSrv.URL + tc.request)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) allBranches(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	if ctx.GetString("uid") == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	branches, err := s.db(ctx).GetBranches()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, branches)
+}
+
+func (s *Server) addBranch(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	var branch models.Branch
+	if err := ctx.ShouldBindBodyWithJSON(&branch); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(branch); err != nil {
+		log.Error().Err(err).Msg("validate branch failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.db(ctx).SaveBranch(branch); err != nil {
+		log.Error().Err(err).Msg("save branch failed")
+		if errors.Is(err, storerrros.ErrBranchExists) {
+			ctx.String(http.StatusConflict, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, branch)
+}
+
+func (s *Server) bookAvailability(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	if ctx.GetString("uid") == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	av, err := s.db(ctx).GetAvailability(ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, storerrros.ErrItemNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, av)
+}
+
+func (s *Server) allTransfers(ctx *gin.Context) {
+	transfers, err := s.db(ctx).GetTransfers(ctx.Query("status"))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, transfers)
+}
+
+func (s *Server) requestTransfer(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	var tr models.Transfer
+	if err := ctx.ShouldBindBodyWithJSON(&tr); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(tr); err != nil {
+		log.Error().Err(err).Msg("validate transfer failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tr.RequestedBy = ctx.GetString("uid")
+	tr, err := s.db(ctx).SaveTransfer(tr)
+	if err != nil {
+		log.Error().Err(err).Msg("save transfer failed")
+		transferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusCreated, tr)
+}
+
+func (s *Server) shipTransfer(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	tr, err := s.db(ctx).ShipTransfer(ctx.Param("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("ship transfer failed")
+		transferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, tr)
+}
+
+func (s *Server) receiveTransfer(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	tr, err := s.db(ctx).ReceiveTransfer(ctx.Param("id"))
+	if err != nil {
+		log.Error().Err(err).Msg("receive transfer failed")
+		transferError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, tr)
+}
+
+func transferError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, storerrros.ErrTransferNoExist), errors.Is(err, storerrros.ErrItemNoExist),
+		errors.Is(err, storerrros.ErrBranchNoExist):
+		ctx.String(http.StatusNotFound, err.Error())
+	case errors.Is(err, storerrros.ErrTransferBadStatus), errors.Is(err, storerrros.ErrItemUnavailable),
+		errors.Is(err, storerrros.ErrItemAtBranch):
+		ctx.String(http.StatusConflict, err.Error())
+	default:
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTransfer(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/transfers", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleLibrarian), srv.requestTransfer)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	requested := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name     string
+		role     string
+		body     string
+		transfer models.Transfer
+		mockFlag bool
+		err      error
+		want     want
+	}
+	tests := []test{
+		{
+			name:     "librarian requests transfer",
+			role:     models.RoleLibrarian,
+			body:     `{"barcode":"BK00000001","to_branch":"north"}`,
+			mockFlag: true,
+			transfer: models.Transfer{
+				TID:         "TID1",
+				Barcode:     "BK00000001",
+				FromBranch:  "central",
+				ToBranch:    "north",
+				Status:      models.TransferRequested,
+				RequestedBy: "test-uid",
+				RequestedAt: requested,
+			},
+			want: want{
+				body: `{"tid":"TID1","barcode":"BK00000001","from_branch":"central","to_branch":"north",` +
+					`"status":"requested","requested_by":"test-uid","requested_at":"2024-10-01T12:00:00Z"}`,
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name: "member is forbidden",
+			role: models.RoleMember,
+			body: `{"barcode":"BK00000001","to_branch":"north"}`,
+			want: want{
+				body:       `access denied`,
+				statusCode: http.StatusForbidden,
+			},
+		},
+		{
+			name:     "item is out",
+			role:     models.RoleAdmin,
+			body:     `{"barcode":"BK00000001","to_branch":"north"}`,
+			mockFlag: true,
+			err:      storerrros.ErrItemUnavailable,
+			want: want{
+				body:       `item is not available for checkout`,
+				statusCode: http.StatusConflict,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: tc.role}, nil)
+			if tc.mockFlag {
+				storMock.On("SaveTransfer", models.Transfer{
+					Barcode:     "BK00000001",
+					ToBranch:    "north",
+					RequestedBy: "test-uid",
+				}).Return(tc.transfer, tc.err)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/transfers"
+			req.SetHeader("Authorization", jwt)
+			req.SetBody(tc.body)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Dorrrke/g3-bookly/internal/blob"
+	"github.com/Dorrrke/g3-bookly/internal/cover"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+)
+
+const coverCacheControl = "public, max-age=86400"
+
+// uploadCover accepts a JPEG, PNG or WebP image either as the "cover" field of a multipart form
+// or as the raw request body, stores the original together with its thumbnails and switches the
+// book to the new cover.
+func (s *Server) uploadCover(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	bid := ctx.Param("id")
+	book, err := s.db(ctx).GetBook(bid)
+	if err != nil {
+		if errors.Is(err, storerrros.ErrBookNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	data, err := readCover(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("read cover failed")
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			ctx.String(http.StatusRequestEntityTooLarge, "cover is too large")
+			return
+		}
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	img, err := cover.Process(data)
+	if err != nil {
+		log.Error().Err(err).Msg("process cover failed")
+		if errors.Is(err, cover.ErrUnsupportedType) {
+			ctx.String(http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		if errors.Is(err, cover.ErrTooLarge) {
+			ctx.String(http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		ctx.String(http.StatusBadRequest, "cover image is corrupted")
+		return
+	}
+	for _, file := range img.Images {
+		key := cover.Key(bid, img.Version, file.Size, img.Images[0].ContentType)
+		if err = s.blobs.Put(ctx, key, bytes.NewReader(file.Data), file.ContentType); err != nil {
+			log.Error().Err(err).Str("key", key).Msg("store cover failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if err = s.db(ctx).SetCover(bid, img.Version, img.Images[0].ContentType, auditOrigin(ctx)); err != nil {
+		log.Error().Err(err).Msg("set cover failed")
+		if errors.Is(err, storerrros.ErrBookNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if book.Cover != "" && book.Cover != img.Version {
+		s.deleteCover(ctx, bid, book.Cover, book.CoverType)
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"cover": img.Version})
+}
+
+// coverImage serves one size of the book cover, medium by default.
+func (s *Server) coverImage(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	size := ctx.DefaultQuery("size", cover.SizeMedium)
+	if _, ok := cover.Boxes[size]; !ok && size != cover.SizeOriginal {
+		ctx.String(http.StatusBadRequest, "unknown cover size")
+		return
+	}
+	book, err := s.db(ctx).GetBook(ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, storerrros.ErrBookNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if book.Cover == "" {
+		ctx.String(http.StatusNotFound, "book has no cover")
+		return
+	}
+	etag := `"` + book.Cover + "-" + size + `"`
+	ctx.Header("Cache-Control", coverCacheControl)
+	ctx.Header("ETag", etag)
+	if match := ctx.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	rc, info, err := s.blobs.Get(ctx, cover.Key(book.BID, book.Cover, size, book.CoverType))
+	if err != nil {
+		log.Error().Err(err).Msg("get cover failed")
+		if errors.Is(err, blob.ErrNotFound) {
+			ctx.String(http.StatusNotFound, "book has no cover")
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer rc.Close()
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "image/jpeg"
+		if size == cover.SizeOriginal {
+			contentType = book.CoverType
+		}
+	}
+	ctx.DataFromReader(http.StatusOK, info.Size, contentType, rc, nil)
+}
+
+func readCover(ctx *gin.Context) ([]byte, error) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, cover.MaxUploadSize)
+	if !strings.HasPrefix(ctx.ContentType(), "multipart/form-data") {
+		return io.ReadAll(ctx.Request.Body)
+	}
+	header, err := ctx.FormFile("cover")
+	if err != nil {
+		return nil, err
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(file)
+}
+
+// deleteCover removes the blobs of a replaced cover. Failures are only logged.
+func (s *Server) deleteCover(ctx *gin.Context, bid, version, contentType string) {
+	log := logger.FromContext(ctx)
+	keys := []string{cover.Key(bid, version, cover.SizeOriginal, contentType)}
+	for size := range cover.Boxes {
+		keys = append(keys, cover.Key(bid, version, size, contentType))
+	}
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			log.Warn().Err(err).Str("key", key).Msg("delete old cover failed")
+		}
+	}
+}
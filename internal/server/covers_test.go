@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/blob"
+	"github.com/Dorrrke/g3-bookly/internal/cover"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadCover(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	blobs, err := blob.NewFS(t.TempDir())
+	assert.NoError(t, err)
+	srv.blobs = blobs
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/books/:id/cover", srv.JWTAuthMiddleware(), srv.uploadCover)
+	r.GET("/books/:id/cover", srv.coverImage)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 300, 450))))
+	pngCover := buf.Bytes()
+	processed, err := cover.Process(pngCover)
+	assert.NoError(t, err)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name      string
+		bid       string
+		multipart bool
+		data      []byte
+		getErr    error
+		setCover  bool
+		want      want
+	}
+	tests := []test{
+		{
+			name:     "successful raw upload",
+			bid:      "BID1",
+			data:     pngCover,
+			setCover: true,
+			want: want{
+				body:       `{"cover":"` + processed.Version + `"}`,
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name:      "successful multipart upload",
+			bid:       "BID2",
+			multipart: true,
+			data:      pngCover,
+			setCover:  true,
+			want: want{
+				body:       `{"cover":"` + processed.Version + `"}`,
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name: "not an image",
+			bid:  "BID1",
+			data: []byte("GIF89a not supported"),
+			want: want{
+				body:       `cover must be a JPEG, PNG or WebP image`,
+				statusCode: http.StatusUnsupportedMediaType,
+			},
+		},
+		{
+			name:   "unknown book",
+			bid:    "BID404",
+			data:   pngCover,
+			getErr: storerrros.ErrBookNoExist,
+			want: want{
+				body:       `book does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			storMock.On("GetBook", tc.bid).Return(models.Book{BID: tc.bid}, tc.getErr)
+			if tc.setCover {
+				storMock.On("SetCover", tc.bid, processed.Version, "image/png", byTestUser()).Return(nil)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/books/" + tc.bid + "/cover"
+			req.SetHeader("Authorization", jwt)
+			if tc.multipart {
+				req.SetFileReader("cover", "cover.png", bytes.NewReader(tc.data))
+			} else {
+				req.SetBody(tc.data)
+			}
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+
+	t.Run("serve stored thumbnail", func(t *testing.T) {
+		storMock := mocks.NewStorage(t)
+		storMock.On("GetBook", "BID1").
+			Return(models.Book{BID: "BID1", Cover: processed.Version, CoverType: "image/png"}, nil)
+		srv.storage = storMock
+		resp, err := resty.New().R().SetQueryParam("size", cover.SizeSmall).Get(httpSrv.URL + "/books/BID1/cover")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, "image/jpeg", resp.Header().Get("Content-Type"))
+		assert.Equal(t, coverCacheControl, resp.Header().Get("Cache-Control"))
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(resp.Body()))
+		assert.NoError(t, err)
+		assert.Equal(t, 96, cfg.Width)
+
+		resp, err = resty.New().R().SetQueryParam("size", cover.SizeSmall).
+			SetHeader("If-None-Match", resp.Header().Get("ETag")).Get(httpSrv.URL + "/books/BID1/cover")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode())
+	})
+
+	t.Run("unknown size", func(t *testing.T) {
+		srv.storage = mocks.NewStorage(t)
+		resp, err := resty.New().R().SetQueryParam("size", "huge").Get(httpSrv.URL + "/books/BID1/cover")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode())
+	})
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// deskProtocol is the WebSocket subprotocol of the desk channel. Clients which can not set
+// the Authorization header offer it followed by the JWT as a second "protocol".
+const deskProtocol = "bookly.jwt"
+
+//nolint:gochecknoglobals //stateless upgrader
+var deskUpgrader = websocket.Upgrader{
+	Subprotocols: []string{deskProtocol},
+}
+
+// WSAuthMiddleware authenticates WebSocket handshakes with the same JWT JWTAuthMiddleware checks.
+// Browsers can not set headers on a handshake, so the token may also come in the subprotocol list
+// after deskProtocol or in the "token" query parameter.
+func (s *Server) WSAuthMiddleware() gin.HandlerFunc {
+	jwtAuth := s.JWTAuthMiddleware()
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader("Authorization") == "" {
+			if token := wsToken(ctx.Request); token != "" {
+				ctx.Request.Header.Set("Authorization", token)
+			}
+		}
+		jwtAuth(ctx)
+	}
+}
+
+func wsToken(req *http.Request) string {
+	protocols := websocket.Subprotocols(req)
+	for i, protocol := range protocols {
+		if protocol == deskProtocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return req.URL.Query().Get("token")
+}
+
+// deskSocket upgrades the request to the desk channel of the branch from the "branch" query parameter.
+func (s *Server) deskSocket(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	branch := strings.TrimSpace(ctx.Query("branch"))
+	if branch != "" {
+		branches, err := s.db(ctx).GetBranches()
+		if err != nil {
+			log.Error().Err(err).Msg("get branches failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !slices.ContainsFunc(branches, func(b models.Branch) bool { return b.ID == branch }) {
+			ctx.String(http.StatusNotFound, storerrros.ErrBranchNoExist.Error())
+			return
+		}
+	}
+	conn, err := deskUpgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// The upgrader has answered the client already.
+		log.Error().Err(err).Msg("desk upgrade failed")
+		return
+	}
+	log.Info().Str("branch", branch).Str("uid", uid).Msg("desk connected")
+	s.desks.Serve(s.done, conn, branch, uid)
+	log.Info().Str("branch", branch).Str("uid", uid).Msg("desk disconnected")
+}
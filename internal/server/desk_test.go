@@ -0,0 +1,116 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/desk"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeskSocket(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/desk", srv.WSAuthMiddleware(), srv.RoleMiddleware(models.RoleLibrarian), srv.deskSocket)
+	httpSrv := httptest.NewServer(r)
+	defer httpSrv.Close()
+	url := "ws" + strings.TrimPrefix(httpSrv.URL, "http") + "/desk"
+	jwt, err := createJWTToken("test-uid")
+	require.NoError(t, err)
+
+	type test struct {
+		name       string
+		role       string
+		query      string
+		protocols  []string
+		header     http.Header
+		statusCode int
+	}
+	tests := []test{
+		{
+			name:       "token in subprotocol",
+			role:       models.RoleLibrarian,
+			query:      "?branch=B1",
+			protocols:  []string{deskProtocol, jwt},
+			statusCode: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "token in query",
+			role:       models.RoleLibrarian,
+			query:      "?branch=B1&token=" + jwt,
+			statusCode: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "token in header",
+			role:       models.RoleAdmin,
+			query:      "?branch=B1",
+			header:     http.Header{"Authorization": []string{jwt}},
+			statusCode: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "no token",
+			query:      "?branch=B1",
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "member can not connect",
+			role:       models.RoleMember,
+			query:      "?branch=B1&token=" + jwt,
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "unknown branch",
+			role:       models.RoleLibrarian,
+			query:      "?branch=B404&token=" + jwt,
+			statusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.role != "" {
+				storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: tc.role}, nil)
+			} else {
+				storMock.On("GetUser", "").Return(models.User{}, assert.AnError).Maybe()
+			}
+			if tc.role != "" && tc.role != models.RoleMember {
+				storMock.On("GetBranches").Return([]models.Branch{{ID: "B1", Name: "Central"}}, nil)
+			}
+			if tc.statusCode == http.StatusSwitchingProtocols {
+				storMock.On("GetDeskAlerts", "B1").Return([]models.DeskAlert{{ID: 1, Branch: "B1", Kind: models.DeskPull}}, nil)
+			}
+			srv.storage = storMock
+			srv.desks = desk.NewHub(storMock, 8)
+			dialer := websocket.Dialer{Subprotocols: tc.protocols}
+			conn, resp, err := dialer.Dial(url+tc.query, tc.header)
+			require.NotNil(t, resp)
+			assert.Equal(t, tc.statusCode, resp.StatusCode)
+			if tc.statusCode != http.StatusSwitchingProtocols {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer conn.Close()
+			if tc.protocols != nil {
+				assert.Equal(t, deskProtocol, conn.Subprotocol())
+			}
+			require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+			var msg desk.Message
+			require.NoError(t, conn.ReadJSON(&msg))
+			assert.Equal(t, desk.TypeAlerts, msg.Type)
+			assert.Len(t, msg.Alerts, 1)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/gin-gonic/gin"
+)
+
+// degradedError is a readiness check result which still lets the instance serve requests.
+type degradedError string
+
+func (e degradedError) Error() string {
+	return string(e)
+}
+
+type readyCheck struct {
+	name string
+	run  func() error
+}
+
+// readyChecks makes the readiness checks of the storage: the database connection and its
+// migrations, or a degraded check when the server fell back to the in-memory storage.
+func readyChecks(stor Storage, migratePath string) []readyCheck {
+	db, ok := stor.(interface{ Ping() error })
+	if !ok {
+		return []readyCheck{{name: "database", run: func() error {
+			return degradedError("database is not connected, running on in-memory storage")
+		}}}
+	}
+	checks := []readyCheck{{name: "database", run: db.Ping}}
+	if m, ok := stor.(interface{ CheckMigrations(string) error }); ok {
+		checks = append(checks, readyCheck{name: "migrations", run: func() error {
+			return m.CheckMigrations(migratePath)
+		}})
+	}
+	return checks
+}
+
+// checkWorkers fails when the background jobs are not running and degrades while some of them fail.
+func (s *Server) checkWorkers() error {
+	if !s.jobs.Running() {
+		return errors.New("job scheduler is not running")
+	}
+	var failing []string
+	for _, st := range s.jobs.Stats() {
+		if st.LastError != "" {
+			failing = append(failing, st.Name)
+		}
+	}
+	if len(failing) > 0 {
+		return degradedError("failing jobs: " + strings.Join(failing, ", "))
+	}
+	return nil
+}
+
+// checkShutdown fails once the server is stopping, so that no new traffic is sent to it.
+func (s *Server) checkShutdown() error {
+	select {
+	case <-s.done:
+		return errors.New("server is shutting down")
+	default:
+		return nil
+	}
+}
+
+// healthz tells that the process is alive. It checks nothing else, a dependency going down
+// must not get the instance restarted.
+func (s *Server) healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": models.HealthOK})
+}
+
+// readyz runs the readiness checks. A failed check answers 503 to take the instance out of
+// the traffic, a degraded one still answers 200.
+func (s *Server) readyz(ctx *gin.Context) {
+	health := s.health()
+	status := http.StatusOK
+	if health.Status == models.HealthFail {
+		status = http.StatusServiceUnavailable
+	}
+	ctx.JSON(status, health)
+}
+
+func (s *Server) health() models.Health {
+	health := models.Health{Status: models.HealthOK, Checks: make(map[string]models.HealthCheck)}
+	for _, c := range s.readyChecks {
+		start := time.Now()
+		err := c.run()
+		check := models.HealthCheck{
+			Status:    models.HealthOK,
+			LatencyMS: float64(time.Since(start).Microseconds()) / float64(time.Millisecond/time.Microsecond),
+		}
+		var degraded degradedError
+		switch {
+		case errors.As(err, &degraded):
+			check.Status = models.HealthDegraded
+		case err != nil:
+			check.Status = models.HealthFail
+		}
+		if err != nil {
+			check.Error = err.Error()
+		}
+		health.Checks[c.name] = check
+		health.Status = worse(health.Status, check.Status)
+	}
+	return health
+}
+
+func worse(a, b models.HealthStatus) models.HealthStatus {
+	rank := map[models.HealthStatus]int{models.HealthOK: 0, models.HealthDegraded: 1, models.HealthFail: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	"github.com/Dorrrke/g3-bookly/internal/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// healthDB is a database backed storage for the readiness checks.
+type healthDB struct {
+	*mocks.Storage
+	pingErr      error
+	migrationErr error
+}
+
+func (db healthDB) Ping() error {
+	return db.pingErr
+}
+
+func (db healthDB) CheckMigrations(string) error {
+	return db.migrationErr
+}
+
+func TestReadyz(t *testing.T) {
+	logger.Get(false)
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name     string
+		stor     Storage
+		failJob  bool
+		stopped  bool
+		noWorker bool
+		want     want
+	}
+	tests := []test{
+		{
+			name: "ready",
+			stor: healthDB{Storage: mocks.NewStorage(t)},
+			want: want{
+				body: `{"status":"ok","checks":{"database":{"status":"ok"},"migrations":{"status":"ok"},` +
+					`"shutdown":{"status":"ok"},"workers":{"status":"ok"}}}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "in-memory storage",
+			stor: mocks.NewStorage(t),
+			want: want{
+				body: `{"status":"degraded","checks":{"database":{"status":"degraded",` +
+					`"error":"database is not connected, running on in-memory storage"},` +
+					`"shutdown":{"status":"ok"},"workers":{"status":"ok"}}}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:    "failing job",
+			stor:    healthDB{Storage: mocks.NewStorage(t)},
+			failJob: true,
+			want: want{
+				body: `{"status":"degraded","checks":{"database":{"status":"ok"},"migrations":{"status":"ok"},` +
+					`"shutdown":{"status":"ok"},"workers":{"status":"degraded","error":"failing jobs: broken"}}}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "database down and behind",
+			stor: healthDB{
+				Storage:      mocks.NewStorage(t),
+				pingErr:      errors.New("connection refused"),
+				migrationErr: errors.New("database is at migration 14, expected 15"),
+			},
+			want: want{
+				body: `{"status":"fail","checks":{"database":{"status":"fail","error":"connection refused"},` +
+					`"migrations":{"status":"fail","error":"database is at migration 14, expected 15"},` +
+					`"shutdown":{"status":"ok"},"workers":{"status":"ok"}}}`,
+				statusCode: http.StatusServiceUnavailable,
+			},
+		},
+		{
+			name:     "workers stopped",
+			stor:     mocks.NewStorage(t),
+			noWorker: true,
+			stopped:  true,
+			want: want{
+				body: `{"status":"fail","checks":{"database":{"status":"degraded",` +
+					`"error":"database is not connected, running on in-memory storage"},` +
+					`"shutdown":{"status":"fail","error":"server is shutting down"},` +
+					`"workers":{"status":"fail","error":"job scheduler is not running"}}}`,
+				statusCode: http.StatusServiceUnavailable,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := Server{jobs: worker.New(worker.Backoff{Initial: time.Millisecond, Max: time.Millisecond})}
+			srv.readyChecks = append(readyChecks(tc.stor, "migrations"),
+				readyCheck{name: "workers", run: srv.checkWorkers},
+				readyCheck{name: "shutdown", run: srv.checkShutdown},
+			)
+			srv.jobs.Add(worker.Job{Name: "broken", Run: func(context.Context) error { return errors.New("boom") }})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if !tc.noWorker {
+				go srv.jobs.Run(ctx)
+				require.Eventually(t, srv.jobs.Running, time.Second, time.Millisecond)
+			}
+			if tc.failJob {
+				require.NoError(t, srv.jobs.Trigger("broken"))
+				require.Eventually(t, func() bool { return srv.jobs.Stats()[0].LastError != "" },
+					time.Second, time.Millisecond)
+			}
+			done := make(chan struct{})
+			if tc.stopped {
+				close(done)
+			}
+			srv.done = done
+			r := gin.New()
+			r.GET("/readyz", srv.readyz)
+			httpSrv := httptest.NewServer(r)
+			defer httpSrv.Close()
+
+			resp, err := resty.New().R().Get(httpSrv.URL + "/readyz")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.JSONEq(t, tc.want.body, stripLatency(t, resp.Body()))
+		})
+	}
+
+	t.Run("healthz", func(t *testing.T) {
+		var srv Server
+		r := gin.New()
+		r.GET("/healthz", srv.healthz)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"status":"ok"}`, rec.Body.String())
+	})
+}
+
+// stripLatency drops the latencies from the health response, they differ between runs.
+func stripLatency(t *testing.T, body []byte) string {
+	t.Helper()
+	var health map[string]any
+	require.NoError(t, json.Unmarshal(body, &health))
+	for _, check := range health["checks"].(map[string]any) {
+		assert.Contains(t, check, "latency_ms")
+		delete(check.(map[string]any), "latency_ms")
+	}
+	out, err := json.Marshal(health)
+	require.NoError(t, err)
+	return string(out)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) placeHold(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	var hold models.Hold
+	if err := ctx.ShouldBindBodyWithJSON(&hold); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(hold); err != nil {
+		log.Error().Err(err).Msg("validate hold failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hold.BID = ctx.Param("id")
+	hold.UID = uid
+	hold, err := s.db(ctx).PlaceHold(hold)
+	if err != nil {
+		log.Error().Err(err).Msg("place hold failed")
+		if errors.Is(err, storerrros.ErrBookNoExist) || errors.Is(err, storerrros.ErrBranchNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, hold)
+}
+
+func (s *Server) userHolds(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	holds, err := s.db(ctx).GetHolds(uid)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, holds)
+}
+
+func (s *Server) cancelHold(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	hid := ctx.Param("id")
+	if err := s.db(ctx).CancelHold(hid, uid); err != nil {
+		log.Error().Err(err).Msg("cancel hold failed")
+		switch {
+		case errors.Is(err, storerrros.ErrHoldNoExist):
+			ctx.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, storerrros.ErrHoldClosed):
+			ctx.String(http.StatusConflict, err.Error())
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.String(http.StatusOK, "hold "+hid+" was cancelled")
+}
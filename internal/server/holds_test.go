@@ -0,0 +1,135 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaceHold(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/books/:id/holds", srv.JWTAuthMiddleware(), srv.placeHold)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	created := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name     string
+		bid      string
+		pickup   string
+		body     string
+		hold     models.Hold
+		mockFlag bool
+		err      error
+		want     want
+	}
+	tests := []test{
+		{
+			name:     "hold ready at pickup branch",
+			bid:      "BID1",
+			pickup:   "central",
+			body:     `{"pickup_branch":"central"}`,
+			mockFlag: true,
+			hold: models.Hold{
+				HID:          "HID1",
+				BID:          "BID1",
+				UID:          "test-uid",
+				PickupBranch: "central",
+				Barcode:      "BK00000001",
+				Status:       models.HoldReady,
+				CreatedAt:    created,
+				ReadyAt:      &created,
+			},
+			want: want{
+				body: `{"hid":"HID1","bid":"BID1","uid":"test-uid","pickup_branch":"central",` +
+					`"barcode":"BK00000001","status":"ready","created_at":"2024-10-01T12:00:00Z",` +
+					`"ready_at":"2024-10-01T12:00:00Z"}`,
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name: "missing pickup branch",
+			bid:  "BID1",
+			body: `{}`,
+			want: want{
+				body: `{"error":"Key: 'Hold.PickupBranch' Error:Field validation ` +
+					`for 'PickupBranch' failed on the 'required' tag"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:     "unknown branch",
+			bid:      "BID1",
+			pickup:   "nowhere",
+			body:     `{"pickup_branch":"nowhere"}`,
+			mockFlag: true,
+			err:      storerrros.ErrBranchNoExist,
+			want: want{
+				body:       `branch does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.mockFlag {
+				storMock.On("PlaceHold", models.Hold{
+					BID:          tc.bid,
+					UID:          "test-uid",
+					PickupBranch: tc.pickup,
+				}).Return(tc.hold, tc.err)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/books/" + tc.bid + "/holds"
+			req.SetHeader("Authorization", jwt)
+			req.SetBody(tc.body)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
+
+func TestHoldsNeedToken(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.storage = mocks.NewStorage(t)
+	r := gin.New()
+	r.GET("/users/holds", srv.JWTAuthMiddleware(), srv.userHolds)
+	r.DELETE("/holds/:id", srv.JWTAuthMiddleware(), srv.cancelHold)
+	httpSrv := httptest.NewServer(r)
+	defer httpSrv.Close()
+
+	resp, err := resty.New().R().Get(httpSrv.URL + "/users/holds")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	assert.Equal(t, "invalid token", string(resp.Body()))
+	resp, err = resty.New().R().SetHeader("Authorization", "bad").Delete(httpSrv.URL + "/holds/HID1")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	assert.Equal(t, "invalid token", string(resp.Body()))
+}
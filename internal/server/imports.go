@@ -0,0 +1,354 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/catalog"
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/Dorrrke/g3-bookly/internal/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func importKey(id string) string {
+	return "imports/" + id
+}
+
+// startImport saves the uploaded catalog file and queues it for the importer.
+// The format is taken from the "format" query parameter or from the Content-Type header.
+// With dry_run=true nothing is saved, the response previews what the import would do.
+func (s *Server) startImport(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	format := ctx.Query("format")
+	if format == "" {
+		format = catalog.FormatByType(ctx.ContentType())
+	}
+	if !catalog.IsImportFormat(format) {
+		ctx.String(http.StatusUnsupportedMediaType, catalog.ErrUnknownFormat.Error())
+		return
+	}
+	if ctx.Query("dry_run") == "true" {
+		s.previewImport(ctx, format)
+		return
+	}
+	job := models.ImportJob{
+		ID:        uuid.New().String(),
+		Format:    format,
+		Status:    models.ImportQueued,
+		CreatedBy: uid,
+		CreatedAt: time.Now(),
+	}
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, consts.MaxImportSize)
+	if err := s.blobs.Put(ctx, importKey(job.ID), body, ctx.ContentType()); err != nil {
+		log.Error().Err(err).Msg("store import upload failed")
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			ctx.String(http.StatusRequestEntityTooLarge, "import file is too large")
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.db(ctx).SaveImport(job); err != nil {
+		log.Error().Err(err).Msg("save import failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	select {
+	case s.importChan <- job.ID:
+	default:
+		log.Warn().Str("id", job.ID).Msg("import queue is full")
+		s.finishImport(ctx, job, nil, errors.New("import queue is full"))
+		if err := s.blobs.Delete(ctx, importKey(job.ID)); err != nil {
+			log.Warn().Err(err).Msg("delete import upload failed")
+		}
+		ctx.String(http.StatusServiceUnavailable, "import queue is full, try again later")
+		return
+	}
+	ctx.Header("Location", "/imports/"+job.ID)
+	ctx.JSON(http.StatusAccepted, job)
+}
+
+// previewImport reads the upload and finds out for every record whether the import would create
+// a book or merge it into an existing one by the same title and author rule SaveBooks uses.
+func (s *Server) previewImport(ctx *gin.Context, format string) {
+	log := logger.FromContext(ctx)
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, consts.MaxImportSize)
+	dec, err := catalog.NewDecoder(format, body)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	preview := models.ImportPreview{Format: format, Rows: []models.PreviewRow{}}
+	// seen maps title and author of the records read so far to the bid they merge into.
+	seen := make(map[[2]string]string)
+	for {
+		book, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *catalog.RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			log.Error().Err(err).Msg("read import preview failed")
+			ctx.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		preview.Processed++
+		row := models.PreviewRow{Row: dec.Row()}
+		if rowErr != nil {
+			err = rowErr.Err
+		} else {
+			err = s.valid.Struct(book)
+		}
+		if err != nil {
+			preview.Failed++
+			row.Action, row.Error = models.PreviewError, err.Error()
+		} else {
+			key := [2]string{book.Lable, book.Author}
+			bid, merged := seen[key]
+			if !merged {
+				found, err := s.db(ctx).FindBook(book.Lable, book.Author)
+				switch {
+				case err == nil:
+					bid, merged = found.BID, true
+				case !errors.Is(err, storerrros.ErrBookNoExist):
+					log.Error().Err(err).Msg("find book failed")
+					ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				seen[key] = bid
+			}
+			row.Action, row.BID, row.Book = models.PreviewCreate, bid, &book
+			if merged {
+				row.Action = models.PreviewMerge
+				preview.Merge++
+			} else {
+				preview.Create++
+			}
+		}
+		if len(preview.Rows) < consts.PreviewRows {
+			preview.Rows = append(preview.Rows, row)
+		} else {
+			preview.Truncated = true
+		}
+	}
+	ctx.JSON(http.StatusOK, preview)
+}
+
+func (s *Server) importStatus(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	job, err := s.db(ctx).GetImport(ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, storerrros.ErrImportNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, job)
+}
+
+// importErrors downloads the rejected rows of the import as a CSV report.
+func (s *Server) importErrors(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	id := ctx.Param("id")
+	if _, err := s.db(ctx).GetImport(id); err != nil {
+		if errors.Is(err, storerrros.ErrImportNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	rowErrs, err := s.db(ctx).GetImportErrors(id)
+	if err != nil {
+		log.Error().Err(err).Msg("get import errors failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.Header("Content-Type", "text/csv")
+	ctx.Header("Content-Disposition", `attachment; filename="import-`+id+`-errors.csv"`)
+	ctx.Status(http.StatusOK)
+	w := csv.NewWriter(ctx.Writer)
+	_ = w.Write([]string{"row", "error"})
+	for _, rowErr := range rowErrs {
+		_ = w.Write([]string{strconv.Itoa(rowErr.Row), rowErr.Error})
+	}
+	w.Flush()
+}
+
+// importer runs queued imports one at a time until the context is done.
+func (s *Server) importer(ctx context.Context) {
+	log := logger.FromContext(ctx)
+	defer log.Debug().Msg("importer was ended")
+	s.resumeImports(ctx, time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debug().Msg("importer context done")
+			return
+		case id := <-s.importChan:
+			s.runImport(ctx, id)
+		}
+	}
+}
+
+// resumeImports picks up the imports left unfinished by an earlier run of the server:
+// the queued ones are run, the ones which were running when it stopped are failed,
+// since the books they already saved would be saved again.
+func (s *Server) resumeImports(ctx context.Context, startedAt time.Time) {
+	log := logger.FromContext(ctx)
+	jobs, err := s.db(ctx).UnfinishedImports(startedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("get unfinished imports failed")
+		return
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if job.Status == models.ImportQueued {
+			log.Info().Str("import", job.ID).Msg("resume queued import")
+			s.runImport(ctx, job.ID)
+			continue
+		}
+		log.Warn().Str("import", job.ID).Msg("fail import interrupted by a restart")
+		s.finishImport(ctx, job, nil, errors.New("import interrupted by a restart"))
+		if err := s.blobs.Delete(ctx, importKey(job.ID)); err != nil {
+			log.Warn().Err(err).Msg("delete import upload failed")
+		}
+	}
+}
+
+// runImport saves the books of the uploaded file one by one. Rejected rows are recorded
+// and skipped, progress is saved every consts.ImportFlushRows rows. A storage failure stops the import.
+func (s *Server) runImport(ctx context.Context, id string) {
+	ctx, span := tracing.Tracer().Start(ctx, "import", trace.WithAttributes(attribute.String("import.id", id)))
+	defer span.End()
+	log := logger.FromContext(ctx).With().Str("import", id).Ctx(ctx).Logger()
+	job, err := s.db(ctx).GetImport(id)
+	if err != nil {
+		log.Error().Err(err).Msg("get import failed")
+		return
+	}
+	defer func() {
+		if err := s.blobs.Delete(context.WithoutCancel(ctx), importKey(id)); err != nil {
+			log.Warn().Err(err).Msg("delete import upload failed")
+		}
+	}()
+	var pending []models.ImportError
+	// A malformed upload fails its import, not the process the importer runs in.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Any("panic", r).Str("stack", string(debug.Stack())).Msg("import panicked")
+			s.finishImport(ctx, job, pending, fmt.Errorf("import failed: %v", r))
+		}
+	}()
+	job.Status = models.ImportRunning
+	if err = s.db(ctx).UpdateImport(job, nil); err != nil {
+		log.Error().Err(err).Msg("update import failed")
+		return
+	}
+	rc, _, err := s.blobs.Get(ctx, importKey(id))
+	if err != nil {
+		s.finishImport(ctx, job, nil, err)
+		return
+	}
+	defer rc.Close()
+	dec, err := catalog.NewDecoder(job.Format, rc)
+	if err != nil {
+		s.finishImport(ctx, job, nil, err)
+		return
+	}
+	// Changes made by an import are audited on behalf of its author with the import ID as the request ID.
+	origin := models.AuditEntry{Actor: job.CreatedBy, RequestID: job.ID}
+	for {
+		if ctx.Err() != nil {
+			s.finishImport(ctx, job, pending, errors.New("import interrupted"))
+			return
+		}
+		book, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rowErr *catalog.RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			s.finishImport(ctx, job, pending, err)
+			return
+		}
+		job.Processed++
+		if rowErr != nil {
+			err = rowErr.Err
+		} else if err = s.valid.Struct(book); err == nil {
+			err = s.db(ctx).SaveBook(book, origin)
+			// Only a book the storage rejects fails its row, any other error would fail every row after it.
+			if err != nil && !errors.Is(err, storerrros.ErrInvalidBook) {
+				log.Error().Err(err).Int("row", dec.Row()).Msg("save book failed")
+				job.Processed--
+				s.finishImport(ctx, job, pending, err)
+				return
+			}
+		}
+		if err != nil {
+			job.Failed++
+			pending = append(pending, models.ImportError{Row: dec.Row(), Error: err.Error()})
+		} else {
+			job.Imported++
+		}
+		if job.Processed%consts.ImportFlushRows == 0 {
+			if err = s.db(ctx).UpdateImport(job, pending); err != nil {
+				log.Error().Err(err).Msg("save import progress failed")
+			} else {
+				pending = nil
+			}
+		}
+	}
+	s.finishImport(ctx, job, pending, nil)
+	log.Info().Int("imported", job.Imported).Int("failed", job.Failed).Msg("import finished")
+}
+
+func (s *Server) finishImport(ctx context.Context, job models.ImportJob, pending []models.ImportError, err error) {
+	log := logger.FromContext(ctx)
+	now := time.Now()
+	job.Status = models.ImportDone
+	if err != nil {
+		job.Status = models.ImportFailed
+		job.Error = err.Error()
+	}
+	job.FinishedAt = &now
+	if err = s.db(ctx).UpdateImport(job, pending); err != nil {
+		log.Error().Err(err).Str("import", job.ID).Msg("finish import failed")
+	}
+}
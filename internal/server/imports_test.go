@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/blob"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStartImport(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	blobs, err := blob.NewFS(t.TempDir())
+	assert.NoError(t, err)
+	srv.blobs = blobs
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/imports", srv.JWTAuthMiddleware(), srv.startImport)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+
+	type want struct {
+		format     string
+		statusCode int
+	}
+	type test struct {
+		name        string
+		contentType string
+		format      string
+		queueSize   int
+		mockFlag    bool
+		want        want
+	}
+	tests := []test{
+		{
+			name:        "csv by content type",
+			contentType: "text/csv",
+			queueSize:   1,
+			mockFlag:    true,
+			want:        want{format: "csv", statusCode: http.StatusAccepted},
+		},
+		{
+			name:        "ndjson by query",
+			contentType: "application/octet-stream",
+			format:      "ndjson",
+			queueSize:   1,
+			mockFlag:    true,
+			want:        want{format: "ndjson", statusCode: http.StatusAccepted},
+		},
+		{
+			name:        "unknown format",
+			contentType: "application/json",
+			want:        want{statusCode: http.StatusUnsupportedMediaType},
+		},
+		{
+			name:        "queue is full",
+			contentType: "text/csv",
+			mockFlag:    true,
+			want:        want{format: "csv", statusCode: http.StatusServiceUnavailable},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.mockFlag {
+				storMock.On("SaveImport", mock.MatchedBy(func(job models.ImportJob) bool {
+					return job.Format == tc.want.format && job.CreatedBy == "test-uid" &&
+						job.Status == models.ImportQueued
+				})).Return(nil)
+			}
+			if tc.want.statusCode == http.StatusServiceUnavailable {
+				storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+					return job.Status == models.ImportFailed
+				}), []models.ImportError(nil)).Return(nil)
+			}
+			srv.storage = storMock
+			srv.importChan = make(chan string, tc.queueSize)
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/imports"
+			req.SetHeader("Authorization", jwt)
+			req.SetHeader("Content-Type", tc.contentType)
+			req.SetQueryParam("format", tc.format)
+			req.SetBody("lable,author,desc,age\n")
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			if tc.want.statusCode == http.StatusAccepted {
+				assert.Len(t, srv.importChan, 1)
+				assert.Contains(t, resp.Header().Get("Location"), "/imports/")
+			}
+		})
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	blobs, err := blob.NewFS(t.TempDir())
+	assert.NoError(t, err)
+	srv.blobs = blobs
+	input := "lable,author,desc,age\n" +
+		"War and Peace,Leo Tolstoy,Epic novel about 1812,12\n" +
+		"X,Leo Tolstoy,Too short title for validation,12\n" +
+		"Idiot,Fyodor Dostoevsky,Novel about a good man,sixteen\n" +
+		"Three Sisters,Anton Chekhov,A play in four acts,14\n"
+	assert.NoError(t, blobs.Put(context.Background(), importKey("IMP1"), strings.NewReader(input), "text/csv"))
+
+	storMock := mocks.NewStorage(t)
+	storMock.On("GetImport", "IMP1").Return(models.ImportJob{ID: "IMP1", Format: "csv", CreatedBy: "UID1"}, nil)
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.Status == models.ImportRunning
+	}), []models.ImportError(nil)).Return(nil)
+	storMock.On("SaveBook", mock.Anything, models.AuditEntry{Actor: "UID1", RequestID: "IMP1"}).Return(nil).Twice()
+	var final models.ImportJob
+	var rowErrs []models.ImportError
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.Status == models.ImportDone
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		final = args.Get(0).(models.ImportJob)
+		rowErrs = args.Get(1).([]models.ImportError)
+	}).Return(nil)
+	srv.storage = storMock
+
+	srv.runImport(context.Background(), "IMP1")
+	assert.Equal(t, 4, final.Processed)
+	assert.Equal(t, 2, final.Imported)
+	assert.Equal(t, 2, final.Failed)
+	assert.NotNil(t, final.FinishedAt)
+	assert.Len(t, rowErrs, 2)
+	assert.Equal(t, 2, rowErrs[0].Row)
+	assert.Equal(t, models.ImportError{Row: 3, Error: `invalid age "sixteen"`}, rowErrs[1])
+	_, _, err = blobs.Get(context.Background(), importKey("IMP1"))
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestRunImportPanic(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	blobs, err := blob.NewFS(t.TempDir())
+	assert.NoError(t, err)
+	srv.blobs = blobs
+	input := "lable,author,desc,age\nWar and Peace,Leo Tolstoy,Epic novel about 1812,12\n"
+	assert.NoError(t, blobs.Put(context.Background(), importKey("IMP1"), strings.NewReader(input), "text/csv"))
+
+	storMock := mocks.NewStorage(t)
+	storMock.On("GetImport", "IMP1").Return(models.ImportJob{ID: "IMP1", Format: "csv"}, nil)
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.Status == models.ImportRunning
+	}), []models.ImportError(nil)).Return(nil)
+	storMock.On("SaveBook", mock.Anything, mock.Anything).Run(func(mock.Arguments) { panic("boom") })
+	var final models.ImportJob
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.Status == models.ImportFailed
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		final = args.Get(0).(models.ImportJob)
+	}).Return(nil)
+	srv.storage = storMock
+
+	assert.NotPanics(t, func() { srv.runImport(context.Background(), "IMP1") })
+	assert.Equal(t, "import failed: boom", final.Error)
+	assert.NotNil(t, final.FinishedAt)
+	_, _, err = blobs.Get(context.Background(), importKey("IMP1"))
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestRunImportStorageError(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	blobs, err := blob.NewFS(t.TempDir())
+	assert.NoError(t, err)
+	srv.blobs = blobs
+	input := "lable,author,desc,age\n" +
+		"War and Peace,Leo Tolstoy,Epic novel about 1812,12\n" +
+		"Anna Karenina,Leo Tolstoy,Novel about a tragic love,16\n" +
+		"Three Sisters,Anton Chekhov,A play in four acts,14\n"
+	assert.NoError(t, blobs.Put(context.Background(), importKey("IMP1"), strings.NewReader(input), "text/csv"))
+
+	storMock := mocks.NewStorage(t)
+	storMock.On("GetImport", "IMP1").Return(models.ImportJob{ID: "IMP1", Format: "csv"}, nil)
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.Status == models.ImportRunning
+	}), []models.ImportError(nil)).Return(nil)
+	storMock.On("SaveBook", mock.MatchedBy(func(book models.Book) bool {
+		return book.Lable == "War and Peace"
+	}), mock.Anything).Return(fmt.Errorf("%w: value too long", storerrros.ErrInvalidBook)).Once()
+	storMock.On("SaveBook", mock.MatchedBy(func(book models.Book) bool {
+		return book.Lable == "Anna Karenina"
+	}), mock.Anything).Return(errors.New("connection refused")).Once()
+	var final models.ImportJob
+	var rowErrs []models.ImportError
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.Status == models.ImportFailed
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		final = args.Get(0).(models.ImportJob)
+		rowErrs = args.Get(1).([]models.ImportError)
+	}).Return(nil)
+	srv.storage = storMock
+
+	srv.runImport(context.Background(), "IMP1")
+	assert.Equal(t, "connection refused", final.Error)
+	assert.Equal(t, 1, final.Processed)
+	assert.Equal(t, 1, final.Failed)
+	assert.Equal(t, 0, final.Imported)
+	assert.Len(t, rowErrs, 1)
+	assert.Equal(t, 1, rowErrs[0].Row)
+}
+
+func TestResumeImports(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	blobs, err := blob.NewFS(t.TempDir())
+	assert.NoError(t, err)
+	srv.blobs = blobs
+	input := "lable,author,desc,age\nWar and Peace,Leo Tolstoy,Epic novel about 1812,12\n"
+	assert.NoError(t, blobs.Put(context.Background(), importKey("IMP1"), strings.NewReader(input), "text/csv"))
+	assert.NoError(t, blobs.Put(context.Background(), importKey("IMP2"), strings.NewReader(input), "text/csv"))
+	startedAt := time.Now()
+	queued := models.ImportJob{ID: "IMP1", Format: "csv", Status: models.ImportQueued, CreatedBy: "UID1"}
+	running := models.ImportJob{ID: "IMP2", Format: "csv", Status: models.ImportRunning, CreatedBy: "UID1"}
+
+	storMock := mocks.NewStorage(t)
+	storMock.On("UnfinishedImports", startedAt).Return([]models.ImportJob{queued, running}, nil)
+	storMock.On("GetImport", "IMP1").Return(queued, nil)
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.ID == "IMP1" && job.Status == models.ImportRunning
+	}), []models.ImportError(nil)).Return(nil)
+	storMock.On("SaveBook", mock.Anything, models.AuditEntry{Actor: "UID1", RequestID: "IMP1"}).Return(nil).Once()
+	finished := make(map[string]models.ImportJob)
+	storMock.On("UpdateImport", mock.MatchedBy(func(job models.ImportJob) bool {
+		return job.FinishedAt != nil
+	}), mock.Anything).Run(func(args mock.Arguments) {
+		job := args.Get(0).(models.ImportJob)
+		finished[job.ID] = job
+	}).Return(nil)
+	srv.storage = storMock
+
+	srv.resumeImports(context.Background(), startedAt)
+	assert.Equal(t, models.ImportDone, finished["IMP1"].Status)
+	assert.Equal(t, 1, finished["IMP1"].Imported)
+	assert.Equal(t, models.ImportFailed, finished["IMP2"].Status)
+	assert.Equal(t, "import interrupted by a restart", finished["IMP2"].Error)
+	for _, id := range []string{"IMP1", "IMP2"} {
+		_, _, err = blobs.Get(context.Background(), importKey(id))
+		assert.ErrorIs(t, err, blob.ErrNotFound)
+	}
+}
+
+func TestPreviewImport(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/imports", srv.JWTAuthMiddleware(), srv.startImport)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+
+	input := `{"lable":"War and Peace","author":"Leo Tolstoy","desc":"Epic novel about 1812","age":12}
+{"lable":"Idiot","author":"Fyodor Dostoevsky","desc":"Novel about a good man","age":16}
+{"lable":"Idiot","author":"Fyodor Dostoevsky","desc":"Second copy of the novel","age":16}
+{"lable":"X","author":"Nobody","desc":"Too short title","age":1}
+`
+	storMock := mocks.NewStorage(t)
+	storMock.On("FindBook", "War and Peace", "Leo Tolstoy").Return(models.Book{BID: "BID1"}, nil).Once()
+	storMock.On("FindBook", "Idiot", "Fyodor Dostoevsky").Return(models.Book{}, storerrros.ErrBookNoExist).Once()
+	srv.storage = storMock
+
+	resp, err := resty.New().R().
+		SetHeader("Authorization", jwt).
+		SetHeader("Content-Type", "application/x-ndjson").
+		SetQueryParam("dry_run", "true").
+		SetBody(input).
+		Post(httpSrv.URL + "/imports")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.JSONEq(t, `{"format":"ndjson","processed":4,"create":1,"merge":2,"failed":1,"rows":[
+		{"row":1,"action":"merge","bid":"BID1","book":{"lable":"War and Peace","author":"Leo Tolstoy",
+			"desc":"Epic novel about 1812","age":12}},
+		{"row":2,"action":"create","book":{"lable":"Idiot","author":"Fyodor Dostoevsky",
+			"desc":"Novel about a good man","age":16}},
+		{"row":3,"action":"merge","book":{"lable":"Idiot","author":"Fyodor Dostoevsky",
+			"desc":"Second copy of the novel","age":16}},
+		{"row":4,"action":"error","error":"Key: 'Book.Lable' Error:Field validation for 'Lable' failed on the 'min' tag"}
+	]}`, string(resp.Body()))
+}
@@ -0,0 +1,557 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/metrics"
+	"github.com/Dorrrke/g3-bookly/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentedStorage measures the latency of every storage method, counts the failed calls
+// and traces each call as a span under the span of ctx, see Server.db.
+// A method added to Storage without a wrapper here still works, only unmeasured.
+type instrumentedStorage struct {
+	Storage
+	metrics *metrics.Metrics
+	ctx     context.Context //nolint:containedctx //storage methods take no context
+}
+
+// observe starts measuring a call of method and returns the func to end it with the call result.
+func (is instrumentedStorage) observe(method string) func(error) {
+	ctx := is.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, span := tracing.Tracer().Start(ctx, "storage "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation.name", method)))
+	start := time.Now()
+	return func(err error) {
+		is.metrics.ObserveStorage(method, start, err)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+func (is instrumentedStorage) SaveUser(user models.User, origin models.AuditEntry) (string, error) {
+	done := is.observe("SaveUser")
+	res, err := is.Storage.SaveUser(user, origin)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ValidUser(user models.User) (string, error) {
+	done := is.observe("ValidUser")
+	res, err := is.Storage.ValidUser(user)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SaveBook(book models.Book, origin models.AuditEntry) error {
+	done := is.observe("SaveBook")
+	err := is.Storage.SaveBook(book, origin)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) SaveBooks(books []models.Book, origin models.AuditEntry) error {
+	done := is.observe("SaveBooks")
+	err := is.Storage.SaveBooks(books, origin)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetUser(uid string) (models.User, error) {
+	done := is.observe("GetUser")
+	res, err := is.Storage.GetUser(uid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetBooks() ([]models.Book, error) {
+	done := is.observe("GetBooks")
+	res, err := is.Storage.GetBooks()
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetBook(bid string) (models.Book, error) {
+	done := is.observe("GetBook")
+	res, err := is.Storage.GetBook(bid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetBooksAt(at time.Time) ([]models.Book, error) {
+	done := is.observe("GetBooksAt")
+	res, err := is.Storage.GetBooksAt(at)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetBookAt(bid string, at time.Time) (models.Book, error) {
+	done := is.observe("GetBookAt")
+	res, err := is.Storage.GetBookAt(bid, at)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) FindBook(lable, author string) (models.Book, error) {
+	done := is.observe("FindBook")
+	res, err := is.Storage.FindBook(lable, author)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetBooksPage(after string, limit int) ([]models.Book, error) {
+	done := is.observe("GetBooksPage")
+	res, err := is.Storage.GetBooksPage(after, limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SetCover(bid, version, contentType string, origin models.AuditEntry) error {
+	done := is.observe("SetCover")
+	err := is.Storage.SetCover(bid, version, contentType, origin)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) SetDeleteStatus(bid string, origin models.AuditEntry) error {
+	done := is.observe("SetDeleteStatus")
+	err := is.Storage.SetDeleteStatus(bid, origin)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetTrash() ([]models.TrashedBook, error) {
+	done := is.observe("GetTrash")
+	res, err := is.Storage.GetTrash()
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) RestoreBook(bid string, origin models.AuditEntry) error {
+	done := is.observe("RestoreBook")
+	err := is.Storage.RestoreBook(bid, origin)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) PurgeBook(bid string, origin models.AuditEntry) error {
+	done := is.observe("PurgeBook")
+	err := is.Storage.PurgeBook(bid, origin)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) PurgeBooks(before time.Time, limit int) (int, error) {
+	done := is.observe("PurgeBooks")
+	res, err := is.Storage.PurgeBooks(before, limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SaveItem(item models.Item, origin models.AuditEntry) (string, error) {
+	done := is.observe("SaveItem")
+	res, err := is.Storage.SaveItem(item, origin)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetItems(bid string) ([]models.Item, error) {
+	done := is.observe("GetItems")
+	res, err := is.Storage.GetItems(bid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetItem(barcode string) (models.Item, error) {
+	done := is.observe("GetItem")
+	res, err := is.Storage.GetItem(barcode)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) UpdateItem(item models.Item, origin models.AuditEntry) (models.Item, error) {
+	done := is.observe("UpdateItem")
+	res, err := is.Storage.UpdateItem(item, origin)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) CheckoutItem(barcode, uid string, period time.Duration) (models.Loan, error) {
+	done := is.observe("CheckoutItem")
+	res, err := is.Storage.CheckoutItem(barcode, uid, period)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ReturnItem(barcode, branch string) (models.Loan, error) {
+	done := is.observe("ReturnItem")
+	res, err := is.Storage.ReturnItem(barcode, branch)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SetUserRole(uid, role string, origin models.AuditEntry) error {
+	done := is.observe("SetUserRole")
+	err := is.Storage.SetUserRole(uid, role, origin)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) SaveBranch(branch models.Branch) error {
+	done := is.observe("SaveBranch")
+	err := is.Storage.SaveBranch(branch)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetBranches() ([]models.Branch, error) {
+	done := is.observe("GetBranches")
+	res, err := is.Storage.GetBranches()
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetBranchBooks(branch string) ([]models.Book, error) {
+	done := is.observe("GetBranchBooks")
+	res, err := is.Storage.GetBranchBooks(branch)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetAvailability(bid string) ([]models.Availability, error) {
+	done := is.observe("GetAvailability")
+	res, err := is.Storage.GetAvailability(bid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) PlaceHold(hold models.Hold) (models.Hold, error) {
+	done := is.observe("PlaceHold")
+	res, err := is.Storage.PlaceHold(hold)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetHolds(uid string) ([]models.Hold, error) {
+	done := is.observe("GetHolds")
+	res, err := is.Storage.GetHolds(uid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) CancelHold(hid, uid string) error {
+	done := is.observe("CancelHold")
+	err := is.Storage.CancelHold(hid, uid)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) SaveTransfer(transfer models.Transfer) (models.Transfer, error) {
+	done := is.observe("SaveTransfer")
+	res, err := is.Storage.SaveTransfer(transfer)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetTransfers(status string) ([]models.Transfer, error) {
+	done := is.observe("GetTransfers")
+	res, err := is.Storage.GetTransfers(status)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ShipTransfer(tid string) (models.Transfer, error) {
+	done := is.observe("ShipTransfer")
+	res, err := is.Storage.ShipTransfer(tid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ReceiveTransfer(tid string) (models.Transfer, error) {
+	done := is.observe("ReceiveTransfer")
+	res, err := is.Storage.ReceiveTransfer(tid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SaveImport(job models.ImportJob) error {
+	done := is.observe("SaveImport")
+	err := is.Storage.SaveImport(job)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) UpdateImport(job models.ImportJob, rowErrs []models.ImportError) error {
+	done := is.observe("UpdateImport")
+	err := is.Storage.UpdateImport(job, rowErrs)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetImport(id string) (models.ImportJob, error) {
+	done := is.observe("GetImport")
+	res, err := is.Storage.GetImport(id)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetImportErrors(id string) ([]models.ImportError, error) {
+	done := is.observe("GetImportErrors")
+	res, err := is.Storage.GetImportErrors(id)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) UnfinishedImports(before time.Time) ([]models.ImportJob, error) {
+	done := is.observe("UnfinishedImports")
+	res, err := is.Storage.UnfinishedImports(before)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetAudit(entity, id string, limit int) ([]models.AuditEntry, error) {
+	done := is.observe("GetAudit")
+	res, err := is.Storage.GetAudit(entity, id, limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) PurgeAudit(before time.Time) (int, error) {
+	done := is.observe("PurgeAudit")
+	res, err := is.Storage.PurgeAudit(before)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) PendingEvents(limit int) ([]models.Event, error) {
+	done := is.observe("PendingEvents")
+	res, err := is.Storage.PendingEvents(limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) MarkPublished(ids []int64) error {
+	done := is.observe("MarkPublished")
+	err := is.Storage.MarkPublished(ids)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) EventsAfter(after int64, limit int) ([]models.Event, error) {
+	done := is.observe("EventsAfter")
+	res, err := is.Storage.EventsAfter(after, limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) MarkOverdueLoans(now time.Time) (int, error) {
+	done := is.observe("MarkOverdueLoans")
+	res, err := is.Storage.MarkOverdueLoans(now)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SaveWebhook(webhook models.Webhook) error {
+	done := is.observe("SaveWebhook")
+	err := is.Storage.SaveWebhook(webhook)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetWebhooks() ([]models.Webhook, error) {
+	done := is.observe("GetWebhooks")
+	res, err := is.Storage.GetWebhooks()
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetWebhook(id string) (models.Webhook, error) {
+	done := is.observe("GetWebhook")
+	res, err := is.Storage.GetWebhook(id)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) DeleteWebhook(id string) error {
+	done := is.observe("DeleteWebhook")
+	err := is.Storage.DeleteWebhook(id)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) EnqueueDeliveries(event models.Event, payload []byte) (int, error) {
+	done := is.observe("EnqueueDeliveries")
+	res, err := is.Storage.EnqueueDeliveries(event, payload)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) DueDeliveries(now time.Time, limit int) ([]models.DeliveryTask, error) {
+	done := is.observe("DueDeliveries")
+	res, err := is.Storage.DueDeliveries(now, limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SaveAttempt(delivery models.Delivery, attempt models.DeliveryAttempt) error {
+	done := is.observe("SaveAttempt")
+	err := is.Storage.SaveAttempt(delivery, attempt)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetDeliveries(
+	webhookID string, status models.DeliveryStatus, limit int,
+) ([]models.Delivery, error) {
+	done := is.observe("GetDeliveries")
+	res, err := is.Storage.GetDeliveries(webhookID, status, limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetDelivery(id int64) (models.Delivery, error) {
+	done := is.observe("GetDelivery")
+	res, err := is.Storage.GetDelivery(id)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetDeliveryAttempts(id int64) ([]models.DeliveryAttempt, error) {
+	done := is.observe("GetDeliveryAttempts")
+	res, err := is.Storage.GetDeliveryAttempts(id)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ReplayDelivery(id int64) (models.Delivery, error) {
+	done := is.observe("ReplayDelivery")
+	res, err := is.Storage.ReplayDelivery(id)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SaveDeskAlert(alert models.DeskAlert) (models.DeskAlert, error) {
+	done := is.observe("SaveDeskAlert")
+	res, err := is.Storage.SaveDeskAlert(alert)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetDeskAlerts(branch string) ([]models.DeskAlert, error) {
+	done := is.observe("GetDeskAlerts")
+	res, err := is.Storage.GetDeskAlerts(branch)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) AckDeskAlert(id int64, branch, uid string) (models.DeskAlert, error) {
+	done := is.observe("AckDeskAlert")
+	res, err := is.Storage.AckDeskAlert(id, branch, uid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) GetNotifyPrefs(uid string) (models.NotifyPrefs, error) {
+	done := is.observe("GetNotifyPrefs")
+	res, err := is.Storage.GetNotifyPrefs(uid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SetNotifyPrefs(prefs models.NotifyPrefs) error {
+	done := is.observe("SetNotifyPrefs")
+	err := is.Storage.SetNotifyPrefs(prefs)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) LoansDue(before time.Time) ([]models.LoanDue, error) {
+	done := is.observe("LoansDue")
+	res, err := is.Storage.LoansDue(before)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ClaimNotice(notice models.NoticeLog) (models.NoticeLog, error) {
+	done := is.observe("ClaimNotice")
+	res, err := is.Storage.ClaimNotice(notice)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) FinishNotice(id int64, status models.NoticeStatus, errText string) error {
+	done := is.observe("FinishNotice")
+	err := is.Storage.FinishNotice(id, status, errText)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetNoticeLog(uid string, limit int) ([]models.NoticeLog, error) {
+	done := is.observe("GetNoticeLog")
+	res, err := is.Storage.GetNoticeLog(uid, limit)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) SaveNotification(notification models.Notification) error {
+	done := is.observe("SaveNotification")
+	err := is.Storage.SaveNotification(notification)
+	done(err)
+	return err
+}
+
+func (is instrumentedStorage) GetNotifications(
+	uid string, before int64, limit int, unread bool,
+) ([]models.Notification, error) {
+	done := is.observe("GetNotifications")
+	res, err := is.Storage.GetNotifications(uid, before, limit, unread)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) CountUnread(uid string) (int, error) {
+	done := is.observe("CountUnread")
+	res, err := is.Storage.CountUnread(uid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ReadNotification(id int64, uid string) (models.Notification, error) {
+	done := is.observe("ReadNotification")
+	res, err := is.Storage.ReadNotification(id, uid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) ReadAllNotifications(uid string) (int, error) {
+	done := is.observe("ReadAllNotifications")
+	res, err := is.Storage.ReadAllNotifications(uid)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) PurgeNotifications(readBefore time.Time) (int, error) {
+	done := is.observe("PurgeNotifications")
+	res, err := is.Storage.PurgeNotifications(readBefore)
+	done(err)
+	return res, err
+}
+
+func (is instrumentedStorage) LibraryStats() (models.LibraryStats, error) {
+	done := is.observe("LibraryStats")
+	res, err := is.Storage.LibraryStats()
+	done(err)
+	return res, err
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/metrics"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestInstrumentedStorage(t *testing.T) {
+	m := metrics.New()
+	storMock := mocks.NewStorage(t)
+	storMock.On("GetBook", "BID1").Return(models.Book{BID: "BID1"}, nil).Once()
+	storMock.On("GetBook", "BID2").Return(models.Book{}, storerrros.ErrBookNoExist).Once()
+	storMock.On("SetDeleteStatus", "BID1", models.AuditEntry{Actor: "UID1"}).Return(nil).Once()
+	stor := instrumentedStorage{Storage: storMock, metrics: m}
+
+	book, err := stor.GetBook("BID1")
+	assert.NoError(t, err)
+	assert.Equal(t, "BID1", book.BID)
+	_, err = stor.GetBook("BID2")
+	assert.ErrorIs(t, err, storerrros.ErrBookNoExist)
+	assert.NoError(t, stor.SetDeleteStatus("BID1", models.AuditEntry{Actor: "UID1"}))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `bookly_storage_duration_seconds_count{method="GetBook"} 2`)
+	assert.Contains(t, string(body), `bookly_storage_errors_total{method="GetBook"} 1`)
+	assert.Contains(t, string(body), `bookly_storage_duration_seconds_count{method="SetDeleteStatus"} 1`)
+	assert.NotContains(t, string(body), `bookly_storage_errors_total{method="SetDeleteStatus"}`)
+}
+
+func TestTracedStorage(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	storMock := mocks.NewStorage(t)
+	storMock.On("GetBook", "BID2").Return(models.Book{}, storerrros.ErrBookNoExist).Once()
+	srv := Server{storage: instrumentedStorage{Storage: storMock, metrics: metrics.New()}}
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "GET /books/:id")
+	_, err := srv.db(ctx).GetBook("BID2")
+	assert.ErrorIs(t, err, storerrros.ErrBookNoExist)
+	parent.End()
+
+	spans := rec.Ended()
+	require.Len(t, spans, 2)
+	assert.Equal(t, "storage GetBook", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.Equal(t, storerrros.ErrBookNoExist.Error(), spans[0].Status().Description)
+}
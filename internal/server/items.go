@@ -0,0 +1,221 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+)
+
+type returnRequest struct {
+	Barcode string `json:"barcode" validate:"required"`
+	Branch  string `json:"branch"`
+}
+
+func (s *Server) bookItems(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	items, err := s.db(ctx).GetItems(ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, storerrros.ErrItemNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, items)
+}
+
+func (s *Server) addItem(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	var item models.Item
+	if err := ctx.ShouldBindBodyWithJSON(&item); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(item); err != nil {
+		log.Error().Err(err).Msg("validate item failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	item.BID = ctx.Param("id")
+	item.Status = models.ItemAvailable
+	barcode, err := s.db(ctx).SaveItem(item, auditOrigin(ctx))
+	if err != nil {
+		log.Error().Err(err).Msg("save item failed")
+		switch {
+		case errors.Is(err, storerrros.ErrBookNoExist), errors.Is(err, storerrros.ErrBranchNoExist):
+			ctx.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, storerrros.ErrItemExists):
+			ctx.String(http.StatusConflict, err.Error())
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"barcode": barcode})
+}
+
+func (s *Server) itemInfo(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	item, err := s.db(ctx).GetItem(ctx.Param("barcode"))
+	if err != nil {
+		if errors.Is(err, storerrros.ErrItemNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
+
+// updateItem changes condition, shelf location, home branch or status of a copy.
+// Checked out copies have to be returned before their status can be changed,
+// the current branch changes only through returns and transfers. A copy made available
+// is offered to the holds queue first, so the response may show it on hold.
+func (s *Server) updateItem(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	var update models.Item
+	if err := ctx.ShouldBindBodyWithJSON(&update); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(update); err != nil {
+		log.Error().Err(err).Msg("validate item failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if update.Status == models.ItemCheckedOut {
+		ctx.String(http.StatusBadRequest, "use checkout to issue the item")
+		return
+	}
+	item, err := s.db(ctx).GetItem(ctx.Param("barcode"))
+	if err != nil {
+		if errors.Is(err, storerrros.ErrItemNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if update.Status != "" && item.Status != models.ItemAvailable && item.Status != models.ItemDamaged &&
+		item.Status != models.ItemLost {
+		ctx.String(http.StatusConflict, storerrros.ErrItemUnavailable.Error())
+		return
+	}
+	if update.Condition != "" {
+		item.Condition = update.Condition
+	}
+	if update.Location != "" {
+		item.Location = update.Location
+	}
+	if update.Status != "" {
+		item.Status = update.Status
+	}
+	if update.HomeBranch != "" {
+		item.HomeBranch = update.HomeBranch
+		if item.CurrentBranch == "" {
+			item.CurrentBranch = update.HomeBranch
+		}
+	}
+	if item, err = s.db(ctx).UpdateItem(item, auditOrigin(ctx)); err != nil {
+		log.Error().Err(err).Msg("update item failed")
+		if errors.Is(err, storerrros.ErrBranchNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, item)
+}
+
+func (s *Server) checkout(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	loan, err := s.db(ctx).CheckoutItem(ctx.Param("barcode"), uid, s.loanPeriod())
+	if err != nil {
+		log.Error().Err(err).Msg("checkout failed")
+		switch {
+		case errors.Is(err, storerrros.ErrItemNoExist):
+			ctx.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, storerrros.ErrItemUnavailable):
+			ctx.String(http.StatusConflict, err.Error())
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.JSON(http.StatusCreated, loan)
+}
+
+func (s *Server) bookReturn(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	var req returnRequest
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(req); err != nil {
+		log.Error().Err(err).Msg("validate return request failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	loan, err := s.db(ctx).ReturnItem(req.Barcode, req.Branch)
+	if err != nil {
+		log.Error().Err(err).Msg("return failed")
+		if errors.Is(err, storerrros.ErrItemNotOnLoan) {
+			ctx.String(http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, storerrros.ErrBranchNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, loan)
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/config"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckout(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.Reload(config.Config{Loan: config.LoanConfig{Period: 7 * 24 * time.Hour}})
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/items/:barcode/checkout", srv.JWTAuthMiddleware(), srv.checkout)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	issued := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name     string
+		barcode  string
+		loan     models.Loan
+		mockFlag bool
+		err      error
+		want     want
+	}
+	tests := []test{
+		{
+			name:     "successful checkout",
+			barcode:  "BK00000001",
+			mockFlag: true,
+			loan: models.Loan{
+				LID:      "LID1",
+				Barcode:  "BK00000001",
+				BID:      "BID1",
+				UID:      "test-uid",
+				IssuedAt: issued,
+				DueAt:    issued.Add(14 * 24 * time.Hour),
+			},
+			want: want{
+				body: `{"lid":"LID1","barcode":"BK00000001","bid":"BID1","uid":"test-uid",` +
+					`"issued_at":"2024-10-01T12:00:00Z","due_at":"2024-10-15T12:00:00Z"}`,
+				statusCode: http.StatusCreated,
+			},
+		},
+		{
+			name:     "unknown barcode",
+			barcode:  "BK404",
+			mockFlag: true,
+			err:      storerrros.ErrItemNoExist,
+			want: want{
+				body:       `item does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:     "item already out",
+			barcode:  "BK00000002",
+			mockFlag: true,
+			err:      storerrros.ErrItemUnavailable,
+			want: want{
+				body:       `item is not available for checkout`,
+				statusCode: http.StatusConflict,
+			},
+		},
+		{
+			name:     "error call",
+			barcode:  "BK00000003",
+			mockFlag: true,
+			err:      errors.New("test err"),
+			want: want{
+				body:       `{"error":"test err"}`,
+				statusCode: http.StatusInternalServerError,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.mockFlag {
+				storMock.On("CheckoutItem", tc.barcode, "test-uid", 7*24*time.Hour).Return(tc.loan, tc.err)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/items/" + tc.barcode + "/checkout"
+			req.SetHeader("Authorization", jwt)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
+
+func TestBookReturn(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.POST("/book-return", srv.JWTAuthMiddleware(), srv.bookReturn)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	issued := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+	returned := issued.Add(48 * time.Hour)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name     string
+		body     string
+		barcode  string
+		branch   string
+		loan     models.Loan
+		mockFlag bool
+		err      error
+		want     want
+	}
+	tests := []test{
+		{
+			name:     "successful return",
+			body:     `{"barcode":"BK00000001"}`,
+			barcode:  "BK00000001",
+			mockFlag: true,
+			loan: models.Loan{
+				LID:        "LID1",
+				Barcode:    "BK00000001",
+				BID:        "BID1",
+				UID:        "test-uid",
+				IssuedAt:   issued,
+				DueAt:      issued.Add(14 * 24 * time.Hour),
+				ReturnedAt: &returned,
+			},
+			want: want{
+				body: `{"lid":"LID1","barcode":"BK00000001","bid":"BID1","uid":"test-uid",` +
+					`"issued_at":"2024-10-01T12:00:00Z","due_at":"2024-10-15T12:00:00Z",` +
+					`"returned_at":"2024-10-03T12:00:00Z"}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "missing barcode",
+			body: `{}`,
+			want: want{
+				body: `{"error":"Key: 'returnRequest.Barcode' Error:Field validation ` +
+					`for 'Barcode' failed on the 'required' tag"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:     "return at another branch",
+			body:     `{"barcode":"BK00000001","branch":"north"}`,
+			barcode:  "BK00000001",
+			branch:   "north",
+			mockFlag: true,
+			loan: models.Loan{
+				LID:        "LID1",
+				Barcode:    "BK00000001",
+				BID:        "BID1",
+				UID:        "test-uid",
+				IssuedAt:   issued,
+				DueAt:      issued.Add(14 * 24 * time.Hour),
+				ReturnedAt: &returned,
+			},
+			want: want{
+				body: `{"lid":"LID1","barcode":"BK00000001","bid":"BID1","uid":"test-uid",` +
+					`"issued_at":"2024-10-01T12:00:00Z","due_at":"2024-10-15T12:00:00Z",` +
+					`"returned_at":"2024-10-03T12:00:00Z"}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:     "unknown branch",
+			body:     `{"barcode":"BK00000001","branch":"nowhere"}`,
+			barcode:  "BK00000001",
+			branch:   "nowhere",
+			mockFlag: true,
+			err:      storerrros.ErrBranchNoExist,
+			want: want{
+				body:       `branch does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:     "item not on loan",
+			body:     `{"barcode":"BK00000002"}`,
+			barcode:  "BK00000002",
+			mockFlag: true,
+			err:      storerrros.ErrItemNotOnLoan,
+			want: want{
+				body:       `item is not on loan`,
+				statusCode: http.StatusConflict,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.mockFlag {
+				storMock.On("ReturnItem", tc.barcode, tc.branch).Return(tc.loan, tc.err)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = http.MethodPost
+			req.URL = httpSrv.URL + "/book-return"
+			req.SetHeader("Authorization", jwt)
+			req.SetBody(tc.body)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/worker"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	purgeJob   = "purge-books"
+	relayJob   = "outbox-relay"
+	webhookJob = "webhook-deliveries"
+	overdueJob = "overdue-loans"
+)
+
+// purgeBooks removes for good the books deleted longer than the retention period ago.
+// It works in batches so a large backlog does not hold one long statement.
+func (s *Server) purgeBooks(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	before := time.Now().Add(-s.purge.Retention)
+	total := 0
+	for ctx.Err() == nil {
+		purged, err := s.db(ctx).PurgeBooks(before, consts.PurgeBatchSize)
+		total += purged
+		if err != nil {
+			return err
+		}
+		if purged < consts.PurgeBatchSize {
+			break
+		}
+	}
+	log.Info().Int("purged", total).Time("before", before).Msg("deleted books purged")
+	return ctx.Err()
+}
+
+// allJobs lists the background jobs with the statistics of their runs.
+func (s *Server) allJobs(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, s.jobs.Stats())
+}
+
+// runJob asks for a run of the job right away. The run happens in the background.
+func (s *Server) runJob(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	name := ctx.Param("name")
+	if err := s.jobs.Trigger(name); err != nil {
+		if errors.Is(err, worker.ErrUnknownJob) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Info().Str("job", name).Msg("job triggered")
+	ctx.String(http.StatusAccepted, "job "+name+" was triggered")
+}
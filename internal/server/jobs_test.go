@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/config"
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPurgeBooks(t *testing.T) {
+	logger.Get(false)
+	type test struct {
+		name    string
+		batches []int
+		err     error
+	}
+	tests := []test{
+		{
+			name:    "single batch",
+			batches: []int{3},
+		},
+		{
+			name:    "full batches are repeated",
+			batches: []int{consts.PurgeBatchSize, consts.PurgeBatchSize, 0},
+		},
+		{
+			name:    "storage error",
+			batches: []int{0},
+			err:     errors.New("connection refused"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			for _, n := range tc.batches {
+				storMock.On("PurgeBooks", mock.MatchedBy(func(before time.Time) bool {
+					return time.Since(before) >= 24*time.Hour
+				}), consts.PurgeBatchSize).Return(n, tc.err).Once()
+			}
+			srv := Server{storage: storMock, purge: config.PurgeConfig{Retention: 24 * time.Hour}}
+			err := srv.purgeBooks(context.Background())
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
+
+func TestJobsAdmin(t *testing.T) {
+	logger.Get(false)
+	storMock := mocks.NewStorage(t)
+	storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: models.RoleAdmin}, nil)
+	purged := make(chan struct{})
+	storMock.On("PurgeBooks", mock.Anything, consts.PurgeBatchSize).Return(0, nil).
+		Run(func(mock.Arguments) { close(purged) }).Once()
+	storMock.On("PendingEvents", consts.OutboxBatchSize).Return(nil, nil).Maybe()
+	storMock.On("DueDeliveries", mock.Anything, consts.WebhookBatchSize).Return(nil, nil).Maybe()
+	storMock.On("MarkOverdueLoans", mock.Anything).Return(0, nil).Maybe()
+	srv := New(config.Config{Purge: config.PurgeConfig{Retention: time.Hour}}, storMock, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.jobs.Run(ctx)
+
+	r := gin.New()
+	r.GET("/admin/jobs", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleAdmin), srv.allJobs)
+	r.POST("/admin/jobs/:name/run", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleAdmin), srv.runJob)
+	httpSrv := httptest.NewServer(r)
+	defer httpSrv.Close()
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+
+	resp, err := resty.New().R().SetHeader("Authorization", jwt).Post(httpSrv.URL + "/admin/jobs/unknown/run")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	resp, err = resty.New().R().SetHeader("Authorization", jwt).Post(httpSrv.URL + "/admin/jobs/" + purgeJob + "/run")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode())
+	select {
+	case <-purged:
+	case <-time.After(time.Second):
+		t.Fatal("purge job did not run")
+	}
+
+	assert.Eventually(t, func() bool {
+		var stats []struct {
+			Name        string     `json:"name"`
+			Runs        int        `json:"runs"`
+			LastSuccess *time.Time `json:"last_success"`
+		}
+		resp, err = resty.New().R().SetHeader("Authorization", jwt).SetResult(&stats).Get(httpSrv.URL + "/admin/jobs")
+		return err == nil && len(stats) == 5 && stats[2].Name == purgeJob &&
+			stats[2].Runs == 1 && stats[2].LastSuccess != nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// maxRequestIDLen bounds the request ID taken from the client, a longer one is replaced.
+const maxRequestIDLen = 128
+
+// requestID keeps the request ID sent by the client or makes a new one and returns it in the response.
+// The request gets a logger of its own with the ID and the route, handlers log through it with
+// logger.FromContext. JWTAuthMiddleware adds the user to it.
+func requestID() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id := ctx.GetHeader(requestIDHeader)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = uuid.New().String()
+		}
+		ctx.Set(requestIDKey, id)
+		ctx.Header(requestIDHeader, id)
+		log := logger.FromContext(ctx.Request.Context()).With().
+			Str(requestIDKey, id).
+			Str("route", ctx.FullPath()).
+			Ctx(ctx.Request.Context()).
+			Logger()
+		setLogger(ctx, log)
+		ctx.Next()
+	}
+}
+
+func setLogger(ctx *gin.Context, log zerolog.Logger) {
+	ctx.Request = ctx.Request.WithContext(logger.WithContext(ctx.Request.Context(), log))
+}
+
+// accessLog logs every request once it is served: client errors as warnings, server errors as errors.
+func accessLog() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		log := logger.FromContext(ctx.Request.Context())
+		status := ctx.Writer.Status()
+		event := log.Info()
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = log.Error()
+		case status >= http.StatusBadRequest:
+			event = log.Warn()
+		}
+		if len(ctx.Errors) > 0 {
+			event = event.Str("errors", ctx.Errors.String())
+		}
+		event.Str("method", ctx.Request.Method).
+			Str("path", ctx.Request.URL.Path).
+			Int("status", status).
+			Dur("latency", time.Since(start)).
+			Int("size", ctx.Writer.Size()).
+			Str("client_ip", ctx.ClientIP()).
+			Msg("request served")
+	}
+}
+
+// recovery answers 500 to a request whose handler panicked and logs the panic with the stack.
+// Panics on a connection the client has closed are left to gin and not logged.
+func recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, err any) {
+		log := logger.FromContext(ctx.Request.Context())
+		log.Error().Any("panic", err).Str("stack", string(debug.Stack())).Msg("handler panicked")
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
+type logLevelRequest struct {
+	Level string `json:"level" validate:"required,oneof=trace debug info warn error"`
+}
+
+func (s *Server) logLevel(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"level": logger.Level()})
+}
+
+// setLogLevel changes the log level of the running server until the next change or restart.
+func (s *Server) setLogLevel(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	var req logLevelRequest
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(req); err != nil {
+		log.Error().Err(err).Msg("validate log level failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	before := logger.Level()
+	if err := logger.SetLevel(req.Level); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Logged without a level so that the change shows whatever the new level is.
+	log.Log().Str("before", before).Str("after", req.Level).Msg("log level changed")
+	ctx.JSON(http.StatusOK, gin.H{"level": logger.Level()})
+}
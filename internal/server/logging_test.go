@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestLogger(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	var buf bytes.Buffer
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(logger.WithContext(ctx.Request.Context(), zerolog.New(&buf)))
+	})
+	r.Use(requestID(), accessLog(), recovery())
+	r.GET("/books/:id", srv.JWTAuthMiddleware(), func(ctx *gin.Context) {
+		log := logger.FromContext(ctx)
+		log.Info().Msg("book asked")
+		ctx.Status(http.StatusNoContent)
+	})
+	r.GET("/panic", func(*gin.Context) { panic("boom") })
+	jwt, err := createJWTToken("test-uid")
+	require.NoError(t, err)
+
+	readLines := func() []map[string]any {
+		var lines []map[string]any
+		sc := bufio.NewScanner(&buf)
+		sc.Buffer(nil, 1<<20)
+		for sc.Scan() {
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(sc.Bytes(), &line))
+			lines = append(lines, line)
+		}
+		buf.Reset()
+		return lines
+	}
+
+	t.Run("handler and access log", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/books/BID1", nil)
+		req.Header.Set("Authorization", jwt)
+		req.Header.Set(requestIDHeader, "req-42")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, "req-42", rec.Header().Get(requestIDHeader))
+
+		lines := readLines()
+		require.Len(t, lines, 2)
+		for _, line := range lines {
+			assert.Equal(t, "req-42", line[requestIDKey])
+			assert.Equal(t, "/books/:id", line["route"])
+			assert.Equal(t, "test-uid", line["uid"])
+		}
+		assert.Equal(t, "book asked", lines[0]["message"])
+		assert.Equal(t, "request served", lines[1]["message"])
+		assert.Equal(t, "/books/BID1", lines[1]["path"])
+		assert.EqualValues(t, http.StatusNoContent, lines[1]["status"])
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.NotEmpty(t, rec.Header().Get(requestIDHeader))
+
+		lines := readLines()
+		require.Len(t, lines, 2)
+		assert.Equal(t, "handler panicked", lines[0]["message"])
+		assert.Equal(t, "boom", lines[0]["panic"])
+		assert.Contains(t, lines[0]["stack"], "logging_test.go")
+		assert.Equal(t, "error", lines[1][zerolog.LevelFieldName])
+		assert.EqualValues(t, http.StatusInternalServerError, lines[1]["status"])
+		assert.Nil(t, lines[1]["uid"])
+	})
+}
+
+func TestSetLogLevel(t *testing.T) {
+	logger.Get(false)
+	defer zerolog.SetGlobalLevel(zerolog.GlobalLevel())
+	require.NoError(t, logger.SetLevel("info"))
+	srv := Server{valid: validator.New()}
+	r := gin.New()
+	r.GET("/admin/log-level", srv.logLevel)
+	r.PUT("/admin/log-level", srv.setLogLevel)
+	httpSrv := httptest.NewServer(r)
+	defer httpSrv.Close()
+
+	type want struct {
+		body       string
+		statusCode int
+		level      string
+	}
+	type test struct {
+		name string
+		body string
+		want want
+	}
+	tests := []test{
+		{
+			name: "debug",
+			body: `{"level":"debug"}`,
+			want: want{body: `{"level":"debug"}`, statusCode: http.StatusOK, level: "debug"},
+		},
+		{
+			name: "unknown level",
+			body: `{"level":"loud"}`,
+			want: want{
+				body:       `{"error":"Key: 'logLevelRequest.Level' Error:Field validation for 'Level' failed on the 'oneof' tag"}`,
+				statusCode: http.StatusBadRequest,
+				level:      "debug",
+			},
+		},
+		{
+			name: "bad body",
+			body: `level=warn`,
+			want: want{body: `incorrectly entered data`, statusCode: http.StatusBadRequest, level: "debug"},
+		},
+		{
+			name: "back to warn",
+			body: `{"level":"warn"}`,
+			want: want{body: `{"level":"warn"}`, statusCode: http.StatusOK, level: "warn"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := resty.New().R().SetBody(tc.body).Put(httpSrv.URL + "/admin/log-level")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+
+			resp, err = resty.New().R().Get(httpSrv.URL + "/admin/log-level")
+			assert.NoError(t, err)
+			assert.Equal(t, `{"level":"`+tc.want.level+`"}`, string(resp.Body()))
+		})
+	}
+}
@@ -5,6 +5,7 @@ package mocks
 import (
 	models "github.com/Dorrrke/g3-bookly/internal/domain/models"
 	mock "github.com/stretchr/testify/mock"
+	time "time"
 )
 
 // Storage is an autogenerated mock type for the Storage type
@@ -12,17 +13,1439 @@ type Storage struct {
 	mock.Mock
 }
 
-// DeleteBooks provides a mock function with given fields:
-func (_m *Storage) DeleteBooks() error {
+// AckDeskAlert provides a mock function with given fields: id, branch, uid
+func (_m *Storage) AckDeskAlert(id int64, branch string, uid string) (models.DeskAlert, error) {
+	ret := _m.Called(id, branch, uid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AckDeskAlert")
+	}
+
+	var r0 models.DeskAlert
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, string, string) (models.DeskAlert, error)); ok {
+		return rf(id, branch, uid)
+	}
+	if rf, ok := ret.Get(0).(func(int64, string, string) models.DeskAlert); ok {
+		r0 = rf(id, branch, uid)
+	} else {
+		r0 = ret.Get(0).(models.DeskAlert)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, string, string) error); ok {
+		r1 = rf(id, branch, uid)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CancelHold provides a mock function with given fields: hid, uid
+func (_m *Storage) CancelHold(hid string, uid string) error {
+	ret := _m.Called(hid, uid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CancelHold")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string) error); ok {
+		r0 = rf(hid, uid)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CheckoutItem provides a mock function with given fields: barcode, uid, period
+func (_m *Storage) CheckoutItem(barcode string, uid string, period time.Duration) (models.Loan, error) {
+	ret := _m.Called(barcode, uid, period)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CheckoutItem")
+	}
+
+	var r0 models.Loan
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, time.Duration) (models.Loan, error)); ok {
+		return rf(barcode, uid, period)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, time.Duration) models.Loan); ok {
+		r0 = rf(barcode, uid, period)
+	} else {
+		r0 = ret.Get(0).(models.Loan)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, time.Duration) error); ok {
+		r1 = rf(barcode, uid, period)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimNotice provides a mock function with given fields: _a0
+func (_m *Storage) ClaimNotice(_a0 models.NoticeLog) (models.NoticeLog, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimNotice")
+	}
+
+	var r0 models.NoticeLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.NoticeLog) (models.NoticeLog, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(models.NoticeLog) models.NoticeLog); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.NoticeLog)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.NoticeLog) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountUnread provides a mock function with given fields: uid
+func (_m *Storage) CountUnread(uid string) (int, error) {
+	ret := _m.Called(uid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountUnread")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (int, error)); ok {
+		return rf(uid)
+	}
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(uid)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(uid)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteWebhook provides a mock function with given fields: _a0
+func (_m *Storage) DeleteWebhook(_a0 string) error {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteWebhook")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DueDeliveries provides a mock function with given fields: now, limit
+func (_m *Storage) DueDeliveries(now time.Time, limit int) ([]models.DeliveryTask, error) {
+	ret := _m.Called(now, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DueDeliveries")
+	}
+
+	var r0 []models.DeliveryTask
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time, int) ([]models.DeliveryTask, error)); ok {
+		return rf(now, limit)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time, int) []models.DeliveryTask); ok {
+		r0 = rf(now, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.DeliveryTask)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time, int) error); ok {
+		r1 = rf(now, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EnqueueDeliveries provides a mock function with given fields: event, payload
+func (_m *Storage) EnqueueDeliveries(event models.Event, payload []byte) (int, error) {
+	ret := _m.Called(event, payload)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnqueueDeliveries")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.Event, []byte) (int, error)); ok {
+		return rf(event, payload)
+	}
+	if rf, ok := ret.Get(0).(func(models.Event, []byte) int); ok {
+		r0 = rf(event, payload)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.Event, []byte) error); ok {
+		r1 = rf(event, payload)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EventsAfter provides a mock function with given fields: after, limit
+func (_m *Storage) EventsAfter(after int64, limit int) ([]models.Event, error) {
+	ret := _m.Called(after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EventsAfter")
+	}
+
+	var r0 []models.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int) ([]models.Event, error)); ok {
+		return rf(after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int) []models.Event); ok {
+		r0 = rf(after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int) error); ok {
+		r1 = rf(after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FindBook provides a mock function with given fields: lable, author
+func (_m *Storage) FindBook(lable string, author string) (models.Book, error) {
+	ret := _m.Called(lable, author)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FindBook")
+	}
+
+	var r0 models.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (models.Book, error)); ok {
+		return rf(lable, author)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) models.Book); ok {
+		r0 = rf(lable, author)
+	} else {
+		r0 = ret.Get(0).(models.Book)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(lable, author)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// FinishNotice provides a mock function with given fields: id, status, errText
+func (_m *Storage) FinishNotice(id int64, status models.NoticeStatus, errText string) error {
+	ret := _m.Called(id, status, errText)
+
+	if len(ret) == 0 {
+		panic("no return value specified for FinishNotice")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(int64, models.NoticeStatus, string) error); ok {
+		r0 = rf(id, status, errText)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// GetAudit provides a mock function with given fields: entity, id, limit
+func (_m *Storage) GetAudit(entity string, id string, limit int) ([]models.AuditEntry, error) {
+	ret := _m.Called(entity, id, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAudit")
+	}
+
+	var r0 []models.AuditEntry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int) ([]models.AuditEntry, error)); ok {
+		return rf(entity, id, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int) []models.AuditEntry); ok {
+		r0 = rf(entity, id, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.AuditEntry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int) error); ok {
+		r1 = rf(entity, id, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAvailability provides a mock function with given fields: _a0
+func (_m *Storage) GetAvailability(_a0 string) ([]models.Availability, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetAvailability")
+	}
+
+	var r0 []models.Availability
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.Availability, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.Availability); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Availability)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBook provides a mock function with given fields: _a0
+func (_m *Storage) GetBook(_a0 string) (models.Book, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBook")
+	}
+
+	var r0 models.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (models.Book, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) models.Book); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.Book)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBookAt provides a mock function with given fields: bid, at
+func (_m *Storage) GetBookAt(bid string, at time.Time) (models.Book, error) {
+	ret := _m.Called(bid, at)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBookAt")
+	}
+
+	var r0 models.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, time.Time) (models.Book, error)); ok {
+		return rf(bid, at)
+	}
+	if rf, ok := ret.Get(0).(func(string, time.Time) models.Book); ok {
+		r0 = rf(bid, at)
+	} else {
+		r0 = ret.Get(0).(models.Book)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, time.Time) error); ok {
+		r1 = rf(bid, at)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBooks provides a mock function with given fields:
+func (_m *Storage) GetBooks() ([]models.Book, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBooks")
+	}
+
+	var r0 []models.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]models.Book, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []models.Book); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBooksAt provides a mock function with given fields: _a0
+func (_m *Storage) GetBooksAt(_a0 time.Time) ([]models.Book, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBooksAt")
+	}
+
+	var r0 []models.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) ([]models.Book, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) []models.Book); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBooksPage provides a mock function with given fields: after, limit
+func (_m *Storage) GetBooksPage(after string, limit int) ([]models.Book, error) {
+	ret := _m.Called(after, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBooksPage")
+	}
+
+	var r0 []models.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int) ([]models.Book, error)); ok {
+		return rf(after, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, int) []models.Book); ok {
+		r0 = rf(after, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(after, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBranchBooks provides a mock function with given fields: _a0
+func (_m *Storage) GetBranchBooks(_a0 string) ([]models.Book, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBranchBooks")
+	}
+
+	var r0 []models.Book
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.Book, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.Book); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Book)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetBranches provides a mock function with given fields:
+func (_m *Storage) GetBranches() ([]models.Branch, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBranches")
+	}
+
+	var r0 []models.Branch
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]models.Branch, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []models.Branch); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Branch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveries provides a mock function with given fields: webhookID, status, limit
+func (_m *Storage) GetDeliveries(webhookID string, status models.DeliveryStatus, limit int) ([]models.Delivery, error) {
+	ret := _m.Called(webhookID, status, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeliveries")
+	}
+
+	var r0 []models.Delivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, models.DeliveryStatus, int) ([]models.Delivery, error)); ok {
+		return rf(webhookID, status, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, models.DeliveryStatus, int) []models.Delivery); ok {
+		r0 = rf(webhookID, status, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Delivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, models.DeliveryStatus, int) error); ok {
+		r1 = rf(webhookID, status, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDelivery provides a mock function with given fields: _a0
+func (_m *Storage) GetDelivery(_a0 int64) (models.Delivery, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDelivery")
+	}
+
+	var r0 models.Delivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (models.Delivery, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(int64) models.Delivery); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.Delivery)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeliveryAttempts provides a mock function with given fields: _a0
+func (_m *Storage) GetDeliveryAttempts(_a0 int64) ([]models.DeliveryAttempt, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeliveryAttempts")
+	}
+
+	var r0 []models.DeliveryAttempt
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) ([]models.DeliveryAttempt, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(int64) []models.DeliveryAttempt); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.DeliveryAttempt)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetDeskAlerts provides a mock function with given fields: branch
+func (_m *Storage) GetDeskAlerts(branch string) ([]models.DeskAlert, error) {
+	ret := _m.Called(branch)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDeskAlerts")
+	}
+
+	var r0 []models.DeskAlert
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.DeskAlert, error)); ok {
+		return rf(branch)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.DeskAlert); ok {
+		r0 = rf(branch)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.DeskAlert)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(branch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetHolds provides a mock function with given fields: _a0
+func (_m *Storage) GetHolds(_a0 string) ([]models.Hold, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetHolds")
+	}
+
+	var r0 []models.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.Hold, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.Hold); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Hold)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetImport provides a mock function with given fields: _a0
+func (_m *Storage) GetImport(_a0 string) (models.ImportJob, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetImport")
+	}
+
+	var r0 models.ImportJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (models.ImportJob, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) models.ImportJob); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.ImportJob)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetImportErrors provides a mock function with given fields: _a0
+func (_m *Storage) GetImportErrors(_a0 string) ([]models.ImportError, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetImportErrors")
+	}
+
+	var r0 []models.ImportError
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.ImportError, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.ImportError); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.ImportError)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetItem provides a mock function with given fields: _a0
+func (_m *Storage) GetItem(_a0 string) (models.Item, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetItem")
+	}
+
+	var r0 models.Item
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (models.Item, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) models.Item); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.Item)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetItems provides a mock function with given fields: _a0
+func (_m *Storage) GetItems(_a0 string) ([]models.Item, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetItems")
+	}
+
+	var r0 []models.Item
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.Item, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.Item); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Item)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNoticeLog provides a mock function with given fields: uid, limit
+func (_m *Storage) GetNoticeLog(uid string, limit int) ([]models.NoticeLog, error) {
+	ret := _m.Called(uid, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNoticeLog")
+	}
+
+	var r0 []models.NoticeLog
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int) ([]models.NoticeLog, error)); ok {
+		return rf(uid, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, int) []models.NoticeLog); ok {
+		r0 = rf(uid, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.NoticeLog)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int) error); ok {
+		r1 = rf(uid, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNotifications provides a mock function with given fields: uid, before, limit, unread
+func (_m *Storage) GetNotifications(uid string, before int64, limit int, unread bool) ([]models.Notification, error) {
+	ret := _m.Called(uid, before, limit, unread)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNotifications")
+	}
+
+	var r0 []models.Notification
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int64, int, bool) ([]models.Notification, error)); ok {
+		return rf(uid, before, limit, unread)
+	}
+	if rf, ok := ret.Get(0).(func(string, int64, int, bool) []models.Notification); ok {
+		r0 = rf(uid, before, limit, unread)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Notification)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int64, int, bool) error); ok {
+		r1 = rf(uid, before, limit, unread)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNotifyPrefs provides a mock function with given fields: uid
+func (_m *Storage) GetNotifyPrefs(uid string) (models.NotifyPrefs, error) {
+	ret := _m.Called(uid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNotifyPrefs")
+	}
+
+	var r0 models.NotifyPrefs
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (models.NotifyPrefs, error)); ok {
+		return rf(uid)
+	}
+	if rf, ok := ret.Get(0).(func(string) models.NotifyPrefs); ok {
+		r0 = rf(uid)
+	} else {
+		r0 = ret.Get(0).(models.NotifyPrefs)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(uid)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTransfers provides a mock function with given fields: _a0
+func (_m *Storage) GetTransfers(_a0 string) ([]models.Transfer, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTransfers")
+	}
+
+	var r0 []models.Transfer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]models.Transfer, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) []models.Transfer); ok {
+		r0 = rf(_a0)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Transfer)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTrash provides a mock function with given fields:
+func (_m *Storage) GetTrash() ([]models.TrashedBook, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetTrash")
+	}
+
+	var r0 []models.TrashedBook
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]models.TrashedBook, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []models.TrashedBook); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.TrashedBook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUser provides a mock function with given fields: _a0
+func (_m *Storage) GetUser(_a0 string) (models.User, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetUser")
+	}
+
+	var r0 models.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (models.User, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) models.User); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.User)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetWebhook provides a mock function with given fields: _a0
+func (_m *Storage) GetWebhook(_a0 string) (models.Webhook, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetWebhook")
+	}
+
+	var r0 models.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (models.Webhook, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) models.Webhook); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.Webhook)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetWebhooks provides a mock function with given fields:
+func (_m *Storage) GetWebhooks() ([]models.Webhook, error) {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetWebhooks")
+	}
+
+	var r0 []models.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func() ([]models.Webhook, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() []models.Webhook); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LibraryStats provides a mock function with given fields:
+func (_m *Storage) LibraryStats() (models.LibraryStats, error) {
 	ret := _m.Called()
 
 	if len(ret) == 0 {
-		panic("no return value specified for DeleteBooks")
+		panic("no return value specified for LibraryStats")
+	}
+
+	var r0 models.LibraryStats
+	var r1 error
+	if rf, ok := ret.Get(0).(func() (models.LibraryStats, error)); ok {
+		return rf()
+	}
+	if rf, ok := ret.Get(0).(func() models.LibraryStats); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(models.LibraryStats)
+	}
+
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// LoansDue provides a mock function with given fields: before
+func (_m *Storage) LoansDue(before time.Time) ([]models.LoanDue, error) {
+	ret := _m.Called(before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for LoansDue")
+	}
+
+	var r0 []models.LoanDue
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) ([]models.LoanDue, error)); ok {
+		return rf(before)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) []models.LoanDue); ok {
+		r0 = rf(before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.LoanDue)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkOverdueLoans provides a mock function with given fields: now
+func (_m *Storage) MarkOverdueLoans(now time.Time) (int, error) {
+	ret := _m.Called(now)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkOverdueLoans")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) (int, error)); ok {
+		return rf(now)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(now)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(now)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkPublished provides a mock function with given fields: ids
+func (_m *Storage) MarkPublished(ids []int64) error {
+	ret := _m.Called(ids)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkPublished")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]int64) error); ok {
+		r0 = rf(ids)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PendingEvents provides a mock function with given fields: limit
+func (_m *Storage) PendingEvents(limit int) ([]models.Event, error) {
+	ret := _m.Called(limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PendingEvents")
+	}
+
+	var r0 []models.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int) ([]models.Event, error)); ok {
+		return rf(limit)
+	}
+	if rf, ok := ret.Get(0).(func(int) []models.Event); ok {
+		r0 = rf(limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int) error); ok {
+		r1 = rf(limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PlaceHold provides a mock function with given fields: _a0
+func (_m *Storage) PlaceHold(_a0 models.Hold) (models.Hold, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PlaceHold")
+	}
+
+	var r0 models.Hold
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.Hold) (models.Hold, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(models.Hold) models.Hold); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.Hold)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.Hold) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeAudit provides a mock function with given fields: before
+func (_m *Storage) PurgeAudit(before time.Time) (int, error) {
+	ret := _m.Called(before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeAudit")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) (int, error)); ok {
+		return rf(before)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeBook provides a mock function with given fields: _a0, _a1
+func (_m *Storage) PurgeBook(_a0 string, _a1 models.AuditEntry) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeBook")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, models.AuditEntry) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeBooks provides a mock function with given fields: before, limit
+func (_m *Storage) PurgeBooks(before time.Time, limit int) (int, error) {
+	ret := _m.Called(before, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeBooks")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time, int) (int, error)); ok {
+		return rf(before, limit)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time, int) int); ok {
+		r0 = rf(before, limit)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time, int) error); ok {
+		r1 = rf(before, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeNotifications provides a mock function with given fields: readBefore
+func (_m *Storage) PurgeNotifications(readBefore time.Time) (int, error) {
+	ret := _m.Called(readBefore)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeNotifications")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) (int, error)); ok {
+		return rf(readBefore)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) int); ok {
+		r0 = rf(readBefore)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(readBefore)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReadAllNotifications provides a mock function with given fields: uid
+func (_m *Storage) ReadAllNotifications(uid string) (int, error) {
+	ret := _m.Called(uid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadAllNotifications")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (int, error)); ok {
+		return rf(uid)
+	}
+	if rf, ok := ret.Get(0).(func(string) int); ok {
+		r0 = rf(uid)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(uid)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReadNotification provides a mock function with given fields: id, uid
+func (_m *Storage) ReadNotification(id int64, uid string) (models.Notification, error) {
+	ret := _m.Called(id, uid)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadNotification")
+	}
+
+	var r0 models.Notification
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, string) (models.Notification, error)); ok {
+		return rf(id, uid)
+	}
+	if rf, ok := ret.Get(0).(func(int64, string) models.Notification); ok {
+		r0 = rf(id, uid)
+	} else {
+		r0 = ret.Get(0).(models.Notification)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, string) error); ok {
+		r1 = rf(id, uid)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReceiveTransfer provides a mock function with given fields: _a0
+func (_m *Storage) ReceiveTransfer(_a0 string) (models.Transfer, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReceiveTransfer")
+	}
+
+	var r0 models.Transfer
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (models.Transfer, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(string) models.Transfer); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.Transfer)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplayDelivery provides a mock function with given fields: _a0
+func (_m *Storage) ReplayDelivery(_a0 int64) (models.Delivery, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReplayDelivery")
+	}
+
+	var r0 models.Delivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (models.Delivery, error)); ok {
+		return rf(_a0)
+	}
+	if rf, ok := ret.Get(0).(func(int64) models.Delivery); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Get(0).(models.Delivery)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(_a0)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreBook provides a mock function with given fields: _a0, _a1
+func (_m *Storage) RestoreBook(_a0 string, _a1 models.AuditEntry) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RestoreBook")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func() error); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(string, models.AuditEntry) error); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -30,26 +1453,126 @@ func (_m *Storage) DeleteBooks() error {
 	return r0
 }
 
-// GetBook provides a mock function with given fields: _a0
-func (_m *Storage) GetBook(_a0 string) (models.Book, error) {
+// ReturnItem provides a mock function with given fields: barcode, branch
+func (_m *Storage) ReturnItem(barcode string, branch string) (models.Loan, error) {
+	ret := _m.Called(barcode, branch)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReturnItem")
+	}
+
+	var r0 models.Loan
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (models.Loan, error)); ok {
+		return rf(barcode, branch)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) models.Loan); ok {
+		r0 = rf(barcode, branch)
+	} else {
+		r0 = ret.Get(0).(models.Loan)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(barcode, branch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveAttempt provides a mock function with given fields: _a0, _a1
+func (_m *Storage) SaveAttempt(_a0 models.Delivery, _a1 models.DeliveryAttempt) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveAttempt")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(models.Delivery, models.DeliveryAttempt) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveBook provides a mock function with given fields: _a0, _a1
+func (_m *Storage) SaveBook(_a0 models.Book, _a1 models.AuditEntry) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveBook")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(models.Book, models.AuditEntry) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveBooks provides a mock function with given fields: _a0, _a1
+func (_m *Storage) SaveBooks(_a0 []models.Book, _a1 models.AuditEntry) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveBooks")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]models.Book, models.AuditEntry) error); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveBranch provides a mock function with given fields: _a0
+func (_m *Storage) SaveBranch(_a0 models.Branch) error {
 	ret := _m.Called(_a0)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetBook")
+		panic("no return value specified for SaveBranch")
 	}
 
-	var r0 models.Book
+	var r0 error
+	if rf, ok := ret.Get(0).(func(models.Branch) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveDeskAlert provides a mock function with given fields: _a0
+func (_m *Storage) SaveDeskAlert(_a0 models.DeskAlert) (models.DeskAlert, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveDeskAlert")
+	}
+
+	var r0 models.DeskAlert
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (models.Book, error)); ok {
+	if rf, ok := ret.Get(0).(func(models.DeskAlert) (models.DeskAlert, error)); ok {
 		return rf(_a0)
 	}
-	if rf, ok := ret.Get(0).(func(string) models.Book); ok {
+	if rf, ok := ret.Get(0).(func(models.DeskAlert) models.DeskAlert); ok {
 		r0 = rf(_a0)
 	} else {
-		r0 = ret.Get(0).(models.Book)
+		r0 = ret.Get(0).(models.DeskAlert)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
+	if rf, ok := ret.Get(1).(func(models.DeskAlert) error); ok {
 		r1 = rf(_a0)
 	} else {
 		r1 = ret.Error(1)
@@ -58,29 +1581,45 @@ func (_m *Storage) GetBook(_a0 string) (models.Book, error) {
 	return r0, r1
 }
 
-// GetBooks provides a mock function with given fields:
-func (_m *Storage) GetBooks() ([]models.Book, error) {
-	ret := _m.Called()
+// SaveImport provides a mock function with given fields: _a0
+func (_m *Storage) SaveImport(_a0 models.ImportJob) error {
+	ret := _m.Called(_a0)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetBooks")
+		panic("no return value specified for SaveImport")
 	}
 
-	var r0 []models.Book
+	var r0 error
+	if rf, ok := ret.Get(0).(func(models.ImportJob) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveItem provides a mock function with given fields: _a0, _a1
+func (_m *Storage) SaveItem(_a0 models.Item, _a1 models.AuditEntry) (string, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveItem")
+	}
+
+	var r0 string
 	var r1 error
-	if rf, ok := ret.Get(0).(func() ([]models.Book, error)); ok {
-		return rf()
+	if rf, ok := ret.Get(0).(func(models.Item, models.AuditEntry) (string, error)); ok {
+		return rf(_a0, _a1)
 	}
-	if rf, ok := ret.Get(0).(func() []models.Book); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(models.Item, models.AuditEntry) string); ok {
+		r0 = rf(_a0, _a1)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]models.Book)
-		}
+		r0 = ret.Get(0).(string)
 	}
 
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(models.Item, models.AuditEntry) error); ok {
+		r1 = rf(_a0, _a1)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -88,26 +1627,44 @@ func (_m *Storage) GetBooks() ([]models.Book, error) {
 	return r0, r1
 }
 
-// GetUser provides a mock function with given fields: _a0
-func (_m *Storage) GetUser(_a0 string) (models.User, error) {
+// SaveNotification provides a mock function with given fields: _a0
+func (_m *Storage) SaveNotification(_a0 models.Notification) error {
 	ret := _m.Called(_a0)
 
 	if len(ret) == 0 {
-		panic("no return value specified for GetUser")
+		panic("no return value specified for SaveNotification")
 	}
 
-	var r0 models.User
+	var r0 error
+	if rf, ok := ret.Get(0).(func(models.Notification) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveTransfer provides a mock function with given fields: _a0
+func (_m *Storage) SaveTransfer(_a0 models.Transfer) (models.Transfer, error) {
+	ret := _m.Called(_a0)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveTransfer")
+	}
+
+	var r0 models.Transfer
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string) (models.User, error)); ok {
+	if rf, ok := ret.Get(0).(func(models.Transfer) (models.Transfer, error)); ok {
 		return rf(_a0)
 	}
-	if rf, ok := ret.Get(0).(func(string) models.User); ok {
+	if rf, ok := ret.Get(0).(func(models.Transfer) models.Transfer); ok {
 		r0 = rf(_a0)
 	} else {
-		r0 = ret.Get(0).(models.User)
+		r0 = ret.Get(0).(models.Transfer)
 	}
 
-	if rf, ok := ret.Get(1).(func(string) error); ok {
+	if rf, ok := ret.Get(1).(func(models.Transfer) error); ok {
 		r1 = rf(_a0)
 	} else {
 		r1 = ret.Error(1)
@@ -116,16 +1673,44 @@ func (_m *Storage) GetUser(_a0 string) (models.User, error) {
 	return r0, r1
 }
 
-// SaveBook provides a mock function with given fields: _a0
-func (_m *Storage) SaveBook(_a0 models.Book) error {
+// SaveUser provides a mock function with given fields: _a0, _a1
+func (_m *Storage) SaveUser(_a0 models.User, _a1 models.AuditEntry) (string, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveUser")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.User, models.AuditEntry) (string, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(models.User, models.AuditEntry) string); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.User, models.AuditEntry) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveWebhook provides a mock function with given fields: _a0
+func (_m *Storage) SaveWebhook(_a0 models.Webhook) error {
 	ret := _m.Called(_a0)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SaveBook")
+		panic("no return value specified for SaveWebhook")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(models.Book) error); ok {
+	if rf, ok := ret.Get(0).(func(models.Webhook) error); ok {
 		r0 = rf(_a0)
 	} else {
 		r0 = ret.Error(0)
@@ -134,16 +1719,52 @@ func (_m *Storage) SaveBook(_a0 models.Book) error {
 	return r0
 }
 
-// SaveBooks provides a mock function with given fields: _a0
-func (_m *Storage) SaveBooks(_a0 []models.Book) error {
+// SetCover provides a mock function with given fields: bid, version, contentType, origin
+func (_m *Storage) SetCover(bid string, version string, contentType string, origin models.AuditEntry) error {
+	ret := _m.Called(bid, version, contentType, origin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetCover")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, string, models.AuditEntry) error); ok {
+		r0 = rf(bid, version, contentType, origin)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetDeleteStatus provides a mock function with given fields: bid, origin
+func (_m *Storage) SetDeleteStatus(bid string, origin models.AuditEntry) error {
+	ret := _m.Called(bid, origin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetDeleteStatus")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, models.AuditEntry) error); ok {
+		r0 = rf(bid, origin)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetNotifyPrefs provides a mock function with given fields: _a0
+func (_m *Storage) SetNotifyPrefs(_a0 models.NotifyPrefs) error {
 	ret := _m.Called(_a0)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SaveBooks")
+		panic("no return value specified for SetNotifyPrefs")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func([]models.Book) error); ok {
+	if rf, ok := ret.Get(0).(func(models.NotifyPrefs) error); ok {
 		r0 = rf(_a0)
 	} else {
 		r0 = ret.Error(0)
@@ -152,26 +1773,44 @@ func (_m *Storage) SaveBooks(_a0 []models.Book) error {
 	return r0
 }
 
-// SaveUser provides a mock function with given fields: _a0
-func (_m *Storage) SaveUser(_a0 models.User) (string, error) {
+// SetUserRole provides a mock function with given fields: uid, role, origin
+func (_m *Storage) SetUserRole(uid string, role string, origin models.AuditEntry) error {
+	ret := _m.Called(uid, role, origin)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetUserRole")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(string, string, models.AuditEntry) error); ok {
+		r0 = rf(uid, role, origin)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ShipTransfer provides a mock function with given fields: _a0
+func (_m *Storage) ShipTransfer(_a0 string) (models.Transfer, error) {
 	ret := _m.Called(_a0)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SaveUser")
+		panic("no return value specified for ShipTransfer")
 	}
 
-	var r0 string
+	var r0 models.Transfer
 	var r1 error
-	if rf, ok := ret.Get(0).(func(models.User) (string, error)); ok {
+	if rf, ok := ret.Get(0).(func(string) (models.Transfer, error)); ok {
 		return rf(_a0)
 	}
-	if rf, ok := ret.Get(0).(func(models.User) string); ok {
+	if rf, ok := ret.Get(0).(func(string) models.Transfer); ok {
 		r0 = rf(_a0)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(models.Transfer)
 	}
 
-	if rf, ok := ret.Get(1).(func(models.User) error); ok {
+	if rf, ok := ret.Get(1).(func(string) error); ok {
 		r1 = rf(_a0)
 	} else {
 		r1 = ret.Error(1)
@@ -180,17 +1819,47 @@ func (_m *Storage) SaveUser(_a0 models.User) (string, error) {
 	return r0, r1
 }
 
-// SetDeleteStatus provides a mock function with given fields: _a0
-func (_m *Storage) SetDeleteStatus(_a0 string) error {
-	ret := _m.Called(_a0)
+// UnfinishedImports provides a mock function with given fields: before
+func (_m *Storage) UnfinishedImports(before time.Time) ([]models.ImportJob, error) {
+	ret := _m.Called(before)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SetDeleteStatus")
+		panic("no return value specified for UnfinishedImports")
+	}
+
+	var r0 []models.ImportJob
+	var r1 error
+	if rf, ok := ret.Get(0).(func(time.Time) ([]models.ImportJob, error)); ok {
+		return rf(before)
+	}
+	if rf, ok := ret.Get(0).(func(time.Time) []models.ImportJob); ok {
+		r0 = rf(before)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]models.ImportJob)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(time.Time) error); ok {
+		r1 = rf(before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateImport provides a mock function with given fields: _a0, _a1
+func (_m *Storage) UpdateImport(_a0 models.ImportJob, _a1 []models.ImportError) error {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateImport")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		r0 = rf(_a0)
+	if rf, ok := ret.Get(0).(func(models.ImportJob, []models.ImportError) error); ok {
+		r0 = rf(_a0, _a1)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -198,6 +1867,34 @@ func (_m *Storage) SetDeleteStatus(_a0 string) error {
 	return r0
 }
 
+// UpdateItem provides a mock function with given fields: _a0, _a1
+func (_m *Storage) UpdateItem(_a0 models.Item, _a1 models.AuditEntry) (models.Item, error) {
+	ret := _m.Called(_a0, _a1)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateItem")
+	}
+
+	var r0 models.Item
+	var r1 error
+	if rf, ok := ret.Get(0).(func(models.Item, models.AuditEntry) (models.Item, error)); ok {
+		return rf(_a0, _a1)
+	}
+	if rf, ok := ret.Get(0).(func(models.Item, models.AuditEntry) models.Item); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		r0 = ret.Get(0).(models.Item)
+	}
+
+	if rf, ok := ret.Get(1).(func(models.Item, models.AuditEntry) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ValidUser provides a mock function with given fields: _a0
 func (_m *Storage) ValidUser(_a0 models.User) (string, error) {
 	ret := _m.Called(_a0)
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+)
+
+const inboxJob = "purge-notifications"
+
+// notifications returns a page of the inbox of the user, the newest first, with the count of the unread ones.
+// The page starts after the notification with the ID in the "before" query parameter, "limit" sets its size
+// and unread=true leaves out the read notifications.
+func (s *Server) notifications(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	var before int64
+	if cursor := ctx.Query("before"); cursor != "" {
+		var err error
+		if before, err = strconv.ParseInt(cursor, 10, 64); err != nil || before < 1 {
+			ctx.String(http.StatusBadRequest, "before must be a notification ID")
+			return
+		}
+	}
+	limit := consts.NotificationsPageSize
+	if size := ctx.Query("limit"); size != "" {
+		var err error
+		if limit, err = strconv.Atoi(size); err != nil || limit < 1 || limit > consts.NotificationsMaxPage {
+			ctx.String(http.StatusBadRequest, "limit must be from 1 to "+strconv.Itoa(consts.NotificationsMaxPage))
+			return
+		}
+	}
+	// One more notification than asked for tells if there is a next page.
+	notifications, err := s.db(ctx).GetNotifications(uid, before, limit+1, ctx.Query("unread") == "true")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	unread, err := s.db(ctx).CountUnread(uid)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	inbox := models.Inbox{Unread: unread, Notifications: notifications}
+	if len(notifications) > limit {
+		inbox.Notifications = notifications[:limit]
+		inbox.Next = strconv.FormatInt(notifications[limit-1].ID, 10)
+	}
+	ctx.JSON(http.StatusOK, inbox)
+}
+
+func (s *Server) readNotification(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "notification ID must be a number")
+		return
+	}
+	notification, err := s.db(ctx).ReadNotification(id, uid)
+	if err != nil {
+		log.Error().Err(err).Msg("read notification failed")
+		if errors.Is(err, storerrros.ErrNotificationNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, notification)
+}
+
+func (s *Server) readAllNotifications(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	read, err := s.db(ctx).ReadAllNotifications(uid)
+	if err != nil {
+		log.Error().Err(err).Msg("read all notifications failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"read": read})
+}
+
+// purgeNotifications removes the notifications read longer than the retention period ago.
+func (s *Server) purgeNotifications(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	before := time.Now().Add(-s.inboxRetention)
+	purged, err := s.db(ctx).PurgeNotifications(before)
+	if err != nil {
+		return err
+	}
+	log.Info().Int("purged", purged).Time("before", before).Msg("old read notifications purged")
+	return ctx.Err()
+}
+
+func (s *Server) notifyPrefs(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	prefs, err := s.db(ctx).GetNotifyPrefs(uid)
+	if err != nil {
+		log.Error().Err(err).Msg("get notify prefs failed")
+		if errors.Is(err, storerrros.ErrUserNotFound) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, prefs)
+}
+
+// setNotifyPrefs chooses how the user gets loan notices: by email, in the app or not at all, and in which language.
+func (s *Server) setNotifyPrefs(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	var prefs models.NotifyPrefs
+	if err := ctx.ShouldBindBodyWithJSON(&prefs); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(prefs); err != nil {
+		log.Error().Err(err).Msg("validate prefs failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prefs.UID = uid
+	if err := s.db(ctx).SetNotifyPrefs(prefs); err != nil {
+		log.Error().Err(err).Msg("set notify prefs failed")
+		if errors.Is(err, storerrros.ErrUserNotFound) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, prefs)
+}
+
+// noticeLog returns the latest loan notices, of a single user with the uid query parameter.
+func (s *Server) noticeLog(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	notices, err := s.db(ctx).GetNoticeLog(ctx.Query("uid"), consts.NoticeLogLimit)
+	if err != nil {
+		log.Error().Err(err).Msg("get notice log failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, notices)
+}
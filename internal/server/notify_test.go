@@ -0,0 +1,253 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetNotifyPrefs(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.PUT("/users/notification-prefs", srv.JWTAuthMiddleware(), srv.setNotifyPrefs)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name  string
+		body  string
+		prefs *models.NotifyPrefs
+		want  want
+	}
+	tests := []test{
+		{
+			name:  "in app notices in russian",
+			body:  `{"channel":"in_app","locale":"ru"}`,
+			prefs: &models.NotifyPrefs{UID: "test-uid", Channel: models.NotifyInApp, Locale: "ru"},
+			want: want{
+				body:       `{"uid":"test-uid","channel":"in_app","locale":"ru"}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name: "unknown channel",
+			body: `{"channel":"sms","locale":"en"}`,
+			want: want{
+				body: `{"error":"Key: 'NotifyPrefs.Channel' Error:Field validation ` +
+					`for 'Channel' failed on the 'oneof' tag"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name: "unknown locale",
+			body: `{"channel":"email","locale":"xx"}`,
+			want: want{
+				body: `{"error":"Key: 'NotifyPrefs.Locale' Error:Field validation ` +
+					`for 'Locale' failed on the 'oneof' tag"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.prefs != nil {
+				storMock.On("SetNotifyPrefs", *tc.prefs).Return(nil)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = http.MethodPut
+			req.URL = httpSrv.URL + "/users/notification-prefs"
+			req.SetHeader("Authorization", jwt)
+			req.SetBody(tc.body)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
+
+func TestNoticeLog(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.GET("/admin/notices", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleAdmin), srv.noticeLog)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+
+	storMock := mocks.NewStorage(t)
+	storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: models.RoleAdmin}, nil)
+	storMock.On("GetNoticeLog", "UID1", 200).Return([]models.NoticeLog{{
+		ID: 1, UID: "UID1", LID: "LID1", Kind: models.NoticeOverdue, Stage: 2,
+		Channel: models.NotifyEmail, Status: models.NoticeFailed, Attempts: 3, Error: "mailbox unavailable",
+	}}, nil)
+	srv.storage = storMock
+	resp, err := resty.New().R().SetHeader("Authorization", jwt).SetQueryParam("uid", "UID1").
+		Get(httpSrv.URL + "/admin/notices")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, `[{"id":1,"uid":"UID1","lid":"LID1","kind":"overdue","stage":2,"channel":"email",`+
+		`"status":"failed","attempts":3,"error":"mailbox unavailable","created_at":"0001-01-01T00:00:00Z"}]`,
+		string(resp.Body()))
+}
+
+func TestNotifications(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.GET("/users/notifications", srv.JWTAuthMiddleware(), srv.notifications)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	page := []models.Notification{
+		{ID: 9, UID: "test-uid", Kind: models.NoticeHoldReady, Title: "ready"},
+		{ID: 5, UID: "test-uid", Kind: models.NoticeDueSoon, Title: "due"},
+		{ID: 2, UID: "test-uid", Kind: models.NoticeOverdue, Title: "late"},
+	}
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name   string
+		query  map[string]string
+		before int64
+		limit  int
+		unread bool
+		page   []models.Notification
+		want   want
+	}
+	tests := []test{
+		{
+			name:  "first page with a next one",
+			query: map[string]string{"limit": "2"},
+			limit: 3,
+			page:  page,
+			want: want{
+				body: `{"unread":4,"notifications":[` +
+					`{"id":9,"uid":"test-uid","kind":"hold_ready","title":"ready","body":"","created_at":"0001-01-01T00:00:00Z"},` +
+					`{"id":5,"uid":"test-uid","kind":"due_soon","title":"due","body":"","created_at":"0001-01-01T00:00:00Z"}` +
+					`],"next":"5"}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "last unread page",
+			query:  map[string]string{"before": "5", "unread": "true"},
+			before: 5,
+			limit:  21,
+			unread: true,
+			page:   page[2:],
+			want: want{
+				body: `{"unread":4,"notifications":[` +
+					`{"id":2,"uid":"test-uid","kind":"overdue","title":"late","body":"","created_at":"0001-01-01T00:00:00Z"}]}`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:  "too large page",
+			query: map[string]string{"limit": "1000"},
+			want: want{
+				body:       `limit must be from 1 to 100`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:  "bad cursor",
+			query: map[string]string{"before": "abc"},
+			want: want{
+				body:       `before must be a notification ID`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			if tc.page != nil {
+				storMock.On("GetNotifications", "test-uid", tc.before, tc.limit, tc.unread).Return(tc.page, nil)
+				storMock.On("CountUnread", "test-uid").Return(4, nil)
+			}
+			srv.storage = storMock
+			resp, err := resty.New().R().SetHeader("Authorization", jwt).SetQueryParams(tc.query).
+				Get(httpSrv.URL + "/users/notifications")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
+
+func TestReadNotification(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.POST("/users/notifications/read-all", srv.JWTAuthMiddleware(), srv.readAllNotifications)
+	r.POST("/users/notifications/:id/read", srv.JWTAuthMiddleware(), srv.readNotification)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+
+	t.Run("read one", func(t *testing.T) {
+		read := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+		storMock := mocks.NewStorage(t)
+		storMock.On("ReadNotification", int64(5), "test-uid").Return(models.Notification{
+			ID: 5, UID: "test-uid", Kind: models.NoticeDueSoon, Title: "due", ReadAt: &read,
+		}, nil)
+		srv.storage = storMock
+		resp, err := resty.New().R().SetHeader("Authorization", jwt).Post(httpSrv.URL + "/users/notifications/5/read")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, `{"id":5,"uid":"test-uid","kind":"due_soon","title":"due","body":"",`+
+			`"created_at":"0001-01-01T00:00:00Z","read_at":"2024-10-01T12:00:00Z"}`, string(resp.Body()))
+	})
+	t.Run("someone else's", func(t *testing.T) {
+		storMock := mocks.NewStorage(t)
+		storMock.On("ReadNotification", int64(6), "test-uid").
+			Return(models.Notification{}, storerrros.ErrNotificationNoExist)
+		srv.storage = storMock
+		resp, err := resty.New().R().SetHeader("Authorization", jwt).Post(httpSrv.URL + "/users/notifications/6/read")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+		assert.Equal(t, `notification does not exists`, string(resp.Body()))
+	})
+	t.Run("read all", func(t *testing.T) {
+		storMock := mocks.NewStorage(t)
+		storMock.On("ReadAllNotifications", "test-uid").Return(3, nil)
+		srv.storage = storMock
+		resp, err := resty.New().R().SetHeader("Authorization", jwt).Post(httpSrv.URL + "/users/notifications/read-all")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, `{"read":3}`, string(resp.Body()))
+	})
+	t.Run("without token", func(t *testing.T) {
+		srv.storage = mocks.NewStorage(t)
+		resp, err := resty.New().R().Post(httpSrv.URL + "/users/notifications/read-all")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+		assert.Equal(t, `invalid token`, string(resp.Body()))
+	})
+}
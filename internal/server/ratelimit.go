@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+const rateLimitJob = "purge-rate-limits"
+
+// rateLimit refuses the requests of a client over the limit of the route with 429. The limit
+// and what is left of it are sent in the RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers, refused requests get Retry-After too.
+func (s *Server) rateLimit() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if s.limiter == nil {
+			return
+		}
+		log := logger.FromContext(ctx)
+		route := ctx.Request.Method + " " + ctx.FullPath()
+		decision, limited, err := s.limiter.Allow(route, rateSubject(ctx), time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("rate limit failed, letting the request through")
+		}
+		if !limited {
+			return
+		}
+		limit := decision.Limit
+		ctx.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+		ctx.Header("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(ceilSeconds(limit.Per)))
+		if decision.Allowed {
+			return
+		}
+		log.Warn().Str("route", route).Str("limit", limit.String()).Msg("rate limit exceeded")
+		ctx.Header("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+		ctx.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+	}
+}
+
+// rateSubject is who the limit applies to: the user of a valid token, otherwise the client IP.
+func rateSubject(ctx *gin.Context) string {
+	if token := ctx.GetHeader("Authorization"); token != "" {
+		if uid, err := validToken(token); err == nil {
+			return "user:" + uid
+		}
+	}
+	return "ip:" + ctx.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// newRateStore keeps the buckets in the database when asked to and it is connected, in memory otherwise.
+func newRateStore(kind string, stor Storage) ratelimit.Store {
+	if kind == "postgres" {
+		if store, ok := stor.(ratelimit.Store); ok {
+			return store
+		}
+		log := logger.Get()
+		log.Warn().Msg("database is not connected, rate limits are kept in memory")
+	}
+	return ratelimit.NewMemStore()
+}
+
+func (s *Server) purgeRateLimits(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	purged, err := s.limiter.Purge(time.Now())
+	if err != nil {
+		return err
+	}
+	log.Debug().Int("purged", purged).Msg("full rate buckets purged")
+	return ctx.Err()
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/config"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.limiter = ratelimit.New(ratelimit.NewMemStore(), map[string]ratelimit.Limit{
+		"POST /add-books": {Requests: 2, Per: time.Minute},
+	})
+	r := gin.New()
+	r.Use(srv.rateLimit())
+	r.POST("/add-books", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	r.GET("/books/", func(ctx *gin.Context) { ctx.Status(http.StatusOK) })
+	httpSrv := httptest.NewServer(r)
+	defer httpSrv.Close()
+	alice, err := createJWTToken("alice")
+	require.NoError(t, err)
+	bob, err := createJWTToken("bob")
+	require.NoError(t, err)
+
+	type want struct {
+		statusCode int
+		remaining  string
+		retryAfter string
+	}
+	type test struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   want
+	}
+	tests := []test{
+		{
+			name:   "first request of a user",
+			method: http.MethodPost,
+			path:   "/add-books",
+			token:  alice,
+			want:   want{statusCode: http.StatusOK, remaining: "1"},
+		},
+		{
+			name:   "last request of a user",
+			method: http.MethodPost,
+			path:   "/add-books",
+			token:  alice,
+			want:   want{statusCode: http.StatusOK, remaining: "0"},
+		},
+		{
+			name:   "user over the limit",
+			method: http.MethodPost,
+			path:   "/add-books",
+			token:  alice,
+			want:   want{statusCode: http.StatusTooManyRequests, remaining: "0", retryAfter: "30"},
+		},
+		{
+			name:   "other user",
+			method: http.MethodPost,
+			path:   "/add-books",
+			token:  bob,
+			want:   want{statusCode: http.StatusOK, remaining: "1"},
+		},
+		{
+			name:   "client without a token is limited by ip",
+			method: http.MethodPost,
+			path:   "/add-books",
+			want:   want{statusCode: http.StatusOK, remaining: "1"},
+		},
+		{
+			name:   "client with a bad token is limited by ip",
+			method: http.MethodPost,
+			path:   "/add-books",
+			token:  "bad token",
+			want:   want{statusCode: http.StatusOK, remaining: "0"},
+		},
+		{
+			name:   "route without a policy",
+			method: http.MethodGet,
+			path:   "/books/",
+			token:  alice,
+			want:   want{statusCode: http.StatusOK},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := resty.New().R()
+			req.Method = tc.method
+			req.URL = httpSrv.URL + tc.path
+			if tc.token != "" {
+				req.SetHeader("Authorization", tc.token)
+			}
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.remaining, resp.Header().Get("RateLimit-Remaining"))
+			assert.Equal(t, tc.want.retryAfter, resp.Header().Get("Retry-After"))
+			if tc.want.remaining != "" {
+				assert.Equal(t, "2", resp.Header().Get("RateLimit-Limit"))
+				assert.Equal(t, "2;w=60", resp.Header().Get("RateLimit-Policy"))
+			}
+		})
+	}
+
+	// The limits change on reload.
+	srv.Reload(config.Config{RateLimit: config.RateLimitConfig{Routes: map[string]ratelimit.Limit{
+		ratelimit.DefaultRoute: {Requests: 10, Per: time.Second},
+	}}})
+	resp, err := resty.New().R().SetHeader("Authorization", alice).Post(httpSrv.URL + "/add-books")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, "10;w=1", resp.Header().Get("RateLimit-Policy"))
+}
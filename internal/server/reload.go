@@ -0,0 +1,21 @@
+package server
+
+import (
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/config"
+)
+
+// Reload applies the settings which may change while the server runs: the loan policy and the
+// rate limits of the routes. The others take effect on restart.
+func (s *Server) Reload(cfg config.Config) {
+	s.loan.Store(int64(cfg.Loan.Period))
+	if s.limiter != nil {
+		s.limiter.SetRoutes(cfg.RateLimit.Routes)
+	}
+}
+
+// loanPeriod is how long a copy checked out now is lent for.
+func (s *Server) loanPeriod() time.Duration {
+	return time.Duration(s.loan.Load())
+}
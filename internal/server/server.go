@@ -3,122 +3,432 @@ package server
 import (
 	"context"
 	"errors"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	"net/http"
 
+	"github.com/Dorrrke/g3-bookly/internal/blob"
 	"github.com/Dorrrke/g3-bookly/internal/config"
+	"github.com/Dorrrke/g3-bookly/internal/desk"
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
 	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/events"
 	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/metadata"
+	"github.com/Dorrrke/g3-bookly/internal/metrics"
+	"github.com/Dorrrke/g3-bookly/internal/notify"
+	"github.com/Dorrrke/g3-bookly/internal/ratelimit"
+	"github.com/Dorrrke/g3-bookly/internal/tracing"
+	"github.com/Dorrrke/g3-bookly/internal/webhook"
+	"github.com/Dorrrke/g3-bookly/internal/worker"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var secretKey = "VerySecurKey2000Cat" //nolint:gochecknoglobals //demo var
+// secretKey and tokenTTL sign the tokens, New sets them from the config.
+var (
+	secretKey = "VerySecurKey2000Cat" //nolint:gochecknoglobals //demo var
+	tokenTTL  = 3 * time.Hour         //nolint:gochecknoglobals //demo var
+)
 
 var ErrInvalidToken = errors.New("invalid token")
 
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+)
+
 type Claims struct {
 	jwt.RegisteredClaims
 	UserID string
 }
 
 type Storage interface {
-	SaveUser(models.User) (string, error)
+	SaveUser(models.User, models.AuditEntry) (string, error)
 	ValidUser(models.User) (string, error)
-	SaveBook(models.Book) error
-	SaveBooks([]models.Book) error
+	SaveBook(models.Book, models.AuditEntry) error
+	SaveBooks([]models.Book, models.AuditEntry) error
 	GetUser(string) (models.User, error)
 	GetBooks() ([]models.Book, error)
 	GetBook(string) (models.Book, error)
-	SetDeleteStatus(string) error
-	DeleteBooks() error
+	GetBooksAt(time.Time) ([]models.Book, error)
+	GetBookAt(bid string, at time.Time) (models.Book, error)
+	FindBook(lable, author string) (models.Book, error)
+	GetBooksPage(after string, limit int) ([]models.Book, error)
+	SetCover(bid, version, contentType string, origin models.AuditEntry) error
+	SetDeleteStatus(bid string, origin models.AuditEntry) error
+	GetTrash() ([]models.TrashedBook, error)
+	RestoreBook(string, models.AuditEntry) error
+	PurgeBook(string, models.AuditEntry) error
+	PurgeBooks(before time.Time, limit int) (int, error)
+	SaveItem(models.Item, models.AuditEntry) (string, error)
+	GetItems(string) ([]models.Item, error)
+	GetItem(string) (models.Item, error)
+	UpdateItem(models.Item, models.AuditEntry) (models.Item, error)
+	CheckoutItem(barcode, uid string, period time.Duration) (models.Loan, error)
+	ReturnItem(barcode, branch string) (models.Loan, error)
+	SetUserRole(uid, role string, origin models.AuditEntry) error
+	SaveBranch(models.Branch) error
+	GetBranches() ([]models.Branch, error)
+	GetBranchBooks(string) ([]models.Book, error)
+	GetAvailability(string) ([]models.Availability, error)
+	PlaceHold(models.Hold) (models.Hold, error)
+	GetHolds(string) ([]models.Hold, error)
+	CancelHold(hid, uid string) error
+	SaveTransfer(models.Transfer) (models.Transfer, error)
+	GetTransfers(string) ([]models.Transfer, error)
+	ShipTransfer(string) (models.Transfer, error)
+	ReceiveTransfer(string) (models.Transfer, error)
+	SaveImport(models.ImportJob) error
+	UpdateImport(models.ImportJob, []models.ImportError) error
+	GetImport(string) (models.ImportJob, error)
+	GetImportErrors(string) ([]models.ImportError, error)
+	UnfinishedImports(before time.Time) ([]models.ImportJob, error)
+	GetAudit(entity, id string, limit int) ([]models.AuditEntry, error)
+	PurgeAudit(before time.Time) (int, error)
+	PendingEvents(limit int) ([]models.Event, error)
+	MarkPublished(ids []int64) error
+	EventsAfter(after int64, limit int) ([]models.Event, error)
+	MarkOverdueLoans(now time.Time) (int, error)
+	SaveWebhook(models.Webhook) error
+	GetWebhooks() ([]models.Webhook, error)
+	GetWebhook(string) (models.Webhook, error)
+	DeleteWebhook(string) error
+	EnqueueDeliveries(event models.Event, payload []byte) (int, error)
+	DueDeliveries(now time.Time, limit int) ([]models.DeliveryTask, error)
+	SaveAttempt(models.Delivery, models.DeliveryAttempt) error
+	GetDeliveries(webhookID string, status models.DeliveryStatus, limit int) ([]models.Delivery, error)
+	GetDelivery(int64) (models.Delivery, error)
+	GetDeliveryAttempts(int64) ([]models.DeliveryAttempt, error)
+	ReplayDelivery(int64) (models.Delivery, error)
+	SaveDeskAlert(models.DeskAlert) (models.DeskAlert, error)
+	GetDeskAlerts(branch string) ([]models.DeskAlert, error)
+	AckDeskAlert(id int64, branch, uid string) (models.DeskAlert, error)
+	GetNotifyPrefs(uid string) (models.NotifyPrefs, error)
+	SetNotifyPrefs(models.NotifyPrefs) error
+	LoansDue(before time.Time) ([]models.LoanDue, error)
+	ClaimNotice(models.NoticeLog) (models.NoticeLog, error)
+	FinishNotice(id int64, status models.NoticeStatus, errText string) error
+	GetNoticeLog(uid string, limit int) ([]models.NoticeLog, error)
+	SaveNotification(models.Notification) error
+	GetNotifications(uid string, before int64, limit int, unread bool) ([]models.Notification, error)
+	CountUnread(uid string) (int, error)
+	ReadNotification(id int64, uid string) (models.Notification, error)
+	ReadAllNotifications(uid string) (int, error)
+	PurgeNotifications(readBefore time.Time) (int, error)
+	LibraryStats() (models.LibraryStats, error)
 }
 
 type Server struct {
-	serv    *http.Server
-	valid   *validator.Validate
-	storage Storage
-	delChan chan struct{}
-	ErrChan chan error
+	serv       *http.Server
+	http       config.HTTPConfig
+	valid      *validator.Validate
+	storage    Storage
+	blobs      blob.BlobStore
+	meta       metadata.MetadataProvider
+	importChan chan string
+	jobs       *worker.Scheduler
+	events     *events.Bus
+	desks      *desk.Hub
+	metrics    *metrics.Metrics
+	// metricsServ serves the metrics on the admin port, nil when they are served by the API.
+	metricsServ *http.Server
+	// done is closed when the server stops, long lived responses like event streams end on it.
+	done       <-chan struct{}
+	purge      config.PurgeConfig
+	adminEmail string
+
+	auditRetention time.Duration
+	inboxRetention time.Duration
+	// loan is the loan period in nanoseconds, it changes on Reload.
+	loan    atomic.Int64
+	limiter *ratelimit.Limiter
+
+	// readyChecks are run by /readyz.
+	readyChecks []readyCheck
 }
 
-func New(cfg config.Config, stor Storage) *Server {
-	server := http.Server{ //nolint:gosec // not today
-		Addr: cfg.Addr,
+func New(cfg config.Config, stor Storage, blobs blob.BlobStore, meta metadata.MetadataProvider) *Server {
+	server := http.Server{
+		Addr:              cfg.Addr,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
 	}
 	valid := validator.New()
-	return &Server{
-		serv:    &server,
-		valid:   valid,
-		storage: stor,
-		delChan: make(chan struct{}, 10), //nolint:mnd //todo
-		ErrChan: make(chan error),
+	m := metrics.New()
+	if pool, ok := stor.(interface{ PoolStat() *pgxpool.Stat }); ok {
+		m.MustRegister(metrics.NewPoolCollector(pool.PoolStat))
+	}
+	checks := readyChecks(stor, cfg.MigratePath)
+	limiter := ratelimit.New(newRateStore(cfg.RateLimit.Store, stor), cfg.RateLimit.Routes)
+	stor = instrumentedStorage{Storage: stor, metrics: m}
+	m.MustRegister(metrics.NewLibraryCollector(stor.LibraryStats))
+	s := &Server{
+		serv:       &server,
+		http:       cfg.HTTP,
+		valid:      valid,
+		storage:    stor,
+		blobs:      blobs,
+		meta:       meta,
+		importChan: make(chan string, cfg.ImportQueue),
+		jobs: worker.New(worker.Backoff{
+			Initial:  consts.JobRetryInitial,
+			Max:      consts.JobRetryMax,
+			Attempts: consts.JobRetryAttempts,
+		}),
+		metrics: m,
+		limiter: limiter,
+		purge:   cfg.Purge,
+		events:  events.NewBus(),
+		desks:   desk.NewHub(stor, consts.DeskBuffer),
+
+		adminEmail: cfg.AdminEmail,
+
+		auditRetention: cfg.Audit.Retention,
+		inboxRetention: cfg.Notify.Retention,
+	}
+	if cfg.Auth.Secret != "" {
+		secretKey = cfg.Auth.Secret
+	}
+	if cfg.Auth.TokenTTL > 0 {
+		tokenTTL = cfg.Auth.TokenTTL
+	}
+	s.Reload(cfg)
+	s.readyChecks = append(checks,
+		readyCheck{name: "workers", run: s.checkWorkers},
+		readyCheck{name: "shutdown", run: s.checkShutdown},
+	)
+	if cfg.MetricsAddr != "" {
+		s.metricsServ = &http.Server{Addr: cfg.MetricsAddr, Handler: m.Handler(), ReadHeaderTimeout: consts.MetricsReadTimeout}
+	}
+	s.jobs.Observe(m.ObserveJob)
+	s.jobs.Add(worker.Job{Name: purgeJob, Interval: cfg.Purge.Interval, Run: s.purgeBooks})
+	sinks := []events.Sink{s.events, webhook.NewSink(stor), s.desks, notify.NewInbox(stor)}
+	if cfg.Events.File != "" {
+		sinks = append(sinks, events.NewFileSink(cfg.Events.File))
 	}
+	relay := events.NewRelay(stor, consts.OutboxBatchSize, sinks...)
+	s.jobs.Add(worker.Job{Name: relayJob, Interval: consts.OutboxPollInterval, Run: relay.Run})
+	dispatcher := webhook.NewDispatcher(stor, &http.Client{
+		Timeout:   consts.WebhookTimeout,
+		Transport: tracing.Transport(nil),
+	}, worker.Backoff{
+		Initial:  consts.WebhookRetryInitial,
+		Max:      consts.WebhookRetryMax,
+		Attempts: consts.WebhookMaxAttempts,
+	}, consts.WebhookBatchSize, consts.WebhookWorkers)
+	s.jobs.Add(worker.Job{Name: webhookJob, Interval: consts.WebhookPollInterval, Run: dispatcher.Run})
+	s.jobs.Add(worker.Job{Name: rateLimitJob, Interval: consts.RateLimitPurgeInterval, Run: s.purgeRateLimits})
+	s.jobs.Add(worker.Job{Name: overdueJob, Interval: consts.OverdueCheckInterval, Run: s.markOverdueLoans})
+	if cfg.Audit.Retention > 0 {
+		s.jobs.Add(worker.Job{Name: auditJob, Interval: consts.AuditPurgeInterval, Run: s.purgeAudit})
+	}
+	if cfg.Notify.Retention > 0 {
+		s.jobs.Add(worker.Job{Name: inboxJob, Interval: consts.NotificationPurgeInterval, Run: s.purgeNotifications})
+	}
+	return s
+}
+
+// db returns the storage with its calls traced under the span of ctx. Handlers pass their gin
+// context, which falls back to the request context holding the span of the request.
+func (s *Server) db(ctx context.Context) Storage {
+	if is, ok := s.storage.(instrumentedStorage); ok {
+		is.ctx = ctx
+		return is
+	}
+	return s.storage
 }
 
 func (s *Server) ShutdownServer() error {
+	if s.metricsServ != nil {
+		if err := s.metricsServ.Shutdown(context.Background()); err != nil {
+			return err
+		}
+	}
 	return s.serv.Shutdown(context.Background())
 }
 
 func (s *Server) Run(ctx context.Context) error {
-	log := logger.Get()
-	router := gin.Default()
+	log := logger.FromContext(ctx)
+	router := gin.New()
+	// Handlers pass their gin context on as context.Context, it has to carry the request span and logger.
+	router.ContextWithFallback = true
+	if err := router.SetTrustedProxies(s.http.TrustedProxies); err != nil {
+		return err
+	}
+	router.Use(tracing.Middleware(), requestID(), accessLog(), s.metrics.Middleware(), recovery(), s.rateLimit())
 	router.GET("/", func(ctx *gin.Context) { ctx.String(http.StatusOK, "Hello") })
+	router.GET("/healthz", s.healthz)
+	router.GET("/readyz", s.readyz)
 	users := router.Group("/users")
 	{
 		users.GET("/info", s.JWTAuthMiddleware(), s.userInfo)
 		users.POST("/register", s.register)
 		users.POST("/login", s.login)
+		users.GET("/holds", s.JWTAuthMiddleware(), s.userHolds)
+		users.DELETE("/holds/:id", s.JWTAuthMiddleware(), s.cancelHold)
+		users.GET("/notification-prefs", s.JWTAuthMiddleware(), s.notifyPrefs)
+		users.PUT("/notification-prefs", s.JWTAuthMiddleware(), s.setNotifyPrefs)
+		users.GET("/notifications", s.JWTAuthMiddleware(), s.notifications)
+		users.POST("/notifications/read-all", s.JWTAuthMiddleware(), s.readAllNotifications)
+		users.POST("/notifications/:id/read", s.JWTAuthMiddleware(), s.readNotification)
+		users.PUT("/:uid/role", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleAdmin), s.setRole)
 	}
 	books := router.Group("/books")
 	{
+		books.GET("/export", s.JWTAuthMiddleware(), longLived(), s.exportBooks)
+		books.POST("/lookup", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.lookupBook)
+		books.GET("/trash", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.trashBooks)
 		books.GET("/:id", s.JWTAuthMiddleware(), s.bookInfo)
-		books.GET("/:id/remove", s.JWTAuthMiddleware(), s.removeBook)
+		books.DELETE("/:id", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.removeBook)
+		books.POST("/:id/restore", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.restoreBook)
+		books.DELETE("/:id/purge", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleAdmin), s.purgeBook)
 		books.GET("/", s.JWTAuthMiddleware(), s.allBooks)
+		books.GET("/:id/items", s.JWTAuthMiddleware(), s.bookItems)
+		books.POST("/:id/items", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.addItem)
+		books.GET("/:id/availability", s.JWTAuthMiddleware(), s.bookAvailability)
+		books.POST("/:id/holds", s.JWTAuthMiddleware(), s.placeHold)
+		books.GET("/:id/cover", s.coverImage)
+		books.POST("/:id/cover", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.uploadCover)
+	}
+	items := router.Group("/items")
+	{
+		items.GET("/:barcode", s.JWTAuthMiddleware(), s.itemInfo)
+		items.PATCH("/:barcode", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.updateItem)
+		items.POST("/:barcode/checkout", s.JWTAuthMiddleware(), s.checkout)
+	}
+	branches := router.Group("/branches")
+	{
+		branches.GET("/", s.JWTAuthMiddleware(), s.allBranches)
+		branches.POST("/", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleAdmin), s.addBranch)
+	}
+	transfers := router.Group("/transfers", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian))
+	{
+		transfers.GET("/", s.allTransfers)
+		transfers.POST("/", s.requestTransfer)
+		transfers.POST("/:id/ship", s.shipTransfer)
+		transfers.POST("/:id/receive", s.receiveTransfer)
+	}
+	imports := router.Group("/imports", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian))
+	{
+		imports.POST("/", longLived(), s.startImport)
+		imports.GET("/:id", s.importStatus)
+		imports.GET("/:id/errors", s.importErrors)
+	}
+	admin := router.Group("/admin", s.requireClientCert(), s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleAdmin))
+	{
+		admin.GET("/jobs", s.allJobs)
+		admin.POST("/jobs/:name/run", s.runJob)
+		admin.GET("/webhooks", s.allWebhooks)
+		admin.POST("/webhooks", s.addWebhook)
+		admin.DELETE("/webhooks/:id", s.removeWebhook)
+		admin.GET("/webhooks/:id/deliveries", s.webhookDeliveries)
+		admin.GET("/deliveries/:id/attempts", s.deliveryAttempts)
+		admin.POST("/deliveries/:id/replay", s.replayDelivery)
+		admin.GET("/notices", s.noticeLog)
+		admin.GET("/log-level", s.logLevel)
+		admin.PUT("/log-level", s.setLogLevel)
 	}
+	router.GET("/events", s.JWTAuthMiddleware(), longLived(), s.eventStream)
+	router.GET("/desk", s.WSAuthMiddleware(), s.RoleMiddleware(models.RoleLibrarian), s.deskSocket)
+	router.GET("/audit", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleAdmin), s.auditLog)
 	router.POST("/add-book", s.JWTAuthMiddleware(), s.addBook)
 	router.POST("/add-books", s.JWTAuthMiddleware(), s.addBooks)
 	router.POST("/book-return", s.JWTAuthMiddleware(), s.bookReturn)
+	if s.metricsServ == nil {
+		router.GET("/metrics", s.JWTAuthMiddleware(), s.RoleMiddleware(models.RoleAdmin), gin.WrapH(s.metrics.Handler()))
+	}
 
-	s.serv.Handler = router
-	log.Debug().Msg("start delete liostener")
-	go s.deleter(ctx)
-	log.Info().Str("host", s.serv.Addr).Msg("server started")
-	if err := s.serv.ListenAndServe(); err != nil {
+	// Without TLS HTTP/2 is still served to the clients and proxies asking for it in cleartext.
+	router.UseH2C = s.http.CertFile == ""
+	s.serv.Handler = router.Handler()
+	s.done = ctx.Done()
+	go s.jobs.Run(ctx)
+	go s.importer(ctx)
+	if s.metricsServ != nil {
+		go func() {
+			log.Info().Str("host", s.metricsServ.Addr).Msg("metrics server started")
+			if err := s.metricsServ.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error().Err(err).Msg("metrics server failed")
+			}
+		}()
+	}
+	log.Info().Str("host", s.serv.Addr).Bool("tls", s.http.CertFile != "").Msg("server started")
+	if err := s.listen(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// listen serves HTTPS when a certificate is configured and plain HTTP otherwise.
+func (s *Server) listen() error {
+	if s.http.CertFile == "" {
+		return s.serv.ListenAndServe()
+	}
+	tlsCfg, err := newTLSConfig(s.http)
+	if err != nil {
+		return err
+	}
+	s.serv.TLSConfig = tlsCfg
+	return s.serv.ListenAndServeTLS("", "")
+}
+
 func (s *Server) Close() error {
 	return s.serv.Shutdown(context.TODO())
 }
 
 func (s *Server) JWTAuthMiddleware() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		log := logger.Get()
+		log := logger.FromContext(ctx)
 		toketn := ctx.GetHeader("Authorization")
 		if toketn == "" {
 			ctx.String(http.StatusUnauthorized, "invalid token")
+			ctx.Abort()
 			return
 		}
 		uid, err := validToken(toketn)
 		if err != nil {
 			log.Error().Err(err).Msg("validate jwt failed")
 			ctx.String(http.StatusUnauthorized, "invalid token")
+			ctx.Abort()
 			return
 		}
 		ctx.Set("uid", uid)
+		setLogger(ctx, logger.FromContext(ctx).With().Str("uid", uid).Logger())
 		ctx.Next()
 	}
 }
 
+// RoleMiddleware lets through users with one of the roles. Admins pass every role check.
+func (s *Server) RoleMiddleware(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		log := logger.FromContext(ctx)
+		user, err := s.db(ctx).GetUser(ctx.GetString("uid"))
+		if err != nil {
+			log.Error().Err(err).Msg("get user role failed")
+			ctx.String(http.StatusForbidden, "access denied")
+			ctx.Abort()
+			return
+		}
+		if user.Role == models.RoleAdmin || slices.Contains(roles, user.Role) {
+			ctx.Next()
+			return
+		}
+		ctx.String(http.StatusForbidden, "access denied")
+		ctx.Abort()
+	}
+}
+
 func createJWTToken(uid string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 3)), //nolint:mnd //todo
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenTTL)),
 		},
 		UserID: uid,
 	})
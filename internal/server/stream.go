@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const lastEventIDHeader = "Last-Event-ID"
+
+// streamAvailability is the stream event with the copy counters of a book, sent whenever
+// a copy is added, lent, returned or put on hold.
+const streamAvailability = "Availability"
+
+// streamTypes are the event types a stream client can ask for. HoldReady and LoanOverdue
+// are sent only to the member the hold or the loan belongs to.
+var streamTypes = []string{ //nolint:gochecknoglobals //read only
+	string(models.EventBookAdded),
+	string(models.EventBookDeleted),
+	string(models.EventBookRestored),
+	string(models.EventBookPurged),
+	streamAvailability,
+	string(models.EventHoldReady),
+	string(models.EventLoanOverdue),
+}
+
+// streamFilter tells which events a stream client gets.
+type streamFilter struct {
+	uid   string
+	types []string
+	bid   string
+}
+
+type availability struct {
+	BID       string `json:"bid"`
+	Count     int    `json:"count"`
+	Available int    `json:"available"`
+}
+
+// eventSubject is the part of the event data the stream filters on.
+type eventSubject struct {
+	BID string `json:"bid"`
+	UID string `json:"uid"`
+}
+
+// eventStream streams the catalog and loan changes as Server-Sent Events. The "types" query parameter
+// takes a comma separated list of event types, "bid" keeps only the events of one book.
+// A client resuming with Last-Event-ID first gets the events it missed. The stream ends when
+// the client falls too far behind, so that it reconnects and gets the missed events this way.
+func (s *Server) eventStream(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	uid := ctx.GetString("uid")
+	if uid == "" {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	filter := streamFilter{uid: uid, types: streamTypes, bid: ctx.Query("bid")}
+	if types := ctx.Query("types"); types != "" {
+		filter.types = strings.Split(types, ",")
+		for _, typ := range filter.types {
+			if !slices.Contains(streamTypes, typ) {
+				ctx.String(http.StatusBadRequest, "unknown event type "+typ)
+				return
+			}
+		}
+	}
+	var lastID int64
+	if id := ctx.GetHeader(lastEventIDHeader); id != "" {
+		var err error
+		if lastID, err = strconv.ParseInt(id, 10, 64); err != nil {
+			ctx.String(http.StatusBadRequest, "Last-Event-ID must be a number")
+			return
+		}
+	}
+	// Subscribing before reading the missed events makes sure nothing falls in between,
+	// events got both ways are told apart by their IDs.
+	sub, cancel := s.events.Subscribe(consts.StreamBuffer)
+	defer cancel()
+	var missed []models.Event
+	if lastID > 0 {
+		var err error
+		if missed, err = s.db(ctx).EventsAfter(lastID, consts.StreamReplayLimit); err != nil {
+			log.Error().Err(err).Msg("get missed events failed")
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Header("Content-Type", sse.ContentType)
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+	// Events are published in commit order, not in ID order, so a live event with a lower ID
+	// than the last one sent may still be new. Only the replayed ones are known to be sent.
+	replayed := make(map[int64]struct{}, len(missed))
+	for _, event := range missed {
+		s.sendEvent(ctx, filter, event)
+		replayed[event.ID] = struct{}{}
+	}
+	keepAlive := time.NewTicker(consts.StreamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-keepAlive.C:
+			if _, err := ctx.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		case event, ok := <-sub:
+			if !ok {
+				// The bus dropped the subscription, the client resumes with Last-Event-ID.
+				return
+			}
+			if _, ok = replayed[event.ID]; ok {
+				delete(replayed, event.ID)
+				continue
+			}
+			s.sendEvent(ctx, filter, event)
+		}
+	}
+}
+
+// sendEvent writes the event to the stream if the client asked for it. Loans and holds turn into
+// availability updates of their book, the members who took them stay private.
+func (s *Server) sendEvent(ctx *gin.Context, filter streamFilter, event models.Event) {
+	log := logger.FromContext(ctx)
+	var subject eventSubject
+	if err := json.Unmarshal(event.Data, &subject); err != nil {
+		log.Warn().Err(err).Int64("event", event.ID).Msg("decode event failed")
+		return
+	}
+	if filter.bid != "" && subject.BID != filter.bid {
+		return
+	}
+	msg := sse.Event{Id: strconv.FormatInt(event.ID, 10), Event: string(event.Type), Data: event.Data}
+	switch event.Type {
+	case models.EventBookAdded, models.EventBookDeleted, models.EventBookRestored, models.EventBookPurged:
+	case models.EventCopyAdded, models.EventLoanCreated, models.EventLoanReturned:
+		msg.Event = streamAvailability
+	case models.EventHoldReady, models.EventLoanOverdue:
+		if subject.UID != filter.uid {
+			// Someone else's hold still takes a copy off the shelf.
+			if event.Type != models.EventHoldReady {
+				return
+			}
+			msg.Event = streamAvailability
+		}
+	default:
+		return
+	}
+	if !slices.Contains(filter.types, msg.Event) {
+		return
+	}
+	if msg.Event == streamAvailability {
+		book, err := s.db(ctx).GetBook(subject.BID)
+		if err != nil {
+			log.Warn().Err(err).Str("bid", subject.BID).Msg("get book availability failed")
+			return
+		}
+		msg.Data = availability{BID: book.BID, Count: book.Count, Available: book.Available}
+	}
+	ctx.Render(-1, msg)
+	ctx.Writer.Flush()
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/events"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testEvent(id int64, typ models.EventType, data string) models.Event {
+	return models.Event{ID: id, Type: typ, Data: json.RawMessage(data)}
+}
+
+// readEvents reads n events from the stream as "id event data" lines.
+func readEvents(t *testing.T, body *bufio.Reader, n int) []string {
+	t.Helper()
+	var got []string
+	var fields []string
+	for len(got) < n {
+		line, err := body.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && len(fields) > 0:
+			got = append(got, strings.Join(fields, " "))
+			fields = nil
+		case strings.HasPrefix(line, "id:"), strings.HasPrefix(line, "event:"), strings.HasPrefix(line, "data:"):
+			fields = append(fields, strings.TrimSpace(line[strings.Index(line, ":")+1:]))
+		}
+	}
+	return got
+}
+
+func TestEventStream(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.events = events.NewBus()
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/events", srv.JWTAuthMiddleware(), srv.eventStream)
+	httpSrv := httptest.NewServer(r)
+	defer httpSrv.Close()
+	jwt, err := createJWTToken("test-uid")
+	require.NoError(t, err)
+
+	open := func(t *testing.T, query, lastID string) (*bufio.Reader, func()) {
+		t.Helper()
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, httpSrv.URL+"/events"+query, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", jwt)
+		if lastID != "" {
+			req.Header.Set(lastEventIDHeader, lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return bufio.NewReader(resp.Body), func() {
+			cancel()
+			resp.Body.Close()
+		}
+	}
+
+	t.Run("resume and stream", func(t *testing.T) {
+		storMock := mocks.NewStorage(t)
+		storMock.On("EventsAfter", int64(5), consts.StreamReplayLimit).Return([]models.Event{
+			testEvent(6, models.EventBookAdded, `{"bid":"BID1","lable":"Dune"}`),
+			testEvent(7, models.EventHoldReady, `{"hid":"HID1","bid":"BID2","uid":"other-uid"}`),
+		}, nil)
+		storMock.On("GetBook", "BID2").Return(models.Book{BID: "BID2", Count: 1}, nil).Once()
+		storMock.On("GetBook", "BID1").Return(models.Book{BID: "BID1", Count: 2, Available: 1}, nil).Once()
+		srv.storage = storMock
+		body, closeStream := open(t, "", "5")
+		defer closeStream()
+		assert.Equal(t, []string{
+			`6 BookAdded {"bid":"BID1","lable":"Dune"}`,
+			`7 Availability {"bid":"BID2","count":1,"available":0}`,
+		}, readEvents(t, body, 2))
+
+		assert.NoError(t, srv.events.Publish(context.Background(), []models.Event{
+			testEvent(7, models.EventHoldReady, `{"hid":"HID1","bid":"BID2","uid":"other-uid"}`),
+			testEvent(8, models.EventLoanCreated, `{"lid":"LID1","bid":"BID1","uid":"other-uid"}`),
+			testEvent(9, models.EventUserRegistered, `{"uid":"UID9"}`),
+			testEvent(10, models.EventHoldReady, `{"hid":"HID2","bid":"BID1","uid":"test-uid"}`),
+			// Committed after the later ones, still not seen by the client.
+			testEvent(4, models.EventBookDeleted, `{"bid":"BID3","deleted_by":"UID"}`),
+		}))
+		assert.Equal(t, []string{
+			`8 Availability {"bid":"BID1","count":2,"available":1}`,
+			`10 HoldReady {"hid":"HID2","bid":"BID1","uid":"test-uid"}`,
+			`4 BookDeleted {"bid":"BID3","deleted_by":"UID"}`,
+		}, readEvents(t, body, 3))
+	})
+
+	t.Run("filter by type and book", func(t *testing.T) {
+		srv.storage = mocks.NewStorage(t)
+		body, closeStream := open(t, "?types=BookAdded,BookDeleted&bid=BID2", "")
+		defer closeStream()
+		// The stream subscribes before sending headers, so published events reach it.
+		assert.NoError(t, srv.events.Publish(context.Background(), []models.Event{
+			testEvent(11, models.EventBookAdded, `{"bid":"BID1"}`),
+			testEvent(12, models.EventCopyAdded, `{"barcode":"B1","bid":"BID2"}`),
+			testEvent(13, models.EventBookAdded, `{"bid":"BID2"}`),
+			testEvent(14, models.EventBookDeleted, `{"bid":"BID2","deleted_by":"UID"}`),
+		}))
+		assert.Equal(t, []string{
+			`13 BookAdded {"bid":"BID2"}`,
+			`14 BookDeleted {"bid":"BID2","deleted_by":"UID"}`,
+		}, readEvents(t, body, 2))
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		srv.storage = mocks.NewStorage(t)
+		for query, header := range map[string]string{"?types=UserRegistered": "", "": "last"} {
+			req, err := http.NewRequest(http.MethodGet, httpSrv.URL+"/events"+query, nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", jwt)
+			req.Header.Set(lastEventIDHeader, header)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/config"
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/gin-gonic/gin"
+)
+
+var errNoClientCA = errors.New("no certificates found")
+
+// newTLSConfig serves the certificate of cfg, read again when its files change, over HTTP/2 or
+// HTTP/1.1. With a client CA the clients may present a certificate, requireClientCert decides
+// which routes need one.
+func newTLSConfig(cfg config.HTTPConfig) (*tls.Config, error) {
+	certs, err := newCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certs.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if cfg.ClientCA != "" {
+		data, err := os.ReadFile(cfg.ClientCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("client ca %s: %w", cfg.ClientCA, errNoClientCA)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsCfg, nil
+}
+
+// certReloader hands out the certificate of the TLS handshakes and loads it again once its
+// files change, so that a renewed certificate is picked up without a restart. A pair which
+// fails to load, like one caught half written, is logged and the old certificate is kept.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := r.lastModified()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < consts.CertCheckInterval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+	modTime, err := r.lastModified()
+	if err == nil && modTime.Equal(r.modTime) {
+		return r.cert, nil
+	}
+	if err == nil {
+		err = r.load(modTime)
+	}
+	log := logger.Get()
+	if err != nil {
+		log.Error().Err(err).Str("cert", r.certFile).Msg("reload tls certificate failed, keeping the old one")
+		return r.cert, nil
+	}
+	log.Info().Str("cert", r.certFile).Msg("tls certificate reloaded")
+	return r.cert, nil
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	return nil
+}
+
+// lastModified is the latest change of the certificate and key files.
+func (r *certReloader) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// requireClientCert lets through only the requests with a client certificate signed by the
+// client CA. Without a client CA configured it lets everything through.
+func (s *Server) requireClientCert() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if s.http.ClientCA == "" {
+			return
+		}
+		if ctx.Request.TLS == nil || len(ctx.Request.TLS.VerifiedChains) == 0 {
+			ctx.String(http.StatusForbidden, "client certificate required")
+			ctx.Abort()
+			return
+		}
+		client := ctx.Request.TLS.VerifiedChains[0][0].Subject.CommonName
+		setLogger(ctx, logger.FromContext(ctx).With().Str("client_cert", client).Logger())
+	}
+}
+
+// longLived lifts the read and write timeouts of the server for the routes which stream a
+// response or take a large upload. It goes after the auth and role middleware, so that
+// only the requests allowed to the route get unlimited time.
+func longLived() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		log := logger.FromContext(ctx)
+		rc := http.NewResponseController(ctx.Writer)
+		if err := rc.SetReadDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Warn().Err(err).Msg("lift read deadline failed")
+		}
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Warn().Err(err).Msg("lift write deadline failed")
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/config"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	tls  tls.Certificate
+}
+
+// issue makes a certificate for name signed by parent, a self-signed CA when parent is nil.
+func issue(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	} else {
+		signer, signKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	pair, err := tls.X509KeyPair(certPEM, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, pem: certPEM, tls: pair}
+}
+
+// write saves c as name.crt and name.key in dir.
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, c.pem, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	logger.Get(false)
+	dir := t.TempDir()
+	ca := issue(t, "bookly ca", nil)
+	first, second := issue(t, "first", ca), issue(t, "second", ca)
+	certFile, keyFile := first.write(t, dir, "server")
+
+	r, err := newCertReloader(certFile, keyFile)
+	require.NoError(t, err)
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.tls.Certificate, cert.Certificate)
+
+	// A half written pair keeps the old certificate.
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(certFile, second.pem, 0o600))
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	r.checked = time.Time{}
+	cert, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.tls.Certificate, cert.Certificate)
+
+	// The files are looked at again only after consts.CertCheckInterval.
+	second.write(t, dir, "server")
+	require.NoError(t, os.Chtimes(keyFile, later.Add(time.Minute), later.Add(time.Minute)))
+	cert, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, first.tls.Certificate, cert.Certificate)
+
+	r.checked = time.Time{}
+	cert, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	assert.Equal(t, second.tls.Certificate, cert.Certificate)
+}
+
+func TestAdminClientCert(t *testing.T) {
+	logger.Get(false)
+	dir := t.TempDir()
+	ca := issue(t, "bookly ca", nil)
+	certFile, keyFile := issue(t, "bookly", ca).write(t, dir, "server")
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+	stranger := issue(t, "stranger", issue(t, "other ca", nil))
+
+	var srv Server
+	srv.http = config.HTTPConfig{CertFile: certFile, KeyFile: keyFile, ClientCA: caFile}
+	r := gin.New()
+	r.GET("/admin/ping", srv.requireClientCert(), func(ctx *gin.Context) { ctx.String(http.StatusOK, ctx.Request.Proto) })
+	r.GET("/ping", func(ctx *gin.Context) { ctx.String(http.StatusOK, ctx.Request.Proto) })
+	tlsCfg, err := newTLSConfig(srv.http)
+	require.NoError(t, err)
+	srv.serv = &http.Server{Handler: r, TLSConfig: tlsCfg, ReadHeaderTimeout: time.Second}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		if err := srv.serv.ServeTLS(ln, "", ""); !errors.Is(err, http.ErrServerClosed) {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() { _ = srv.serv.Close() })
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	type test struct {
+		name       string
+		path       string
+		clientCert *testCert
+		statusCode int
+		body       string
+	}
+	tests := []test{
+		{
+			name:       "public route without a certificate",
+			path:       "/ping",
+			statusCode: http.StatusOK,
+			body:       "HTTP/2.0",
+		},
+		{
+			name:       "admin route without a certificate",
+			path:       "/admin/ping",
+			statusCode: http.StatusForbidden,
+			body:       "client certificate required",
+		},
+		{
+			name:       "admin route with a certificate of another ca",
+			path:       "/admin/ping",
+			clientCert: stranger,
+			statusCode: http.StatusForbidden,
+			body:       "client certificate required",
+		},
+		{
+			name:       "admin route with a certificate",
+			path:       "/admin/ping",
+			clientCert: issue(t, "ops", ca),
+			statusCode: http.StatusOK,
+			body:       "HTTP/2.0",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tlsClient := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+			if tc.clientCert != nil {
+				tlsClient.Certificates = []tls.Certificate{tc.clientCert.tls}
+			}
+			httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsClient, ForceAttemptHTTP2: true}}
+			resp, body := get(t, httpClient, "https://"+ln.Addr().String()+tc.path)
+			assert.Equal(t, tc.statusCode, resp.StatusCode)
+			assert.Equal(t, tc.body, body)
+		})
+	}
+
+}
+
+func get(t *testing.T, httpClient *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := httpClient.Get(url) //nolint:noctx //test request
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var body []byte
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) trashBooks(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	trash, err := s.db(ctx).GetTrash()
+	if err != nil {
+		log.Error().Err(err).Msg("get trash failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, trash)
+}
+
+func (s *Server) restoreBook(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	id := ctx.Param("id")
+	if err := s.db(ctx).RestoreBook(id, auditOrigin(ctx)); err != nil {
+		log.Error().Err(err).Msg("restore book failed")
+		if errors.Is(err, storerrros.ErrBookNotTrashed) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.String(http.StatusOK, "book "+id+" was restored")
+}
+
+// purgeBook removes a trashed book for good without waiting for the purge job.
+// Books with copies on loan can not be purged until the copies are returned.
+func (s *Server) purgeBook(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	_, exist := ctx.Get("uid")
+	if !exist {
+		log.Error().Msg("user ID not found")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "User ID not found"})
+		return
+	}
+	id := ctx.Param("id")
+	if err := s.db(ctx).PurgeBook(id, auditOrigin(ctx)); err != nil {
+		log.Error().Err(err).Msg("purge book failed")
+		switch {
+		case errors.Is(err, storerrros.ErrBookNotTrashed):
+			ctx.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, storerrros.ErrBookOnLoan):
+			ctx.String(http.StatusConflict, err.Error())
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	ctx.String(http.StatusOK, "book "+id+" was purged")
+}
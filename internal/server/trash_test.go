@@ -0,0 +1,183 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// byTestUser matches the audit origin of the changes made by the test user.
+func byTestUser() any {
+	return mock.MatchedBy(func(origin models.AuditEntry) bool { return origin.Actor == "test-uid" })
+}
+
+func TestTrash(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.GET("/books/trash", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleLibrarian), srv.trashBooks)
+	r.DELETE("/books/:id", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleLibrarian), srv.removeBook)
+	r.POST("/books/:id/restore", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleLibrarian), srv.restoreBook)
+	r.DELETE("/books/:id/purge", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleAdmin), srv.purgeBook)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	deleted := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name   string
+		role   string
+		method string
+		path   string
+		mock   func(*mocks.Storage)
+		want   want
+	}
+	tests := []test{
+		{
+			name:   "delete book",
+			role:   models.RoleLibrarian,
+			method: http.MethodDelete,
+			path:   "/books/BID1",
+			mock: func(m *mocks.Storage) {
+				m.On("SetDeleteStatus", "BID1", byTestUser()).Return(nil)
+			},
+			want: want{
+				body:       `book BID1 was deleted`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "delete unknown book",
+			role:   models.RoleLibrarian,
+			method: http.MethodDelete,
+			path:   "/books/BID404",
+			mock: func(m *mocks.Storage) {
+				m.On("SetDeleteStatus", "BID404", byTestUser()).Return(storerrros.ErrBookNoExist)
+			},
+			want: want{
+				body:       `book does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "member can not delete",
+			role:   models.RoleMember,
+			method: http.MethodDelete,
+			path:   "/books/BID1",
+			want: want{
+				body:       `access denied`,
+				statusCode: http.StatusForbidden,
+			},
+		},
+		{
+			name:   "list trash",
+			role:   models.RoleLibrarian,
+			method: http.MethodGet,
+			path:   "/books/trash",
+			mock: func(m *mocks.Storage) {
+				m.On("GetTrash").Return([]models.TrashedBook{{
+					Book:      models.Book{BID: "BID1", Lable: "Dune", Author: "Frank Herbert", Desc: "Spice", Age: 12},
+					DeletedBy: "test-uid",
+					DeletedAt: deleted,
+				}}, nil)
+			},
+			want: want{
+				body: `[{"bid":"BID1","lable":"Dune","author":"Frank Herbert","desc":"Spice","age":12,` +
+					`"deleted_by":"test-uid","deleted_at":"2024-10-01T12:00:00Z"}]`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "restore book",
+			role:   models.RoleLibrarian,
+			method: http.MethodPost,
+			path:   "/books/BID1/restore",
+			mock: func(m *mocks.Storage) {
+				m.On("RestoreBook", "BID1", byTestUser()).Return(nil)
+			},
+			want: want{
+				body:       `book BID1 was restored`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "restore book not in trash",
+			role:   models.RoleLibrarian,
+			method: http.MethodPost,
+			path:   "/books/BID2/restore",
+			mock:   func(m *mocks.Storage) { m.On("RestoreBook", "BID2", byTestUser()).Return(storerrros.ErrBookNotTrashed) },
+			want: want{
+				body:       `book is not in the trash`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "librarian can not purge",
+			role:   models.RoleLibrarian,
+			method: http.MethodDelete,
+			path:   "/books/BID1/purge",
+			want: want{
+				body:       `access denied`,
+				statusCode: http.StatusForbidden,
+			},
+		},
+		{
+			name:   "purge book",
+			role:   models.RoleAdmin,
+			method: http.MethodDelete,
+			path:   "/books/BID1/purge",
+			mock: func(m *mocks.Storage) {
+				m.On("PurgeBook", "BID1", byTestUser()).Return(nil)
+			},
+			want: want{
+				body:       `book BID1 was purged`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "purge book on loan",
+			role:   models.RoleAdmin,
+			method: http.MethodDelete,
+			path:   "/books/BID3/purge",
+			mock:   func(m *mocks.Storage) { m.On("PurgeBook", "BID3", byTestUser()).Return(storerrros.ErrBookOnLoan) },
+			want: want{
+				body:       `book has copies on loan`,
+				statusCode: http.StatusConflict,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: tc.role}, nil)
+			if tc.mock != nil {
+				tc.mock(storMock)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = tc.method
+			req.URL = httpSrv.URL + tc.path
+			req.SetHeader("Authorization", jwt)
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			assert.Equal(t, tc.want.body, string(resp.Body()))
+		})
+	}
+}
@@ -11,7 +11,7 @@ import (
 )
 
 func (s *Server) register(ctx *gin.Context) {
-	log := logger.Get()
+	log := logger.FromContext(ctx)
 	var user models.User
 	if err := ctx.ShouldBindBodyWithJSON(&user); err != nil {
 		log.Error().Err(err).Msg("unmarshal body failed")
@@ -23,7 +23,11 @@ func (s *Server) register(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	uuid, err := s.storage.SaveUser(user)
+	user.Role = ""
+	if s.adminEmail != "" && user.Email == s.adminEmail {
+		user.Role = models.RoleAdmin
+	}
+	uuid, err := s.db(ctx).SaveUser(user, auditOrigin(ctx))
 	if err != nil {
 		if errors.Is(err, storerrros.ErrUserExists) {
 			log.Error().Msg(err.Error())
@@ -46,7 +50,7 @@ func (s *Server) register(ctx *gin.Context) {
 }
 
 func (s *Server) login(ctx *gin.Context) {
-	log := logger.Get()
+	log := logger.FromContext(ctx)
 	var user models.User
 	if err := ctx.ShouldBindBodyWithJSON(&user); err != nil {
 		log.Error().Err(err).Msg("unmarshal body failed")
@@ -58,7 +62,7 @@ func (s *Server) login(ctx *gin.Context) {
 		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	uuid, err := s.storage.ValidUser(user)
+	uuid, err := s.db(ctx).ValidUser(user)
 	if err != nil {
 		if errors.Is(err, storerrros.ErrUserNoExist) {
 			log.Error().Err(err).Msg("user not found")
@@ -85,9 +89,9 @@ func (s *Server) login(ctx *gin.Context) {
 }
 
 func (s *Server) userInfo(ctx *gin.Context) {
-	log := logger.Get()
+	log := logger.FromContext(ctx)
 	uid := ctx.GetString("uid")
-	user, err := s.storage.GetUser(uid)
+	user, err := s.db(ctx).GetUser(uid)
 	if err != nil {
 		log.Error().Err(err).Msg("failed get user from db")
 		if errors.Is(err, storerrros.ErrUserNotFound) {
@@ -99,3 +103,33 @@ func (s *Server) userInfo(ctx *gin.Context) {
 	}
 	ctx.JSON(http.StatusFound, user)
 }
+
+type roleRequest struct {
+	Role string `json:"role" validate:"required,oneof=member librarian admin"`
+}
+
+func (s *Server) setRole(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	var req roleRequest
+	if err := ctx.ShouldBindBodyWithJSON(&req); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(req); err != nil {
+		log.Error().Err(err).Msg("validate role failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	uid := ctx.Param("uid")
+	if err := s.db(ctx).SetUserRole(uid, req.Role, auditOrigin(ctx)); err != nil {
+		log.Error().Err(err).Msg("set user role failed")
+		if errors.Is(err, storerrros.ErrUserNotFound) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.String(http.StatusOK, "user %s now has role %s", uid, req.Role)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/go-playground/validator"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestRegister(t *testing.T) {
@@ -133,7 +134,7 @@ func TestRegister(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			storMock := mocks.NewStorage(t)
 			if tc.mock {
-				storMock.On("SaveUser", tc.user).Return(tc.uuid, tc.err)
+				storMock.On("SaveUser", tc.user, mock.AnythingOfType("models.AuditEntry")).Return(tc.uuid, tc.err)
 				srv.storage = storMock
 			}
 			req := resty.New().R()
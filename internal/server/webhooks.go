@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const secretSize = 32
+
+// addWebhook registers a webhook. Without a secret in the request a random one is made.
+// The secret is returned only here, receivers need it to verify the signatures.
+func (s *Server) addWebhook(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	var hook models.Webhook
+	if err := ctx.ShouldBindBodyWithJSON(&hook); err != nil {
+		log.Error().Err(err).Msg("unmarshal body failed")
+		ctx.String(http.StatusBadRequest, "incorrectly entered data")
+		return
+	}
+	if err := s.valid.Struct(hook); err != nil {
+		log.Error().Err(err).Msg("validate webhook failed")
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if hook.Secret == "" {
+		secret := make([]byte, secretSize)
+		if _, err := rand.Read(secret); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		hook.Secret = hex.EncodeToString(secret)
+	}
+	hook.ID = uuid.New().String()
+	hook.CreatedBy = ctx.GetString("uid")
+	hook.CreatedAt = time.Now()
+	if err := s.db(ctx).SaveWebhook(hook); err != nil {
+		log.Error().Err(err).Msg("save webhook failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusCreated, hook)
+}
+
+func (s *Server) allWebhooks(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	hooks, err := s.db(ctx).GetWebhooks()
+	if err != nil {
+		log.Error().Err(err).Msg("get webhooks failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	ctx.JSON(http.StatusOK, hooks)
+}
+
+func (s *Server) removeWebhook(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	id := ctx.Param("id")
+	if err := s.db(ctx).DeleteWebhook(id); err != nil {
+		log.Error().Err(err).Msg("delete webhook failed")
+		if errors.Is(err, storerrros.ErrWebhookNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.String(http.StatusOK, "webhook "+id+" was deleted")
+}
+
+// webhookDeliveries lists the latest deliveries of the webhook, "status" narrows them down, e.g. to failed ones.
+func (s *Server) webhookDeliveries(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	id := ctx.Param("id")
+	if _, err := s.db(ctx).GetWebhook(id); err != nil {
+		if errors.Is(err, storerrros.ErrWebhookNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status := models.DeliveryStatus(ctx.Query("status"))
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryFailed:
+	default:
+		ctx.String(http.StatusBadRequest, "unknown delivery status")
+		return
+	}
+	deliveries, err := s.db(ctx).GetDeliveries(id, status, consts.DeliveriesLimit)
+	if err != nil {
+		log.Error().Err(err).Msg("get deliveries failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, deliveries)
+}
+
+func (s *Server) deliveryAttempts(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	id, ok := deliveryID(ctx)
+	if !ok {
+		return
+	}
+	if _, err := s.db(ctx).GetDelivery(id); err != nil {
+		if errors.Is(err, storerrros.ErrDeliveryNoExist) {
+			ctx.String(http.StatusNotFound, err.Error())
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	attempts, err := s.db(ctx).GetDeliveryAttempts(id)
+	if err != nil {
+		log.Error().Err(err).Msg("get delivery attempts failed")
+		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, attempts)
+}
+
+// replayDelivery sends a failed delivery again and wakes the dispatcher up so it goes out right away.
+func (s *Server) replayDelivery(ctx *gin.Context) {
+	log := logger.FromContext(ctx)
+	id, ok := deliveryID(ctx)
+	if !ok {
+		return
+	}
+	delivery, err := s.db(ctx).ReplayDelivery(id)
+	if err != nil {
+		log.Error().Err(err).Msg("replay delivery failed")
+		switch {
+		case errors.Is(err, storerrros.ErrDeliveryNoExist):
+			ctx.String(http.StatusNotFound, err.Error())
+		case errors.Is(err, storerrros.ErrDeliveryNotFailed):
+			ctx.String(http.StatusConflict, err.Error())
+		default:
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	if err = s.jobs.Trigger(webhookJob); err != nil {
+		log.Warn().Err(err).Msg("trigger webhook deliveries failed")
+	}
+	ctx.JSON(http.StatusAccepted, delivery)
+}
+
+func deliveryID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.String(http.StatusBadRequest, "delivery ID must be a number")
+		return 0, false
+	}
+	return id, true
+}
+
+// markOverdueLoans reports the loans which passed their due date, the events go out to the webhooks.
+func (s *Server) markOverdueLoans(ctx context.Context) error {
+	log := logger.FromContext(ctx)
+	marked, err := s.db(ctx).MarkOverdueLoans(time.Now())
+	if err != nil {
+		return err
+	}
+	if marked > 0 {
+		log.Info().Int("loans", marked).Msg("overdue loans reported")
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,268 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/server/mocks"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/Dorrrke/g3-bookly/internal/worker"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestWebhooks(t *testing.T) {
+	logger.Get(false)
+	var srv Server
+	srv.valid = validator.New()
+	srv.jobs = worker.New(worker.Backoff{})
+	r := gin.New()
+	r.Use(gin.Recovery())
+	admin := r.Group("/admin", srv.JWTAuthMiddleware(), srv.RoleMiddleware(models.RoleAdmin))
+	admin.GET("/webhooks", srv.allWebhooks)
+	admin.POST("/webhooks", srv.addWebhook)
+	admin.DELETE("/webhooks/:id", srv.removeWebhook)
+	admin.GET("/webhooks/:id/deliveries", srv.webhookDeliveries)
+	admin.GET("/deliveries/:id/attempts", srv.deliveryAttempts)
+	admin.POST("/deliveries/:id/replay", srv.replayDelivery)
+	httpSrv := httptest.NewServer(r)
+	jwt, err := createJWTToken("test-uid")
+	assert.NoError(t, err)
+	created := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	type want struct {
+		body       string
+		statusCode int
+	}
+	type test struct {
+		name   string
+		role   string
+		method string
+		path   string
+		body   string
+		mock   func(*mocks.Storage)
+		want   want
+	}
+	tests := []test{
+		{
+			name:   "member can not list webhooks",
+			role:   models.RoleMember,
+			method: http.MethodGet,
+			path:   "/admin/webhooks",
+			want: want{
+				body:       `access denied`,
+				statusCode: http.StatusForbidden,
+			},
+		},
+		{
+			name:   "list webhooks without secrets",
+			role:   models.RoleAdmin,
+			method: http.MethodGet,
+			path:   "/admin/webhooks",
+			mock: func(m *mocks.Storage) {
+				m.On("GetWebhooks").Return([]models.Webhook{{
+					ID:        "WH1",
+					URL:       "https://example.com/hook",
+					Secret:    "0123456789abcdef",
+					Events:    []models.EventType{models.EventHoldReady},
+					CreatedAt: created,
+				}}, nil)
+			},
+			want: want{
+				body: `[{"id":"WH1","url":"https://example.com/hook","events":["HoldReady"],` +
+					`"created_at":"2024-10-01T12:00:00Z"}]`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "register with given secret",
+			role:   models.RoleAdmin,
+			method: http.MethodPost,
+			path:   "/admin/webhooks",
+			body:   `{"url":"https://example.com/hook","secret":"0123456789abcdef","events":["LoanOverdue"]}`,
+			mock: func(m *mocks.Storage) {
+				m.On("SaveWebhook", mock.MatchedBy(func(hook models.Webhook) bool {
+					return hook.ID != "" && hook.Secret == "0123456789abcdef" && hook.CreatedBy == "test-uid"
+				})).Return(nil)
+			},
+			want: want{statusCode: http.StatusCreated},
+		},
+		{
+			name:   "register with unknown event",
+			role:   models.RoleAdmin,
+			method: http.MethodPost,
+			path:   "/admin/webhooks",
+			body:   `{"url":"https://example.com/hook","events":["BookBurned"]}`,
+			want: want{
+				body: `{"error":"Key: 'Webhook.Events[0]' Error:Field validation for 'Events[0]' ` +
+					`failed on the 'oneof' tag"}`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "delete unknown webhook",
+			role:   models.RoleAdmin,
+			method: http.MethodDelete,
+			path:   "/admin/webhooks/WH404",
+			mock:   func(m *mocks.Storage) { m.On("DeleteWebhook", "WH404").Return(storerrros.ErrWebhookNoExist) },
+			want: want{
+				body:       `webhook does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "failed deliveries",
+			role:   models.RoleAdmin,
+			method: http.MethodGet,
+			path:   "/admin/webhooks/WH1/deliveries?status=failed",
+			mock: func(m *mocks.Storage) {
+				m.On("GetWebhook", "WH1").Return(models.Webhook{ID: "WH1"}, nil)
+				m.On("GetDeliveries", "WH1", models.DeliveryFailed, consts.DeliveriesLimit).Return([]models.Delivery{{
+					ID:        7,
+					WebhookID: "WH1",
+					EventID:   42,
+					EventType: models.EventBookAdded,
+					Payload:   json.RawMessage(`{"id":42}`),
+					Status:    models.DeliveryFailed,
+					Attempts:  8,
+					LastError: "unexpected status 500",
+					CreatedAt: created,
+				}}, nil)
+			},
+			want: want{
+				body: `[{"id":7,"webhook_id":"WH1","event_id":42,"event_type":"BookAdded","payload":{"id":42},` +
+					`"status":"failed","attempts":8,"last_error":"unexpected status 500",` +
+					`"created_at":"2024-10-01T12:00:00Z"}]`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "deliveries of unknown webhook",
+			role:   models.RoleAdmin,
+			method: http.MethodGet,
+			path:   "/admin/webhooks/WH404/deliveries",
+			mock: func(m *mocks.Storage) {
+				m.On("GetWebhook", "WH404").Return(models.Webhook{}, storerrros.ErrWebhookNoExist)
+			},
+			want: want{
+				body:       `webhook does not exists`,
+				statusCode: http.StatusNotFound,
+			},
+		},
+		{
+			name:   "delivery attempts",
+			role:   models.RoleAdmin,
+			method: http.MethodGet,
+			path:   "/admin/deliveries/7/attempts",
+			mock: func(m *mocks.Storage) {
+				m.On("GetDelivery", int64(7)).Return(models.Delivery{ID: 7}, nil)
+				m.On("GetDeliveryAttempts", int64(7)).Return([]models.DeliveryAttempt{{
+					ID:         1,
+					DeliveryID: 7,
+					At:         created,
+					StatusCode: http.StatusInternalServerError,
+					Error:      "unexpected status 500",
+					Duration:   time.Millisecond,
+				}}, nil)
+			},
+			want: want{
+				body: `[{"id":1,"delivery_id":7,"at":"2024-10-01T12:00:00Z","status_code":500,` +
+					`"error":"unexpected status 500","duration":1000000}]`,
+				statusCode: http.StatusOK,
+			},
+		},
+		{
+			name:   "bad delivery ID",
+			role:   models.RoleAdmin,
+			method: http.MethodPost,
+			path:   "/admin/deliveries/seven/replay",
+			want: want{
+				body:       `delivery ID must be a number`,
+				statusCode: http.StatusBadRequest,
+			},
+		},
+		{
+			name:   "replay failed delivery",
+			role:   models.RoleAdmin,
+			method: http.MethodPost,
+			path:   "/admin/deliveries/7/replay",
+			mock: func(m *mocks.Storage) {
+				m.On("ReplayDelivery", int64(7)).Return(models.Delivery{
+					ID:        7,
+					WebhookID: "WH1",
+					EventID:   42,
+					EventType: models.EventBookAdded,
+					Payload:   json.RawMessage(`{"id":42}`),
+					Status:    models.DeliveryPending,
+					CreatedAt: created,
+				}, nil)
+			},
+			want: want{
+				body: `{"id":7,"webhook_id":"WH1","event_id":42,"event_type":"BookAdded","payload":{"id":42},` +
+					`"status":"pending","attempts":0,"created_at":"2024-10-01T12:00:00Z"}`,
+				statusCode: http.StatusAccepted,
+			},
+		},
+		{
+			name:   "replay delivered delivery",
+			role:   models.RoleAdmin,
+			method: http.MethodPost,
+			path:   "/admin/deliveries/8/replay",
+			mock: func(m *mocks.Storage) {
+				m.On("ReplayDelivery", int64(8)).Return(models.Delivery{}, storerrros.ErrDeliveryNotFailed)
+			},
+			want: want{
+				body:       `only failed deliveries can be replayed`,
+				statusCode: http.StatusConflict,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			storMock := mocks.NewStorage(t)
+			storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: tc.role}, nil)
+			if tc.mock != nil {
+				tc.mock(storMock)
+			}
+			srv.storage = storMock
+			req := resty.New().R()
+			req.Method = tc.method
+			req.URL = httpSrv.URL + tc.path
+			req.SetHeader("Authorization", jwt)
+			if tc.body != "" {
+				req.SetBody(tc.body)
+			}
+			resp, err := req.Send()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want.statusCode, resp.StatusCode())
+			if tc.want.body != "" {
+				assert.Equal(t, tc.want.body, string(resp.Body()))
+			}
+		})
+	}
+
+	t.Run("register with generated secret", func(t *testing.T) {
+		storMock := mocks.NewStorage(t)
+		storMock.On("GetUser", "test-uid").Return(models.User{UID: "test-uid", Role: models.RoleAdmin}, nil)
+		storMock.On("SaveWebhook", mock.Anything).Return(nil)
+		srv.storage = storMock
+		var hook models.Webhook
+		resp, err := resty.New().R().SetHeader("Authorization", jwt).SetResult(&hook).
+			SetBody(`{"url":"https://example.com/hook","events":["BookAdded","HoldReady"]}`).
+			Post(httpSrv.URL + "/admin/webhooks")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode())
+		assert.Len(t, hook.Secret, 2*secretSize)
+		assert.Equal(t, []models.EventType{models.EventBookAdded, models.EventHoldReady}, hook.Events)
+	})
+}
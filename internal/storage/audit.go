@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// redactedFields never get into the audit log.
+var redactedFields = map[string]bool{"pass": true} //nolint:gochecknoglobals //constant set
+
+// auditEntry fills the entry of the change made on behalf of the origin, which carries the actor and the request.
+func auditEntry(origin models.AuditEntry, action models.AuditAction, entity, id string,
+	changes []models.FieldChange,
+) models.AuditEntry {
+	origin.Action, origin.Entity, origin.EntityID = action, entity, id
+	origin.At = time.Now()
+	origin.Changes = changes
+	if origin.Changes == nil {
+		origin.Changes = []models.FieldChange{}
+	}
+	return origin
+}
+
+// diff compares the JSON fields of two values of the same type. A nil before means the value is created.
+func diff(before, after any) []models.FieldChange {
+	old, cur := fields(before), fields(after)
+	names := make([]string, 0, len(cur))
+	for name := range cur {
+		names = append(names, name)
+	}
+	for name := range old {
+		if _, ok := cur[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var changes []models.FieldChange
+	for _, name := range names {
+		if redactedFields[name] || reflect.DeepEqual(old[name], cur[name]) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{Field: name, Before: old[name], After: cur[name]})
+	}
+	return changes
+}
+
+func fields(value any) map[string]any {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	created := diff(nil, models.User{UID: "UID1", Email: "reader@bookly.ru", Pass: "secret-pass", Age: 20})
+	assert.Equal(t, []models.FieldChange{
+		{Field: "age", After: 20.0},
+		{Field: "email", After: "reader@bookly.ru"},
+		{Field: "uuid", After: "UID1"},
+	}, created)
+
+	before := models.Book{BID: "BID1", Lable: "Dune", Author: "Frank Herbert", Count: 1, Available: 1}
+	after := before
+	after.Count, after.Available = 2, 2
+	assert.Equal(t, []models.FieldChange{
+		{Field: "available", Before: 1.0, After: 2.0},
+		{Field: "count", Before: 1.0, After: 2.0},
+	}, diff(before, after))
+	assert.Empty(t, diff(before, before))
+}
+
+func TestMemStorageAudit(t *testing.T) {
+	logger.Get(false)
+	stor := New()
+	origin := models.AuditEntry{Actor: "UID1", RequestID: "req-1"}
+	book := models.Book{Lable: "Dune", Author: "Frank Herbert", Desc: "Desert planet", Age: 12}
+	require.NoError(t, stor.SaveBook(book, origin))
+	require.NoError(t, stor.SaveBook(book, origin))
+	books, err := stor.GetBooks()
+	require.NoError(t, err)
+	require.NoError(t, stor.SetDeleteStatus(books[0].BID, origin))
+
+	entries, err := stor.GetAudit(models.AuditBook, books[0].BID, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	for _, entry := range entries {
+		assert.Equal(t, "UID1", entry.Actor)
+		assert.Equal(t, "req-1", entry.RequestID)
+	}
+	assert.Equal(t, models.AuditDelete, entries[0].Action)
+	assert.Equal(t, models.AuditCount, entries[1].Action)
+	assert.Equal(t, []models.FieldChange{
+		{Field: "available", Before: 1.0, After: 2.0},
+		{Field: "count", Before: 1.0, After: 2.0},
+	}, entries[1].Changes)
+	assert.Equal(t, models.AuditCreate, entries[2].Action)
+
+	items, err := stor.GetItems(books[0].BID)
+	require.NoError(t, err)
+	item := items[0]
+	item.Condition, item.Status = "poor", models.ItemDamaged
+	_, err = stor.UpdateItem(item, origin)
+	require.NoError(t, err)
+	entries, err = stor.GetAudit(models.AuditItem, item.Barcode, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "UID1", entries[0].Actor)
+	assert.Equal(t, models.AuditUpdate, entries[0].Action)
+	assert.Equal(t, []models.FieldChange{
+		{Field: "condition", Before: items[0].Condition, After: "poor"},
+		{Field: "status", Before: string(models.ItemAvailable), After: string(models.ItemDamaged)},
+	}, entries[0].Changes)
+
+	uid, err := stor.SaveUser(models.User{Email: "reader@bookly.ru", Pass: "password", Age: 20},
+		models.AuditEntry{RequestID: "req-2"})
+	require.NoError(t, err)
+	entries, err = stor.GetAudit(models.AuditUser, uid, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, uid, entries[0].Actor, "the user registers themselves")
+	for _, change := range entries[0].Changes {
+		assert.NotEqual(t, "pass", change.Field)
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+)
+
+// addAudit records the change in the transaction which makes it, so the change and its entry are saved together.
+func addAudit(ctx context.Context, q querier, origin models.AuditEntry, action models.AuditAction, entity, id string,
+	changes []models.FieldChange,
+) error {
+	entry := auditEntry(origin, action, entity, id, changes)
+	_, err := q.Exec(ctx, `INSERT INTO audit (entity, entity_id, action, actor, request_id, at, changes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		entry.Entity, entry.EntityID, entry.Action, entry.Actor, entry.RequestID, entry.At, entry.Changes)
+	return err
+}
+
+// GetAudit returns the latest entries, the newest first. Empty entity or id match any.
+func (dbs *DBStorage) GetAudit(entity, id string, limit int) ([]models.AuditEntry, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT id, entity, entity_id, action, actor, request_id, at, changes
+		FROM audit WHERE ($1 = '' OR entity = $1) AND ($2 = '' OR entity_id = $2)
+		ORDER BY at DESC, id DESC LIMIT $3`, entity, id, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("get audit failed")
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		err = rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &entry.Action, &entry.Actor,
+			&entry.RequestID, &entry.At, &entry.Changes)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// PurgeAudit removes the entries recorded before the given time.
+func (dbs *DBStorage) PurgeAudit(before time.Time) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, "DELETE FROM audit WHERE at < $1", before)
+	if err != nil {
+		log.Error().Err(err).Msg("purge audit failed")
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,480 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const holdColumns = `hid, bid, uid, pickup_branch, COALESCE(barcode, ''), status, created_at, ready_at`
+
+const transferColumns = `tid, barcode, COALESCE(from_branch, ''), to_branch, COALESCE(hid, ''), status,
+	COALESCE(requested_by, ''), requested_at, shipped_at, received_at`
+
+func scanHold(row pgx.Row) (models.Hold, error) {
+	var hold models.Hold
+	err := row.Scan(&hold.HID, &hold.BID, &hold.UID, &hold.PickupBranch, &hold.Barcode, &hold.Status,
+		&hold.CreatedAt, &hold.ReadyAt)
+	return hold, err
+}
+
+func scanTransfer(row pgx.Row) (models.Transfer, error) {
+	var tr models.Transfer
+	err := row.Scan(&tr.TID, &tr.Barcode, &tr.FromBranch, &tr.ToBranch, &tr.HID, &tr.Status,
+		&tr.RequestedBy, &tr.RequestedAt, &tr.ShippedAt, &tr.ReceivedAt)
+	return tr, err
+}
+
+func (dbs *DBStorage) SaveBranch(branch models.Branch) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	_, err := dbs.conn.Exec(ctx, "INSERT INTO branches (id, name, address) VALUES ($1, $2, $3)",
+		branch.ID, branch.Name, branch.Address)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+			return storerrros.ErrBranchExists
+		}
+		log.Error().Err(err).Msg("save branch failed")
+		return err
+	}
+	return nil
+}
+
+func (dbs *DBStorage) GetBranches() ([]models.Branch, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, "SELECT id, name, address FROM branches ORDER BY name")
+	if err != nil {
+		log.Error().Err(err).Msg("failed get branches from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var branches []models.Branch
+	for rows.Next() {
+		var branch models.Branch
+		if err = rows.Scan(&branch.ID, &branch.Name, &branch.Address); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+	return branches, rows.Err()
+}
+
+// GetBranchBooks returns books that have copies currently at the branch, counting only those copies.
+func (dbs *DBStorage) GetBranchBooks(branch string) ([]models.Book, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+bookColumns+`
+		FROM books b JOIN items i ON i.bid = b.bid AND i.current_branch = $1
+		WHERE b.deleted=false GROUP BY b.bid`, branch)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get branch books from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(books) < 1 {
+		return nil, storerrros.ErrEmptyBooksList
+	}
+	return books, nil
+}
+
+func (dbs *DBStorage) GetAvailability(bid string) ([]models.Availability, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT COALESCE(current_branch, ''),
+		COUNT(*) FILTER (WHERE status <> 'lost'), COUNT(*) FILTER (WHERE status = 'available')
+		FROM items WHERE bid=$1 GROUP BY current_branch ORDER BY 1`, bid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get availability from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var res []models.Availability
+	for rows.Next() {
+		var av models.Availability
+		if err = rows.Scan(&av.Branch, &av.Count, &av.Available); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		res = append(res, av)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(res) < 1 {
+		return nil, storerrros.ErrItemNoExist
+	}
+	return res, nil
+}
+
+// PlaceHold queues the member for the book and immediately allocates a free copy when there is one,
+// preferring copies that are already at the pickup branch.
+func (dbs *DBStorage) PlaceHold(hold models.Hold) (models.Hold, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Hold{}, err
+	}
+	defer rollback(ctx, tx)
+	var exists bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM books WHERE bid=$1 AND deleted=false)", hold.BID).
+		Scan(&exists)
+	if err != nil {
+		log.Error().Err(err).Msg("check book failed")
+		return models.Hold{}, err
+	}
+	if !exists {
+		return models.Hold{}, storerrros.ErrBookNoExist
+	}
+	hold.HID = uuid.New().String()
+	hold.Status = models.HoldWaiting
+	hold.CreatedAt = time.Now()
+	_, err = tx.Exec(ctx, `INSERT INTO holds (hid, bid, uid, pickup_branch, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		hold.HID, hold.BID, hold.UID, hold.PickupBranch, hold.Status, hold.CreatedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("save hold failed")
+		return models.Hold{}, mapItemErr(err)
+	}
+	var barcode, branch string
+	err = tx.QueryRow(ctx, `SELECT barcode, COALESCE(current_branch, '') FROM items
+		WHERE bid=$1 AND status=$2
+		ORDER BY current_branch IS NOT DISTINCT FROM $3 DESC, barcode LIMIT 1 FOR UPDATE SKIP LOCKED`,
+		hold.BID, models.ItemAvailable, hold.PickupBranch).Scan(&barcode, &branch)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		log.Error().Err(err).Msg("search free copy failed")
+		return models.Hold{}, err
+	default:
+		if err = allocateItem(ctx, tx, hold, barcode, branch); err != nil {
+			log.Error().Err(err).Msg("allocate copy failed")
+			return models.Hold{}, err
+		}
+	}
+	hold, err = scanHold(tx.QueryRow(ctx, `SELECT `+holdColumns+` FROM holds WHERE hid=$1`, hold.HID))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to scan data from db")
+		return models.Hold{}, err
+	}
+	return hold, tx.Commit(ctx)
+}
+
+func (dbs *DBStorage) GetHolds(uid string) ([]models.Hold, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+holdColumns+` FROM holds WHERE uid=$1 ORDER BY created_at DESC`, uid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get holds from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var holds []models.Hold
+	for rows.Next() {
+		var hold models.Hold
+		if hold, err = scanHold(rows); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+// CancelHold closes the hold of the member and releases the allocated copy unless it is already on the way.
+func (dbs *DBStorage) CancelHold(hid, uid string) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return err
+	}
+	defer rollback(ctx, tx)
+	hold, err := scanHold(tx.QueryRow(ctx, `SELECT `+holdColumns+` FROM holds WHERE hid=$1 AND uid=$2 FOR UPDATE`,
+		hid, uid))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storerrros.ErrHoldNoExist
+		}
+		log.Error().Err(err).Msg("get hold failed")
+		return err
+	}
+	if hold.Status == models.HoldFulfilled || hold.Status == models.HoldCancelled {
+		return storerrros.ErrHoldClosed
+	}
+	if _, err = tx.Exec(ctx, "UPDATE holds SET status=$2 WHERE hid=$1", hid, models.HoldCancelled); err != nil {
+		log.Error().Err(err).Msg("cancel hold failed")
+		return err
+	}
+	release := hold.Status == models.HoldReady
+	if hold.Status == models.HoldInTransit {
+		tag, err := tx.Exec(ctx, "UPDATE transfers SET status=$3 WHERE hid=$1 AND status=$2",
+			hid, models.TransferRequested, models.TransferCancelled)
+		if err != nil {
+			log.Error().Err(err).Msg("cancel transfer failed")
+			return err
+		}
+		release = tag.RowsAffected() > 0
+	}
+	if release {
+		if err = shelveItem(ctx, tx, hold.Barcode); err != nil {
+			log.Error().Err(err).Msg("shelve item failed")
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// SaveTransfer requests moving a free copy from its current branch to another one.
+func (dbs *DBStorage) SaveTransfer(tr models.Transfer) (models.Transfer, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Transfer{}, err
+	}
+	defer rollback(ctx, tx)
+	item, err := scanItem(tx.QueryRow(ctx, `SELECT `+itemColumns+` FROM items WHERE barcode=$1 FOR UPDATE`,
+		tr.Barcode))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transfer{}, storerrros.ErrItemNoExist
+		}
+		log.Error().Err(err).Msg("get item failed")
+		return models.Transfer{}, err
+	}
+	if item.Status != models.ItemAvailable {
+		return models.Transfer{}, storerrros.ErrItemUnavailable
+	}
+	if item.CurrentBranch == tr.ToBranch {
+		return models.Transfer{}, storerrros.ErrItemAtBranch
+	}
+	tr.TID = uuid.New().String()
+	tr.FromBranch = item.CurrentBranch
+	tr.Status = models.TransferRequested
+	tr.RequestedAt = time.Now()
+	_, err = tx.Exec(ctx, `INSERT INTO transfers (tid, barcode, from_branch, to_branch, status, requested_by,
+		requested_at) VALUES ($1, $2, NULLIF($3, ''), $4, $5, NULLIF($6, ''), $7)`,
+		tr.TID, tr.Barcode, tr.FromBranch, tr.ToBranch, tr.Status, tr.RequestedBy, tr.RequestedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("save transfer failed")
+		return models.Transfer{}, mapItemErr(err)
+	}
+	return tr, tx.Commit(ctx)
+}
+
+func (dbs *DBStorage) GetTransfers(status string) ([]models.Transfer, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+transferColumns+` FROM transfers
+		WHERE $1 = '' OR status = $1 ORDER BY requested_at`, status)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get transfers from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var transfers []models.Transfer
+	for rows.Next() {
+		var tr models.Transfer
+		if tr, err = scanTransfer(rows); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		transfers = append(transfers, tr)
+	}
+	return transfers, rows.Err()
+}
+
+// ShipTransfer marks the copy as sent to the destination branch.
+func (dbs *DBStorage) ShipTransfer(tid string) (models.Transfer, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Transfer{}, err
+	}
+	defer rollback(ctx, tx)
+	tr, err := lockTransfer(ctx, tx, tid, models.TransferRequested)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	var status models.ItemStatus
+	if err = tx.QueryRow(ctx, "SELECT status FROM items WHERE barcode=$1 FOR UPDATE", tr.Barcode).
+		Scan(&status); err != nil {
+		log.Error().Err(err).Msg("get item failed")
+		return models.Transfer{}, err
+	}
+	// Copies for holds are already reserved, manual transfers need a copy that is still on the shelf.
+	if (tr.HID == "" && status != models.ItemAvailable) || (tr.HID != "" && status != models.ItemOnHold) {
+		return models.Transfer{}, storerrros.ErrItemUnavailable
+	}
+	now := time.Now()
+	tr.Status = models.TransferInTransit
+	tr.ShippedAt = &now
+	if _, err = tx.Exec(ctx, "UPDATE transfers SET status=$2, shipped_at=$3 WHERE tid=$1",
+		tid, tr.Status, now); err != nil {
+		log.Error().Err(err).Msg("update transfer failed")
+		return models.Transfer{}, err
+	}
+	if _, err = tx.Exec(ctx, "UPDATE items SET status=$2 WHERE barcode=$1",
+		tr.Barcode, models.ItemInTransit); err != nil {
+		log.Error().Err(err).Msg("update item status failed")
+		return models.Transfer{}, err
+	}
+	return tr, tx.Commit(ctx)
+}
+
+// ReceiveTransfer shelves the copy at the destination branch or puts it on the hold shelf.
+func (dbs *DBStorage) ReceiveTransfer(tid string) (models.Transfer, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Transfer{}, err
+	}
+	defer rollback(ctx, tx)
+	tr, err := lockTransfer(ctx, tx, tid, models.TransferInTransit)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	now := time.Now()
+	tr.Status = models.TransferReceived
+	tr.ReceivedAt = &now
+	if _, err = tx.Exec(ctx, "UPDATE transfers SET status=$2, received_at=$3 WHERE tid=$1",
+		tid, tr.Status, now); err != nil {
+		log.Error().Err(err).Msg("update transfer failed")
+		return models.Transfer{}, err
+	}
+	if _, err = tx.Exec(ctx, "UPDATE items SET current_branch=$2 WHERE barcode=$1",
+		tr.Barcode, tr.ToBranch); err != nil {
+		log.Error().Err(err).Msg("update item branch failed")
+		return models.Transfer{}, err
+	}
+	ready := false
+	if tr.HID != "" {
+		hold, err := scanHold(tx.QueryRow(ctx, `UPDATE holds SET status=$3, ready_at=$4 WHERE hid=$1 AND status=$2
+			RETURNING `+holdColumns, tr.HID, models.HoldInTransit, models.HoldReady, now))
+		switch {
+		case err == nil:
+			ready = true
+			err = addEvent(ctx, tx, models.EventHoldReady, hold.HID, hold)
+		case errors.Is(err, pgx.ErrNoRows):
+			err = nil
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("update hold failed")
+			return models.Transfer{}, err
+		}
+	}
+	if ready {
+		_, err = tx.Exec(ctx, "UPDATE items SET status=$2 WHERE barcode=$1", tr.Barcode, models.ItemOnHold)
+	} else {
+		err = shelveItem(ctx, tx, tr.Barcode)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("shelve item failed")
+		return models.Transfer{}, err
+	}
+	return tr, tx.Commit(ctx)
+}
+
+func lockTransfer(ctx context.Context, tx pgx.Tx, tid string, status models.TransferStatus) (models.Transfer, error) {
+	tr, err := scanTransfer(tx.QueryRow(ctx, `SELECT `+transferColumns+` FROM transfers WHERE tid=$1 FOR UPDATE`, tid))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transfer{}, storerrros.ErrTransferNoExist
+		}
+		return models.Transfer{}, err
+	}
+	if tr.Status != status {
+		return models.Transfer{}, storerrros.ErrTransferBadStatus
+	}
+	return tr, nil
+}
+
+// shelveItem hands a free copy to the oldest waiting hold for its book or puts it back on the shelf.
+func shelveItem(ctx context.Context, tx pgx.Tx, barcode string) error {
+	var bid, branch string
+	err := tx.QueryRow(ctx, "SELECT bid, COALESCE(current_branch, '') FROM items WHERE barcode=$1", barcode).
+		Scan(&bid, &branch)
+	if err != nil {
+		return err
+	}
+	var hold models.Hold
+	err = tx.QueryRow(ctx, `SELECT hid, pickup_branch FROM holds WHERE bid=$1 AND status=$2
+		ORDER BY created_at LIMIT 1 FOR UPDATE`, bid, models.HoldWaiting).Scan(&hold.HID, &hold.PickupBranch)
+	if errors.Is(err, pgx.ErrNoRows) {
+		_, err = tx.Exec(ctx, "UPDATE items SET status=$2 WHERE barcode=$1", barcode, models.ItemAvailable)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	return allocateItem(ctx, tx, hold, barcode, branch)
+}
+
+// allocateItem reserves the copy for the hold. Copies away from the pickup branch get a transfer request.
+func allocateItem(ctx context.Context, tx pgx.Tx, hold models.Hold, barcode, branch string) error {
+	if branch == "" || branch == hold.PickupBranch {
+		if _, err := tx.Exec(ctx, "UPDATE items SET status=$2, current_branch=$3 WHERE barcode=$1",
+			barcode, models.ItemOnHold, hold.PickupBranch); err != nil {
+			return err
+		}
+		ready, err := scanHold(tx.QueryRow(ctx, `UPDATE holds SET status=$2, barcode=$3, ready_at=$4 WHERE hid=$1
+			RETURNING `+holdColumns, hold.HID, models.HoldReady, barcode, time.Now()))
+		if err != nil {
+			return err
+		}
+		return addEvent(ctx, tx, models.EventHoldReady, ready.HID, ready)
+	}
+	if _, err := tx.Exec(ctx, "UPDATE items SET status=$2 WHERE barcode=$1", barcode, models.ItemOnHold); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE holds SET status=$2, barcode=$3 WHERE hid=$1",
+		hold.HID, models.HoldInTransit, barcode); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `INSERT INTO transfers (tid, barcode, from_branch, to_branch, hid, status, requested_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		uuid.New().String(), barcode, branch, hold.PickupBranch, hold.HID, models.TransferRequested, time.Now())
+	return err
+}
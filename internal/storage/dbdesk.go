@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/jackc/pgx/v5"
+)
+
+const alertColumns = `id, event_id, branch, kind, subject, data, created_at, COALESCE(acked_by, ''), acked_at`
+
+func scanAlert(row pgx.Row) (models.DeskAlert, error) {
+	var alert models.DeskAlert
+	err := row.Scan(&alert.ID, &alert.EventID, &alert.Branch, &alert.Kind, &alert.Subject, &alert.Data,
+		&alert.CreatedAt, &alert.AckedBy, &alert.AckedAt)
+	return alert, err
+}
+
+// SaveDeskAlert opens the alert and returns it with its ID. An alert made from the same event
+// again is rejected with ErrAlertExists.
+func (dbs *DBStorage) SaveDeskAlert(alert models.DeskAlert) (models.DeskAlert, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	saved, err := scanAlert(dbs.conn.QueryRow(ctx, `INSERT INTO desk_alerts (event_id, branch, kind, subject, data)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (event_id) DO NOTHING RETURNING `+alertColumns,
+		alert.EventID, alert.Branch, alert.Kind, alert.Subject, alert.Data))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DeskAlert{}, storerrros.ErrAlertExists
+		}
+		log.Error().Err(err).Msg("save desk alert failed")
+		return models.DeskAlert{}, err
+	}
+	return saved, nil
+}
+
+// GetDeskAlerts returns the open alerts of the branch, the oldest first.
+func (dbs *DBStorage) GetDeskAlerts(branch string) ([]models.DeskAlert, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+alertColumns+` FROM desk_alerts
+		WHERE branch=$1 AND acked_at IS NULL ORDER BY id`, branch)
+	if err != nil {
+		log.Error().Err(err).Msg("get desk alerts failed")
+		return nil, err
+	}
+	defer rows.Close()
+	alerts := []models.DeskAlert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// AckDeskAlert closes the alert of the branch. Acknowledging a closed alert again keeps the first acknowledgement.
+func (dbs *DBStorage) AckDeskAlert(id int64, branch, uid string) (models.DeskAlert, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	alert, err := scanAlert(dbs.conn.QueryRow(ctx, `UPDATE desk_alerts
+		SET acked_by=COALESCE(acked_by, NULLIF($3, '')), acked_at=COALESCE(acked_at, now())
+		WHERE id=$1 AND branch=$2 RETURNING `+alertColumns, id, branch, uid))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DeskAlert{}, storerrros.ErrAlertNoExist
+		}
+		log.Error().Err(err).Msg("ack desk alert failed")
+		return models.DeskAlert{}, err
+	}
+	return alert, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file" //nolint:revive //registers the file source
+)
+
+// Ping checks that the database answers.
+func (dbs *DBStorage) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	return dbs.conn.Ping(ctx)
+}
+
+// CheckMigrations compares the migration version of the database with the latest migration
+// in migrationsPath. A failed migration or a database behind the files is an error.
+func (dbs *DBStorage) CheckMigrations(migrationsPath string) error {
+	latest, err := LatestMigration(migrationsPath)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	var version uint
+	var dirty bool
+	if err = dbs.conn.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).
+		Scan(&version, &dirty); err != nil {
+		return fmt.Errorf("read migration version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("migration %d failed halfway", version)
+	}
+	if version < latest {
+		return fmt.Errorf("database is at migration %d, expected %d", version, latest)
+	}
+	return nil
+}
+
+// LatestMigration returns the version of the last migration in migrationsPath.
+func LatestMigration(migrationsPath string) (uint, error) {
+	src, err := source.Open("file://" + migrationsPath)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
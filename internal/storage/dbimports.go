@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/jackc/pgx/v5"
+)
+
+func (dbs *DBStorage) SaveImport(job models.ImportJob) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	_, err := dbs.conn.Exec(ctx, `INSERT INTO imports (id, format, status, created_by, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)`,
+		job.ID, job.Format, job.Status, job.CreatedBy, job.CreatedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("save import failed")
+		return err
+	}
+	return nil
+}
+
+// UpdateImport saves the progress of the job together with the row errors found since the last update.
+func (dbs *DBStorage) UpdateImport(job models.ImportJob, rowErrs []models.ImportError) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return err
+	}
+	defer rollback(ctx, tx)
+	tag, err := tx.Exec(ctx, `UPDATE imports SET status=$2, processed=$3, imported=$4, failed=$5, error=$6,
+		finished_at=$7 WHERE id=$1`,
+		job.ID, job.Status, job.Processed, job.Imported, job.Failed, job.Error, job.FinishedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("update import failed")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storerrros.ErrImportNoExist
+	}
+	if len(rowErrs) > 0 {
+		rows := make([][]any, 0, len(rowErrs))
+		for _, rowErr := range rowErrs {
+			rows = append(rows, []any{job.ID, rowErr.Row, rowErr.Error})
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"import_errors"}, []string{"import_id", "row", "error"},
+			pgx.CopyFromRows(rows))
+		if err != nil {
+			log.Error().Err(err).Msg("save import errors failed")
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+const importColumns = `id, format, status, processed, imported, failed, error,
+	COALESCE(created_by, ''), created_at, finished_at`
+
+func scanImport(row pgx.Row) (models.ImportJob, error) {
+	var job models.ImportJob
+	err := row.Scan(&job.ID, &job.Format, &job.Status, &job.Processed, &job.Imported, &job.Failed, &job.Error,
+		&job.CreatedBy, &job.CreatedAt, &job.FinishedAt)
+	return job, err
+}
+
+func (dbs *DBStorage) GetImport(id string) (models.ImportJob, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	job, err := scanImport(dbs.conn.QueryRow(ctx, `SELECT `+importColumns+` FROM imports WHERE id=$1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.ImportJob{}, storerrros.ErrImportNoExist
+		}
+		log.Error().Err(err).Msg("get import failed")
+		return models.ImportJob{}, err
+	}
+	return job, nil
+}
+
+func (dbs *DBStorage) GetImportErrors(id string) ([]models.ImportError, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, "SELECT row, error FROM import_errors WHERE import_id=$1 ORDER BY row", id)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get import errors from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var rowErrs []models.ImportError
+	for rows.Next() {
+		var rowErr models.ImportError
+		if err = rows.Scan(&rowErr.Row, &rowErr.Error); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		rowErrs = append(rowErrs, rowErr)
+	}
+	return rowErrs, rows.Err()
+}
+
+// UnfinishedImports returns the imports created before the given time which are still queued or running,
+// the oldest first.
+func (dbs *DBStorage) UnfinishedImports(before time.Time) ([]models.ImportJob, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+importColumns+` FROM imports
+		WHERE status IN ($1, $2) AND created_at < $3 ORDER BY created_at, id`,
+		models.ImportQueued, models.ImportRunning, before)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get unfinished imports from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []models.ImportJob
+	for rows.Next() {
+		job, err := scanImport(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/google/uuid"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type querier interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+const itemColumns = `barcode, bid, acquired_at, condition, location, status,
+	COALESCE(home_branch, ''), COALESCE(current_branch, '')`
+
+func scanItem(row pgx.Row) (models.Item, error) {
+	var item models.Item
+	err := row.Scan(&item.Barcode, &item.BID, &item.AcquiredAt, &item.Condition, &item.Location, &item.Status,
+		&item.HomeBranch, &item.CurrentBranch)
+	return item, err
+}
+
+// insertItem stores a copy of the book. An empty barcode is generated from item_barcode_seq.
+func insertItem(ctx context.Context, q querier, item models.Item) (string, error) {
+	if item.AcquiredAt.IsZero() {
+		item.AcquiredAt = time.Now()
+	}
+	if item.Condition == "" {
+		item.Condition = consts.DefaultCondition
+	}
+	if item.Status == "" {
+		item.Status = models.ItemAvailable
+	}
+	if item.CurrentBranch == "" {
+		item.CurrentBranch = item.HomeBranch
+	}
+	var barcode string
+	err := q.QueryRow(ctx, `INSERT INTO items (barcode, bid, acquired_at, condition, location, status,
+		home_branch, current_branch)
+		VALUES (COALESCE(NULLIF($1, ''), 'BK' || lpad(nextval('item_barcode_seq')::text, 8, '0')),
+		$2, $3, $4, $5, $6, NULLIF($7, ''), NULLIF($8, ''))
+		RETURNING barcode`,
+		item.Barcode, item.BID, item.AcquiredAt, item.Condition, item.Location, item.Status,
+		item.HomeBranch, item.CurrentBranch).Scan(&barcode)
+	if err != nil {
+		return "", mapItemErr(err)
+	}
+	item.Barcode = barcode
+	if err = addEvent(ctx, q, models.EventCopyAdded, item.BID, item); err != nil {
+		return "", err
+	}
+	return barcode, nil
+}
+
+func mapItemErr(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case pgerrcode.UniqueViolation:
+		return storerrros.ErrItemExists
+	case pgerrcode.ForeignKeyViolation:
+		return storerrros.ErrBranchNoExist
+	}
+	return err
+}
+
+func (dbs *DBStorage) SaveItem(item models.Item, origin models.AuditEntry) (string, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return "", err
+	}
+	defer rollback(ctx, tx)
+	tag, err := tx.Exec(ctx, "SELECT 1 FROM books WHERE bid=$1 AND deleted=false FOR UPDATE", item.BID)
+	if err != nil {
+		log.Error().Err(err).Msg("check book failed")
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		return "", storerrros.ErrBookNoExist
+	}
+	before, err := bookInTx(ctx, tx, item.BID)
+	if err != nil {
+		log.Error().Err(err).Msg("get book failed")
+		return "", err
+	}
+	barcode, err := insertItem(ctx, tx, item)
+	if err != nil {
+		log.Error().Err(err).Msg("save item failed")
+		return "", err
+	}
+	after, err := bookInTx(ctx, tx, item.BID)
+	if err != nil {
+		log.Error().Err(err).Msg("get book failed")
+		return "", err
+	}
+	if err = addAudit(ctx, tx, origin, models.AuditCount, models.AuditBook, item.BID, diff(before, after)); err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
+		return "", err
+	}
+	return barcode, tx.Commit(ctx)
+}
+
+func (dbs *DBStorage) GetItems(bid string) ([]models.Item, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+itemColumns+` FROM items WHERE bid=$1 ORDER BY barcode`, bid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get book items from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var items []models.Item
+	for rows.Next() {
+		var item models.Item
+		if item, err = scanItem(rows); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) < 1 {
+		return nil, storerrros.ErrItemNoExist
+	}
+	return items, nil
+}
+
+func (dbs *DBStorage) GetItem(barcode string) (models.Item, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	item, err := scanItem(dbs.conn.QueryRow(ctx, `SELECT `+itemColumns+` FROM items WHERE barcode=$1`, barcode))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Item{}, storerrros.ErrItemNoExist
+		}
+		log.Error().Err(err).Msg("failed to scan data from db")
+		return models.Item{}, err
+	}
+	return item, nil
+}
+
+// UpdateItem saves the copy and returns it as stored. A copy made available goes to the oldest
+// waiting hold for its book the way a returned copy does. The change is audited with the state
+// read under the lock of the copy row.
+func (dbs *DBStorage) UpdateItem(item models.Item, origin models.AuditEntry) (models.Item, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Item{}, err
+	}
+	defer rollback(ctx, tx)
+	before, err := scanItem(tx.QueryRow(ctx, `SELECT `+itemColumns+` FROM items WHERE barcode=$1 FOR UPDATE`,
+		item.Barcode))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Item{}, storerrros.ErrItemNoExist
+		}
+		log.Error().Err(err).Msg("get item failed")
+		return models.Item{}, err
+	}
+	_, err = tx.Exec(ctx, `UPDATE items SET condition=$2, location=$3, status=$4,
+		home_branch=NULLIF($5, ''), current_branch=NULLIF($6, '') WHERE barcode=$1`,
+		item.Barcode, item.Condition, item.Location, item.Status, item.HomeBranch, item.CurrentBranch)
+	if err != nil {
+		log.Error().Err(err).Msg("update item failed")
+		return models.Item{}, mapItemErr(err)
+	}
+	if item.Status == models.ItemAvailable {
+		if err = shelveItem(ctx, tx, item.Barcode); err != nil {
+			log.Error().Err(err).Msg("shelve item failed")
+			return models.Item{}, err
+		}
+	}
+	item, err = scanItem(tx.QueryRow(ctx, `SELECT `+itemColumns+` FROM items WHERE barcode=$1`, item.Barcode))
+	if err != nil {
+		log.Error().Err(err).Msg("get item failed")
+		return models.Item{}, err
+	}
+	err = addAudit(ctx, tx, origin, models.AuditUpdate, models.AuditItem, item.Barcode, diff(before, item))
+	if err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
+		return models.Item{}, err
+	}
+	return item, tx.Commit(ctx)
+}
+
+func (dbs *DBStorage) CheckoutItem(barcode, uid string, period time.Duration) (models.Loan, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Loan{}, err
+	}
+	defer rollback(ctx, tx)
+	loan := models.Loan{
+		LID:     uuid.New().String(),
+		Barcode: barcode,
+		UID:     uid,
+	}
+	var status models.ItemStatus
+	err = tx.QueryRow(ctx, "SELECT bid, status FROM items WHERE barcode=$1 FOR UPDATE", barcode).
+		Scan(&loan.BID, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Loan{}, storerrros.ErrItemNoExist
+		}
+		log.Error().Err(err).Msg("get item failed")
+		return models.Loan{}, err
+	}
+	switch status {
+	case models.ItemAvailable:
+	case models.ItemOnHold:
+		// A copy on the hold shelf is issued only to the member it waits for.
+		tag, err := tx.Exec(ctx, `UPDATE holds SET status=$3 WHERE barcode=$1 AND uid=$2 AND status=$4`,
+			barcode, uid, models.HoldFulfilled, models.HoldReady)
+		if err != nil {
+			log.Error().Err(err).Msg("fulfil hold failed")
+			return models.Loan{}, err
+		}
+		if tag.RowsAffected() == 0 {
+			return models.Loan{}, storerrros.ErrItemUnavailable
+		}
+	default:
+		return models.Loan{}, storerrros.ErrItemUnavailable
+	}
+	loan.IssuedAt = time.Now()
+	loan.DueAt = loan.IssuedAt.Add(period)
+	if _, err = tx.Exec(ctx, "UPDATE items SET status=$2 WHERE barcode=$1", barcode, models.ItemCheckedOut); err != nil {
+		log.Error().Err(err).Msg("update item status failed")
+		return models.Loan{}, err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO loans (lid, barcode, uid, issued_at, due_at) VALUES ($1, $2, $3, $4, $5)`,
+		loan.LID, loan.Barcode, loan.UID, loan.IssuedAt, loan.DueAt)
+	if err != nil {
+		log.Error().Err(err).Msg("save loan failed")
+		return models.Loan{}, err
+	}
+	if err = addEvent(ctx, tx, models.EventLoanCreated, loan.LID, loan); err != nil {
+		log.Error().Err(err).Msg("save event failed")
+		return models.Loan{}, err
+	}
+	return loan, tx.Commit(ctx)
+}
+
+// ReturnItem closes the active loan of the copy. A non empty branch becomes the current branch of the copy.
+func (dbs *DBStorage) ReturnItem(barcode, branch string) (models.Loan, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Loan{}, err
+	}
+	defer rollback(ctx, tx)
+	var loan models.Loan
+	err = tx.QueryRow(ctx, `SELECT l.lid, l.barcode, i.bid, l.uid, l.issued_at, l.due_at
+		FROM loans l JOIN items i ON i.barcode = l.barcode
+		WHERE l.barcode=$1 AND l.returned_at IS NULL FOR UPDATE`, barcode).
+		Scan(&loan.LID, &loan.Barcode, &loan.BID, &loan.UID, &loan.IssuedAt, &loan.DueAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Loan{}, storerrros.ErrItemNotOnLoan
+		}
+		log.Error().Err(err).Msg("get active loan failed")
+		return models.Loan{}, err
+	}
+	now := time.Now()
+	loan.ReturnedAt = &now
+	if _, err = tx.Exec(ctx, "UPDATE loans SET returned_at=$2 WHERE lid=$1", loan.LID, now); err != nil {
+		log.Error().Err(err).Msg("close loan failed")
+		return models.Loan{}, err
+	}
+	_, err = tx.Exec(ctx, `UPDATE items SET current_branch=COALESCE(NULLIF($2, ''), current_branch)
+		WHERE barcode=$1`, barcode, branch)
+	if err != nil {
+		log.Error().Err(err).Msg("update item branch failed")
+		return models.Loan{}, mapItemErr(err)
+	}
+	if err = shelveItem(ctx, tx, barcode); err != nil {
+		log.Error().Err(err).Msg("shelve item failed")
+		return models.Loan{}, err
+	}
+	if err = addEvent(ctx, tx, models.EventLoanReturned, loan.LID, loan); err != nil {
+		log.Error().Err(err).Msg("save event failed")
+		return models.Loan{}, err
+	}
+	return loan, tx.Commit(ctx)
+}
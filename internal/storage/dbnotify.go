@@ -0,0 +1,254 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/jackc/pgx/v5"
+)
+
+const noticeColumns = `id, uid, lid, kind, stage, channel, status, attempts, subject, error, created_at, sent_at`
+
+func scanNotice(row pgx.Row) (models.NoticeLog, error) {
+	var notice models.NoticeLog
+	err := row.Scan(&notice.ID, &notice.UID, &notice.LID, &notice.Kind, &notice.Stage, &notice.Channel,
+		&notice.Status, &notice.Attempts, &notice.Subject, &notice.Error, &notice.CreatedAt, &notice.SentAt)
+	return notice, err
+}
+
+// GetNotifyPrefs returns the notice preferences of the user, the defaults when the user set none.
+func (dbs *DBStorage) GetNotifyPrefs(uid string) (models.NotifyPrefs, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	prefs := models.NotifyPrefs{UID: uid}
+	err := dbs.conn.QueryRow(ctx, `SELECT COALESCE(p.channel, $2), COALESCE(p.locale, $3)
+		FROM users u LEFT JOIN notify_prefs p ON p.uid = u.uid WHERE u.uid=$1`,
+		uid, models.NotifyEmail, consts.DefaultLocale).Scan(&prefs.Channel, &prefs.Locale)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.NotifyPrefs{}, storerrros.ErrUserNotFound
+		}
+		log.Error().Err(err).Msg("get notify prefs failed")
+		return models.NotifyPrefs{}, err
+	}
+	return prefs, nil
+}
+
+func (dbs *DBStorage) SetNotifyPrefs(prefs models.NotifyPrefs) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	_, err := dbs.conn.Exec(ctx, `INSERT INTO notify_prefs (uid, channel, locale) VALUES ($1, $2, $3)
+		ON CONFLICT (uid) DO UPDATE SET channel=excluded.channel, locale=excluded.locale`,
+		prefs.UID, prefs.Channel, prefs.Locale)
+	if err != nil {
+		log.Error().Err(err).Msg("set notify prefs failed")
+		return err
+	}
+	return nil
+}
+
+// LoansDue returns the open loans due before the time with the borrowers and their preferences.
+func (dbs *DBStorage) LoansDue(before time.Time) ([]models.LoanDue, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT l.lid, l.barcode, i.bid, l.uid, l.issued_at, l.due_at,
+		u.email, b.lable, COALESCE(p.channel, $2), COALESCE(p.locale, $3)
+		FROM loans l JOIN items i ON i.barcode = l.barcode JOIN books b ON b.bid = i.bid
+		JOIN users u ON u.uid = l.uid LEFT JOIN notify_prefs p ON p.uid = l.uid
+		WHERE l.returned_at IS NULL AND l.due_at < $1 ORDER BY l.due_at`,
+		before, models.NotifyEmail, consts.DefaultLocale)
+	if err != nil {
+		log.Error().Err(err).Msg("get loans due failed")
+		return nil, err
+	}
+	defer rows.Close()
+	var loans []models.LoanDue
+	for rows.Next() {
+		var loan models.LoanDue
+		if err = rows.Scan(&loan.LID, &loan.Barcode, &loan.BID, &loan.UID, &loan.IssuedAt, &loan.DueAt,
+			&loan.Email, &loan.Lable, &loan.Prefs.Channel, &loan.Prefs.Locale); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		loan.Prefs.UID = loan.UID
+		loans = append(loans, loan)
+	}
+	return loans, rows.Err()
+}
+
+// ClaimNotice records the notice as pending before it is sent so that it is sent once.
+// A failed notice may be claimed again until it was tried consts.NoticeMaxAttempts times,
+// otherwise claiming a recorded notice fails with ErrNoticeExists.
+func (dbs *DBStorage) ClaimNotice(notice models.NoticeLog) (models.NoticeLog, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	claimed, err := scanNotice(dbs.conn.QueryRow(ctx, `INSERT INTO notice_log (uid, lid, kind, stage, channel, subject)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (lid, kind, stage) DO UPDATE SET status='pending', attempts=notice_log.attempts+1,
+			channel=excluded.channel, subject=excluded.subject, error=''
+		WHERE notice_log.status='failed' AND notice_log.attempts < $7
+		RETURNING `+noticeColumns,
+		notice.UID, notice.LID, notice.Kind, notice.Stage, notice.Channel, notice.Subject, consts.NoticeMaxAttempts))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.NoticeLog{}, storerrros.ErrNoticeExists
+		}
+		log.Error().Err(err).Msg("claim notice failed")
+		return models.NoticeLog{}, err
+	}
+	return claimed, nil
+}
+
+func (dbs *DBStorage) FinishNotice(id int64, status models.NoticeStatus, errText string) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	_, err := dbs.conn.Exec(ctx, `UPDATE notice_log SET status=$2, error=$3,
+		sent_at=CASE WHEN $2='sent' THEN now() END WHERE id=$1`, id, status, errText)
+	if err != nil {
+		log.Error().Err(err).Msg("finish notice failed")
+		return err
+	}
+	return nil
+}
+
+// GetNoticeLog returns the latest notices, only of the user when uid is not empty.
+func (dbs *DBStorage) GetNoticeLog(uid string, limit int) ([]models.NoticeLog, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+noticeColumns+` FROM notice_log
+		WHERE $1='' OR uid=$1 ORDER BY id DESC LIMIT $2`, uid, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("get notice log failed")
+		return nil, err
+	}
+	defer rows.Close()
+	notices := []models.NoticeLog{}
+	for rows.Next() {
+		notice, err := scanNotice(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		notices = append(notices, notice)
+	}
+	return notices, rows.Err()
+}
+
+// SaveNotification puts the notification into the inbox. A notification of the same source
+// as a saved one is dropped.
+func (dbs *DBStorage) SaveNotification(notification models.Notification) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	_, err := dbs.conn.Exec(ctx, `INSERT INTO notifications (uid, kind, title, body, source)
+		VALUES ($1, $2, $3, $4, NULLIF($5, '')) ON CONFLICT (source) DO NOTHING`,
+		notification.UID, notification.Kind, notification.Title, notification.Body, notification.Source)
+	if err != nil {
+		log.Error().Err(err).Msg("save notification failed")
+		return err
+	}
+	return nil
+}
+
+const notificationColumns = `id, uid, kind, title, body, created_at, read_at`
+
+func scanNotification(row pgx.Row) (models.Notification, error) {
+	var notification models.Notification
+	err := row.Scan(&notification.ID, &notification.UID, &notification.Kind, &notification.Title,
+		&notification.Body, &notification.CreatedAt, &notification.ReadAt)
+	return notification, err
+}
+
+// GetNotifications returns up to limit notifications of the user older than the one with the before ID,
+// the newest first. Zero before starts from the newest one.
+func (dbs *DBStorage) GetNotifications(uid string, before int64, limit int, unread bool) ([]models.Notification, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+notificationColumns+` FROM notifications
+		WHERE uid=$1 AND ($2=0 OR id < $2) AND (NOT $3 OR read_at IS NULL) ORDER BY id DESC LIMIT $4`,
+		uid, before, unread, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("get notifications failed")
+		return nil, err
+	}
+	defer rows.Close()
+	notifications := []models.Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (dbs *DBStorage) CountUnread(uid string) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	var unread int
+	err := dbs.conn.QueryRow(ctx, `SELECT count(*) FROM notifications WHERE uid=$1 AND read_at IS NULL`, uid).
+		Scan(&unread)
+	if err != nil {
+		log.Error().Err(err).Msg("count unread notifications failed")
+		return 0, err
+	}
+	return unread, nil
+}
+
+// ReadNotification marks the notification of the user as read. Reading it again keeps the first read time.
+func (dbs *DBStorage) ReadNotification(id int64, uid string) (models.Notification, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	notification, err := scanNotification(dbs.conn.QueryRow(ctx, `UPDATE notifications
+		SET read_at=COALESCE(read_at, now()) WHERE id=$1 AND uid=$2 RETURNING `+notificationColumns, id, uid))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Notification{}, storerrros.ErrNotificationNoExist
+		}
+		log.Error().Err(err).Msg("read notification failed")
+		return models.Notification{}, err
+	}
+	return notification, nil
+}
+
+// ReadAllNotifications marks every unread notification of the user as read and returns how many.
+func (dbs *DBStorage) ReadAllNotifications(uid string) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, `UPDATE notifications SET read_at=now() WHERE uid=$1 AND read_at IS NULL`, uid)
+	if err != nil {
+		log.Error().Err(err).Msg("read all notifications failed")
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// PurgeNotifications removes the notifications read before the time and returns how many.
+func (dbs *DBStorage) PurgeNotifications(readBefore time.Time) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, `DELETE FROM notifications WHERE read_at < $1`, readBefore)
+	if err != nil {
+		log.Error().Err(err).Msg("purge notifications failed")
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
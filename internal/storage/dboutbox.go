@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+)
+
+// addEvent writes a domain event to the outbox. It must run in the transaction of the change.
+func addEvent(ctx context.Context, q querier, typ models.EventType, subject string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = q.Exec(ctx, "INSERT INTO outbox (type, subject, data) VALUES ($1, $2, $3)", typ, subject, payload)
+	return err
+}
+
+// PendingEvents returns the oldest events not published yet.
+func (dbs *DBStorage) PendingEvents(limit int) ([]models.Event, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT id, type, subject, data, created_at FROM outbox
+		WHERE published_at IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("get pending events failed")
+		return nil, err
+	}
+	defer rows.Close()
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		if err = rows.Scan(&event.ID, &event.Type, &event.Subject, &event.Data, &event.CreatedAt); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (dbs *DBStorage) MarkPublished(ids []int64) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	if _, err := dbs.conn.Exec(ctx, "UPDATE outbox SET published_at=now() WHERE id = ANY($1)", ids); err != nil {
+		log.Error().Err(err).Msg("mark events published failed")
+		return err
+	}
+	return nil
+}
+
+// EventsAfter returns the published events following the given one, the oldest first.
+func (dbs *DBStorage) EventsAfter(after int64, limit int) ([]models.Event, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT id, type, subject, data, created_at FROM outbox
+		WHERE id > $1 AND published_at IS NOT NULL ORDER BY id LIMIT $2`, after, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("get events failed")
+		return nil, err
+	}
+	defer rows.Close()
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		if err = rows.Scan(&event.ID, &event.Type, &event.Subject, &event.Data, &event.CreatedAt); err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
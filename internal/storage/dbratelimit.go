@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/ratelimit"
+)
+
+// TakeToken takes a token from the bucket of key with the bucket row locked, so that the
+// replicas sharing the database share the limit too.
+func (dbs *DBStorage) TakeToken(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Bucket, bool, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return ratelimit.Bucket{}, false, err
+	}
+	defer rollback(ctx, tx)
+	// A new bucket is created full first, so that there is always a row to lock.
+	_, err = tx.Exec(ctx, `INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING`, key, limit.Requests, now)
+	if err != nil {
+		log.Error().Err(err).Msg("create rate bucket failed")
+		return ratelimit.Bucket{}, false, err
+	}
+	var bucket ratelimit.Bucket
+	err = tx.QueryRow(ctx, "SELECT tokens, updated_at FROM rate_limits WHERE key=$1 FOR UPDATE", key).
+		Scan(&bucket.Tokens, &bucket.Updated)
+	if err != nil {
+		log.Error().Err(err).Msg("get rate bucket failed")
+		return ratelimit.Bucket{}, false, err
+	}
+	bucket, allowed := ratelimit.Take(bucket, limit, now)
+	_, err = tx.Exec(ctx, "UPDATE rate_limits SET tokens=$2, updated_at=$3 WHERE key=$1",
+		key, bucket.Tokens, bucket.Updated)
+	if err != nil {
+		log.Error().Err(err).Msg("update rate bucket failed")
+		return ratelimit.Bucket{}, false, err
+	}
+	return bucket, allowed, tx.Commit(ctx)
+}
+
+func (dbs *DBStorage) PurgeRateBuckets(before time.Time) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, "DELETE FROM rate_limits WHERE updated_at < $1", before)
+	if err != nil {
+		log.Error().Err(err).Msg("purge rate buckets failed")
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
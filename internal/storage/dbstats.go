@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LibraryStats counts the titles and copies in the catalog and the open loans. Lost copies
+// and soft deleted books are left out.
+func (dbs *DBStorage) LibraryStats() (models.LibraryStats, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	var stats models.LibraryStats
+	err := dbs.conn.QueryRow(ctx, `SELECT
+		(SELECT count(*) FROM books WHERE NOT deleted),
+		(SELECT count(*) FROM items i JOIN books b ON b.bid = i.bid WHERE NOT b.deleted AND i.status <> 'lost'),
+		(SELECT count(*) FROM items i JOIN books b ON b.bid = i.bid WHERE NOT b.deleted AND i.status = 'available'),
+		(SELECT count(*) FROM loans WHERE returned_at IS NULL),
+		(SELECT count(*) FROM loans WHERE returned_at IS NULL AND due_at < now())`).
+		Scan(&stats.Titles, &stats.Copies, &stats.AvailableCopies, &stats.ActiveLoans, &stats.OverdueLoans)
+	if err != nil {
+		log.Error().Err(err).Msg("get library stats failed")
+		return models.LibraryStats{}, err
+	}
+	return stats, nil
+}
+
+// PoolStat returns the statistics of the connection pool.
+func (dbs *DBStorage) PoolStat() *pgxpool.Stat {
+	return dbs.conn.Stat()
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
-	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
 	"github.com/Dorrrke/g3-bookly/internal/domain/models"
 	"github.com/Dorrrke/g3-bookly/internal/logger"
 	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
@@ -14,24 +14,33 @@ import (
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type DBStorage struct {
-	conn *pgx.Conn
+	conn *pgxpool.Pool
+	// timeout limits every call of the storage.
+	timeout time.Duration
 }
 
-func NewDB(ctx context.Context, addr string) (*DBStorage, error) {
-	conn, err := pgx.Connect(ctx, addr)
+func NewDB(ctx context.Context, addr string, timeout time.Duration) (*DBStorage, error) {
+	conn, err := pgxpool.New(ctx, addr)
 	if err != nil {
 		return nil, err
 	}
+	if err = conn.Ping(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	return &DBStorage{
-		conn: conn,
+		conn:    conn,
+		timeout: timeout,
 	}, nil
 }
 
-func (dbs *DBStorage) SaveUser(user models.User) (string, error) {
+// SaveUser registers the user, who is the actor of the registration in the audit.
+func (dbs *DBStorage) SaveUser(user models.User, origin models.AuditEntry) (string, error) {
 	log := logger.Get()
 	uuid := uuid.New().String()
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Pass), bcrypt.DefaultCost)
@@ -39,13 +48,21 @@ func (dbs *DBStorage) SaveUser(user models.User) (string, error) {
 		log.Error().Err(err).Msg("save user failed")
 		return "", err
 	}
-	log.Debug().Str("hash", string(hash)).Send()
 	user.Pass = string(hash)
 	user.UID = uuid
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	if user.Role == "" {
+		user.Role = models.RoleMember
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
-	_, err = dbs.conn.Exec(ctx, "INSERT INTO users (uid, email, pass, age) VALUES ($1, $2, $3, $4)",
-		user.UID, user.Email, user.Pass, user.Age)
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return "", err
+	}
+	defer rollback(ctx, tx)
+	_, err = tx.Exec(ctx, "INSERT INTO users (uid, email, pass, age, role) VALUES ($1, $2, $3, $4, $5)",
+		user.UID, user.Email, user.Pass, user.Age, user.Role)
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -55,12 +72,22 @@ func (dbs *DBStorage) SaveUser(user models.User) (string, error) {
 		}
 		return "", err
 	}
-	return user.UID, nil
+	err = addEvent(ctx, tx, models.EventUserRegistered, user.UID, map[string]string{"uid": user.UID, "role": user.Role})
+	if err != nil {
+		log.Error().Err(err).Msg("save event failed")
+		return "", err
+	}
+	origin.Actor = user.UID
+	if err = addAudit(ctx, tx, origin, models.AuditCreate, models.AuditUser, user.UID, diff(nil, user)); err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
+		return "", err
+	}
+	return user.UID, tx.Commit(ctx)
 }
 
 func (dbs *DBStorage) ValidUser(user models.User) (string, error) {
 	log := logger.Get()
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
 	row := dbs.conn.QueryRow(ctx, "SELECT uid, email, pass FROM users WHERE email = $1", user.Email)
 	var usr models.User
@@ -78,181 +105,522 @@ func (dbs *DBStorage) ValidUser(user models.User) (string, error) {
 
 func (dbs *DBStorage) GetUser(uid string) (models.User, error) {
 	log := logger.Get()
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
-	row := dbs.conn.QueryRow(ctx, "SELECT uid, email, pass, age FROM users WHERE uid = $1", uid)
+	row := dbs.conn.QueryRow(ctx, "SELECT uid, email, pass, age, role FROM users WHERE uid = $1", uid)
 	var usr models.User
-	if err := row.Scan(&usr.UID, &usr.Email, &usr.Pass, &usr.Age); err != nil {
+	if err := row.Scan(&usr.UID, &usr.Email, &usr.Pass, &usr.Age, &usr.Role); err != nil {
 		log.Error().Err(err).Msg("failed scan db data")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.User{}, storerrros.ErrUserNotFound
+		}
 		return models.User{}, err
 	}
 	log.Debug().Any("db user", usr).Msg("user form data base")
 	return usr, nil
 }
 
-func (dbs *DBStorage) SaveBook(book models.Book) error {
+func (dbs *DBStorage) SetUserRole(uid, role string, origin models.AuditEntry) error {
 	log := logger.Get()
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
-	var bid string
-	var count int
-	log.Debug().Msgf("search book %s %s", book.Author, book.Lable)
-	err := dbs.conn.QueryRow(ctx, `SELECT bid, count FROM books 
-		WHERE lable=$1 AND author=$2`, book.Lable, book.Author).Scan(&bid, &count)
+	tx, err := dbs.conn.Begin(ctx)
 	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return err
+	}
+	defer rollback(ctx, tx)
+	var before string
+	if err = tx.QueryRow(ctx, "SELECT role FROM users WHERE uid=$1 FOR UPDATE", uid).Scan(&before); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			bid = uuid.New().String()
-			_, err = dbs.conn.Exec(ctx,
-				`INSERT INTO books (bid, lable, author, "desc", age, count) 
-				VALUES ($1, $2, $3, $4, $5, $6)`,
-				bid, book.Lable, book.Author, book.Desc, book.Age, book.Count)
-			if err != nil {
-				log.Error().Err(err).Msg("save book failed")
-				return nil
-			}
-			return nil
+			return storerrros.ErrUserNotFound
 		}
-		log.Error().Err(err).Msg("get book count failed")
+		log.Error().Err(err).Msg("get user role failed")
+		return err
+	}
+	if _, err = tx.Exec(ctx, "UPDATE users SET role=$2 WHERE uid=$1", uid, role); err != nil {
+		log.Error().Err(err).Msg("update user role failed")
 		return err
 	}
-	log.Debug().Int("book count", count).Msg("book count")
-	_, err = dbs.conn.Exec(ctx, "UPDATE books SET count=count + 1 WHERE bid=$1", bid)
+	if err = addEvent(ctx, tx, models.EventUserRoleChanged, uid, map[string]string{"uid": uid, "role": role}); err != nil {
+		log.Error().Err(err).Msg("save event failed")
+		return err
+	}
+	err = addAudit(ctx, tx, origin, models.AuditUpdate, models.AuditUser, uid,
+		[]models.FieldChange{{Field: "role", Before: before, After: role}})
 	if err != nil {
-		log.Error().Err(err).Msg("uodate book count failed")
+		log.Error().Err(err).Msg("save audit entry failed")
 		return err
 	}
-	return nil
+	return tx.Commit(ctx)
 }
 
-func (dbs *DBStorage) SaveBooks(books []models.Book) error {
+func (dbs *DBStorage) SaveBook(book models.Book, origin models.AuditEntry) error {
 	log := logger.Get()
-	log.Debug().Any("books", books).Msg("check books")
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
 	tx, err := dbs.conn.Begin(ctx)
 	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
 		return err
 	}
-	defer func() {
-		if err = tx.Rollback(ctx); err != nil {
-			log.Error().Err(err).Send()
-		}
-	}()
-	_, err = tx.Prepare(ctx, "saveBook", `SELECT bid, count FROM books 
-		WHERE lable=$1 AND author=$2`)
-	if err != nil {
-		log.Error().Err(err).Msg("prepare save book req failed")
-		return err
-	}
-	_, err = tx.Prepare(ctx, "insertBook", `INSERT INTO books (bid, lable, author, "desc", age, count) 
-				VALUES ($1, $2, $3, $4, $5, $6)`)
-	if err != nil {
-		log.Error().Err(err).Msg("prepare insert book req failed")
+	defer rollback(ctx, tx)
+	if err = saveBookCopy(ctx, tx, book, origin); err != nil {
 		return err
 	}
-	_, err = tx.Prepare(ctx, "updateBook", `UPDATE books SET count=count + 1 WHERE bid=$1`)
+	return tx.Commit(ctx)
+}
+
+func (dbs *DBStorage) SaveBooks(books []models.Book, origin models.AuditEntry) error {
+	log := logger.Get()
+	log.Debug().Any("books", books).Msg("check books")
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
 	if err != nil {
-		log.Error().Err(err).Msg("prepare update book req failed")
 		return err
 	}
+	defer rollback(ctx, tx)
 	for _, book := range books {
-		var bid string
-		var count int
-		log.Debug().Msgf("search book %s %s", book.Author, book.Lable)
-		err = tx.QueryRow(ctx, "saveBook", book.Lable, book.Author).Scan(&bid, &count)
-		if err != nil {
-			if errors.Is(err, pgx.ErrNoRows) {
-				bid = uuid.New().String()
-				count = 1
-				_, err = tx.Exec(ctx, `insertBook`,
-					bid, book.Lable, book.Author, book.Desc, book.Age, count)
-				if err != nil {
-					log.Error().Err(err).Msg("save book failed")
-					return nil
-				}
-				continue
-			}
-			log.Error().Err(err).Msg("get book count failed")
+		if err = saveBookCopy(ctx, tx, book, origin); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// mapBookErr tells a book the database rejects, by its values or by a constraint,
+// from a failure of the database itself.
+func mapBookErr(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) &&
+		(pgerrcode.IsDataException(pgErr.Code) || pgerrcode.IsIntegrityConstraintViolation(pgErr.Code)) {
+		return fmt.Errorf("%w: %s", storerrros.ErrInvalidBook, pgErr.Message)
+	}
+	return err
+}
+
+// saveBookCopy adds a copy of the book, creating the book unless one with the same title and author exists.
+// The creation or the copy count change is audited with the state read under the lock of the book row.
+func saveBookCopy(ctx context.Context, q querier, book models.Book, origin models.AuditEntry) error {
+	log := logger.Get()
+	var bid string
+	log.Debug().Msgf("search book %s %s", book.Author, book.Lable)
+	err := q.QueryRow(ctx, findBookQuery+" FOR UPDATE", book.Lable, book.Author).Scan(&bid)
+	var before any
+	switch {
+	case err == nil:
+		if before, err = bookInTx(ctx, q, bid); err != nil {
+			log.Error().Err(err).Msg("get book failed")
 			return err
 		}
-		log.Debug().Int("book count", count).Msg("book count")
-		_, err = tx.Exec(ctx, "updateBook", bid)
+	case errors.Is(err, pgx.ErrNoRows):
+		bid = uuid.New().String()
+		_, err = q.Exec(ctx, `INSERT INTO books (bid, lable, author, "desc", age, genre)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			bid, book.Lable, book.Author, book.Desc, book.Age, book.Genre)
 		if err != nil {
-			log.Error().Err(err).Msg("uodate book count failed")
+			log.Error().Err(err).Msg("save book failed")
+			return mapBookErr(err)
+		}
+		if err = addBookEvent(ctx, q, bid, book); err != nil {
+			log.Error().Err(err).Msg("save event failed")
 			return err
 		}
+	default:
+		log.Error().Err(err).Msg("search book failed")
+		return err
 	}
-	return tx.Commit(ctx)
+	if _, err = insertItem(ctx, q, models.Item{BID: bid}); err != nil {
+		log.Error().Err(err).Msg("save book copy failed")
+		return err
+	}
+	after, err := bookInTx(ctx, q, bid)
+	if err != nil {
+		log.Error().Err(err).Msg("get book failed")
+		return err
+	}
+	action := models.AuditCount
+	if before == nil {
+		action = models.AuditCreate
+	}
+	if err = addAudit(ctx, q, origin, action, models.AuditBook, bid, diff(before, after)); err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
+		return err
+	}
+	return nil
+}
+
+// bookInTx returns the book with its copies as the transaction sees it, deleted or not.
+func bookInTx(ctx context.Context, q querier, bid string) (models.Book, error) {
+	return scanBook(q.QueryRow(ctx, booksQuery+` WHERE b.bid = $1 GROUP BY b.bid`, bid))
+}
+
+func addBookEvent(ctx context.Context, q querier, bid string, book models.Book) error {
+	book.BID, book.Count, book.Available = bid, 0, 0
+	return addEvent(ctx, q, models.EventBookAdded, bid, book)
+}
+
+// bookColumns select a book together with the number of copies derived from item statuses
+// of the items joined as i.
+const bookColumns = `b.bid, b.lable, b.author, b."desc", b.age, b.genre, b.cover, b.cover_type,
+	COUNT(i.barcode) FILTER (WHERE i.status <> 'lost'),
+	COUNT(i.barcode) FILTER (WHERE i.status = 'available')`
+
+const booksQuery = `SELECT ` + bookColumns + ` FROM books b LEFT JOIN items i ON i.bid = b.bid`
+
+// findBookQuery is the deduplication rule of saved books: a book with the same title and author gets one more copy.
+// Books in the trash are left out, a new book is created beside them.
+const findBookQuery = `SELECT bid FROM books WHERE lable=$1 AND author=$2 AND deleted=false`
+
+func scanBook(row pgx.Row) (models.Book, error) {
+	var book models.Book
+	err := row.Scan(&book.BID, &book.Lable, &book.Author, &book.Desc, &book.Age, &book.Genre,
+		&book.Cover, &book.CoverType, &book.Count, &book.Available)
+	return book, err
 }
 
 func (dbs *DBStorage) GetBooks() ([]models.Book, error) {
 	log := logger.Get()
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
-	rows, err := dbs.conn.Query(ctx, `SELECT bid, lable, author, "desc", age, count FROM books WHERE deleted=false`)
+	rows, err := dbs.conn.Query(ctx, booksQuery+` WHERE b.deleted=false GROUP BY b.bid`)
 	if err != nil {
 		log.Error().Err(err).Msg("failed get all books from db")
 		return nil, err
 	}
+	defer rows.Close()
 	var books []models.Book
 	for rows.Next() {
-		var book models.Book
-		if err = rows.Scan(&book.BID, &book.Lable, &book.Author, &book.Desc, &book.Age, &book.Count); err != nil {
+		book, err := scanBook(rows)
+		if err != nil {
 			log.Error().Err(err).Msg("failed to scan data from db")
 			return nil, err
 		}
 		books = append(books, book)
 	}
-	return books, nil
+	return books, rows.Err()
+}
+
+// GetBooksPage returns up to limit books ordered by bid, starting after the given bid.
+// Every page is a separate query, so long exports do not hold a connection between pages.
+func (dbs *DBStorage) GetBooksPage(after string, limit int) ([]models.Book, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, booksQuery+` WHERE b.deleted=false AND b.bid > $1
+		GROUP BY b.bid ORDER BY b.bid LIMIT $2`, after, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get books page from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	return books, rows.Err()
+}
+
+// FindBook returns the book a new copy with the same title and author would be merged into.
+func (dbs *DBStorage) FindBook(lable, author string) (models.Book, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	book, err := scanBook(dbs.conn.QueryRow(ctx, booksQuery+` WHERE b.lable=$1 AND b.author=$2
+		AND b.deleted=false GROUP BY b.bid LIMIT 1`, lable, author))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Book{}, storerrros.ErrBookNoExist
+		}
+		log.Error().Err(err).Msg("find book failed")
+		return models.Book{}, err
+	}
+	return book, nil
 }
 
 func (dbs *DBStorage) GetBook(bid string) (models.Book, error) {
 	log := logger.Get()
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
-	row := dbs.conn.QueryRow(ctx,
-		`SELECT bid, lable, author, "desc", age, count FROM books WHERE bid = $1 AND deleted=false`, bid)
-	var book models.Book
-	if err := row.Scan(&book.BID, &book.Lable, &book.Author, &book.Desc, &book.Age, &book.Count); err != nil {
+	row := dbs.conn.QueryRow(ctx, booksQuery+` WHERE b.bid = $1 AND b.deleted=false GROUP BY b.bid`, bid)
+	book, err := scanBook(row)
+	if err != nil {
 		log.Error().Err(err).Msg("failed to scan data from db")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Book{}, storerrros.ErrBookNoExist
+		}
 		return models.Book{}, err
 	}
 	return book, nil
 }
 
-func (dbs *DBStorage) SetDeleteStatus(bid string) error {
+// booksAsOfQuery selects the books and their copies as they were at $1 from the current rows
+// valid since then and the history rows valid back then.
+const booksAsOfQuery = `WITH b AS (
+	SELECT bid, lable, author, "desc", age, genre, cover, cover_type, deleted FROM books
+		WHERE sys_period @> $1::timestamptz
+	UNION ALL
+	SELECT bid, lable, author, "desc", age, genre, cover, cover_type, deleted FROM books_history
+		WHERE sys_period @> $1::timestamptz
+), i AS (
+	SELECT bid, barcode, status FROM items WHERE sys_period @> $1::timestamptz
+	UNION ALL
+	SELECT bid, barcode, status FROM items_history WHERE sys_period @> $1::timestamptz
+)
+SELECT ` + bookColumns + ` FROM b LEFT JOIN i ON i.bid = b.bid WHERE b.deleted=false`
+
+const booksAsOfGroup = ` GROUP BY b.bid, b.lable, b.author, b."desc", b.age, b.genre, b.cover, b.cover_type`
+
+// GetBooksAt returns the catalog as it was at the given time.
+func (dbs *DBStorage) GetBooksAt(at time.Time) ([]models.Book, error) {
 	log := logger.Get()
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
-	_, err := dbs.conn.Exec(ctx, "UPDATE books SET deleted=true WHERE bid=$1", bid)
+	rows, err := dbs.conn.Query(ctx, booksAsOfQuery+booksAsOfGroup+` ORDER BY b.bid`, at)
 	if err != nil {
-		log.Error().Msg("set deleted status failed")
+		log.Error().Err(err).Msg("failed get books as of time from db")
+		return nil, err
+	}
+	defer rows.Close()
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		books = append(books, book)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(books) < 1 {
+		return nil, storerrros.ErrEmptyBooksList
+	}
+	return books, nil
+}
+
+// GetBookAt returns the book as it was at the given time.
+func (dbs *DBStorage) GetBookAt(bid string, at time.Time) (models.Book, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	book, err := scanBook(dbs.conn.QueryRow(ctx, booksAsOfQuery+` AND b.bid = $2`+booksAsOfGroup, at, bid))
+	if err != nil {
+		log.Error().Err(err).Msg("failed to scan data from db")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Book{}, storerrros.ErrBookNoExist
+		}
+		return models.Book{}, err
+	}
+	return book, nil
+}
+
+// SetCover points the book to a new cover version.
+func (dbs *DBStorage) SetCover(bid, version, contentType string, origin models.AuditEntry) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
 		return err
 	}
-	return nil
+	defer rollback(ctx, tx)
+	var before string
+	err = tx.QueryRow(ctx, "SELECT cover FROM books WHERE bid=$1 AND deleted=false FOR UPDATE", bid).Scan(&before)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storerrros.ErrBookNoExist
+		}
+		log.Error().Err(err).Msg("get cover failed")
+		return err
+	}
+	_, err = tx.Exec(ctx, "UPDATE books SET cover=$2, cover_type=$3 WHERE bid=$1", bid, version, contentType)
+	if err != nil {
+		log.Error().Err(err).Msg("set cover failed")
+		return err
+	}
+	err = addAudit(ctx, tx, origin, models.AuditUpdate, models.AuditBook, bid,
+		diff(models.Book{Cover: before}, models.Book{Cover: version}))
+	if err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
-func (dbs *DBStorage) DeleteBooks() error {
+// SetDeleteStatus moves the book to the trash on behalf of the actor of the origin.
+func (dbs *DBStorage) SetDeleteStatus(bid string, origin models.AuditEntry) error {
 	log := logger.Get()
-	ctx, cancel := context.WithTimeout(context.Background(), consts.DBCtxTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
 	defer cancel()
 	tx, err := dbs.conn.Begin(ctx)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to start tx")
 		return err
 	}
-	defer func() {
-		if err = tx.Rollback(ctx); err != nil {
-			log.Error().Err(err).Send()
+	defer rollback(ctx, tx)
+	tag, err := tx.Exec(ctx, `UPDATE books SET deleted=true, deleted_at=now(), deleted_by=$2
+		WHERE bid=$1 AND deleted=false`, bid, origin.Actor)
+	if err != nil {
+		log.Error().Msg("set deleted status failed")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storerrros.ErrBookNoExist
+	}
+	err = addEvent(ctx, tx, models.EventBookDeleted, bid, map[string]string{"bid": bid, "deleted_by": origin.Actor})
+	if err != nil {
+		log.Error().Err(err).Msg("save event failed")
+		return err
+	}
+	err = addAudit(ctx, tx, origin, models.AuditDelete, models.AuditBook, bid,
+		[]models.FieldChange{{Field: "deleted", Before: false, After: true}})
+	if err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetTrash returns the deleted books not purged yet, the most recently deleted first.
+func (dbs *DBStorage) GetTrash() ([]models.TrashedBook, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+bookColumns+`, coalesce(b.deleted_by, ''), b.deleted_at
+		FROM books b LEFT JOIN items i ON i.bid = b.bid WHERE b.deleted=true
+		GROUP BY b.bid ORDER BY b.deleted_at DESC`)
+	if err != nil {
+		log.Error().Err(err).Msg("failed get trash from db")
+		return nil, err
+	}
+	defer rows.Close()
+	trash := []models.TrashedBook{}
+	for rows.Next() {
+		var book models.TrashedBook
+		err = rows.Scan(&book.BID, &book.Lable, &book.Author, &book.Desc, &book.Age, &book.Genre,
+			&book.Cover, &book.CoverType, &book.Count, &book.Available, &book.DeletedBy, &book.DeletedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
 		}
-	}()
-	if _, err = tx.Exec(ctx, "DELETE FROM books WHERE deleted=true"); err != nil {
-		log.Error().Err(err).Msg("delete books failed")
+		trash = append(trash, book)
+	}
+	return trash, rows.Err()
+}
+
+// RestoreBook takes the book out of the trash.
+func (dbs *DBStorage) RestoreBook(bid string, origin models.AuditEntry) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return err
+	}
+	defer rollback(ctx, tx)
+	tag, err := tx.Exec(ctx, `UPDATE books SET deleted=false, deleted_at=NULL, deleted_by=NULL
+		WHERE bid=$1 AND deleted=true`, bid)
+	if err != nil {
+		log.Error().Err(err).Msg("restore book failed")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storerrros.ErrBookNotTrashed
+	}
+	if err = addEvent(ctx, tx, models.EventBookRestored, bid, map[string]string{"bid": bid}); err != nil {
+		log.Error().Err(err).Msg("save event failed")
+		return err
+	}
+	err = addAudit(ctx, tx, origin, models.AuditRestore, models.AuditBook, bid,
+		[]models.FieldChange{{Field: "deleted", Before: true, After: false}})
+	if err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
 		return err
 	}
 	return tx.Commit(ctx)
 }
 
+// PurgeBook removes a book from the trash for good right away, regardless of the retention period.
+func (dbs *DBStorage) PurgeBook(bid string, origin models.AuditEntry) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return err
+	}
+	defer rollback(ctx, tx)
+	var deleted, onLoan bool
+	err = tx.QueryRow(ctx, `SELECT b.deleted, EXISTS (SELECT 1 FROM items i JOIN loans l ON l.barcode = i.barcode
+		WHERE i.bid = b.bid AND l.returned_at IS NULL) FROM books b WHERE b.bid=$1 FOR UPDATE`, bid).
+		Scan(&deleted, &onLoan)
+	if errors.Is(err, pgx.ErrNoRows) || (err == nil && !deleted) {
+		return storerrros.ErrBookNotTrashed
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("get trashed book failed")
+		return err
+	}
+	if onLoan {
+		return storerrros.ErrBookOnLoan
+	}
+	if _, err = tx.Exec(ctx, "DELETE FROM books WHERE bid=$1", bid); err != nil {
+		log.Error().Err(err).Msg("purge book failed")
+		return err
+	}
+	if err = addEvent(ctx, tx, models.EventBookPurged, bid, map[string]string{"bid": bid}); err != nil {
+		log.Error().Err(err).Msg("save event failed")
+		return err
+	}
+	if err = addAudit(ctx, tx, origin, models.AuditPurge, models.AuditBook, bid, nil); err != nil {
+		log.Error().Err(err).Msg("save audit entry failed")
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// purgeBooksQuery removes at most $2 books deleted before $1 and writes an event per removed book.
+// Books with a copy still on loan are kept until it is returned, removing them would drop the loan by cascade.
+const purgeBooksQuery = `WITH purged AS (DELETE FROM books WHERE bid IN (
+	SELECT b.bid FROM books b WHERE b.deleted=true AND b.deleted_at < $1
+	AND NOT EXISTS (SELECT 1 FROM items i JOIN loans l ON l.barcode = i.barcode
+		WHERE i.bid = b.bid AND l.returned_at IS NULL)
+	LIMIT $2) RETURNING bid)
+INSERT INTO outbox (type, subject, data)
+	SELECT '` + string(models.EventBookPurged) + `', bid, jsonb_build_object('bid', bid) FROM purged`
+
+// PurgeBooks removes for good at most limit books soft deleted before the given time
+// together with their copies and holds and returns how many were removed.
+func (dbs *DBStorage) PurgeBooks(before time.Time, limit int) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, purgeBooksQuery, before, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("purge books failed")
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+func rollback(ctx context.Context, tx pgx.Tx) {
+	log := logger.Get()
+	if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+		log.Error().Err(err).Msg("tx rollback failed")
+	}
+}
+
 func Migrations(dbDsn string, migrationsPath string) error {
 	log := logger.Get()
 	migratePath := fmt.Sprintf("file://%s", migrationsPath)
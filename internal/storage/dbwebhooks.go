@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/jackc/pgx/v5"
+)
+
+const deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+	last_error, created_at, delivered_at`
+
+func scanDelivery(row pgx.Row, dest ...any) (models.Delivery, error) {
+	var d models.Delivery
+	err := row.Scan(append([]any{&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &d.Payload, &d.Status,
+		&d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.DeliveredAt}, dest...)...)
+	return d, err
+}
+
+func (dbs *DBStorage) SaveWebhook(hook models.Webhook) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	_, err := dbs.conn.Exec(ctx, `INSERT INTO webhooks (id, url, secret, events, created_by, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6)`,
+		hook.ID, hook.URL, hook.Secret, hook.Events, hook.CreatedBy, hook.CreatedAt)
+	if err != nil {
+		log.Error().Err(err).Msg("save webhook failed")
+		return err
+	}
+	return nil
+}
+
+func (dbs *DBStorage) GetWebhooks() ([]models.Webhook, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT id, url, secret, events, COALESCE(created_by, ''), created_at
+		FROM webhooks ORDER BY created_at`)
+	if err != nil {
+		log.Error().Err(err).Msg("get webhooks failed")
+		return nil, err
+	}
+	defer rows.Close()
+	hooks := []models.Webhook{}
+	for rows.Next() {
+		var hook models.Webhook
+		err = rows.Scan(&hook.ID, &hook.URL, &hook.Secret, &hook.Events, &hook.CreatedBy, &hook.CreatedAt)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (dbs *DBStorage) GetWebhook(id string) (models.Webhook, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	var hook models.Webhook
+	err := dbs.conn.QueryRow(ctx, `SELECT id, url, secret, events, COALESCE(created_by, ''), created_at
+		FROM webhooks WHERE id=$1`, id).
+		Scan(&hook.ID, &hook.URL, &hook.Secret, &hook.Events, &hook.CreatedBy, &hook.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Webhook{}, storerrros.ErrWebhookNoExist
+		}
+		log.Error().Err(err).Msg("get webhook failed")
+		return models.Webhook{}, err
+	}
+	return hook, nil
+}
+
+// DeleteWebhook removes the webhook together with its delivery history.
+func (dbs *DBStorage) DeleteWebhook(id string) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, "DELETE FROM webhooks WHERE id=$1", id)
+	if err != nil {
+		log.Error().Err(err).Msg("delete webhook failed")
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return storerrros.ErrWebhookNoExist
+	}
+	return nil
+}
+
+// EnqueueDeliveries creates a delivery of the event for every webhook subscribed to its type
+// and returns how many were created. An event enqueued again is not delivered twice.
+func (dbs *DBStorage) EnqueueDeliveries(event models.Event, payload []byte) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, `INSERT INTO deliveries (webhook_id, event_id, event_type, payload, next_attempt_at)
+		SELECT id, $1, $2, $3, now() FROM webhooks WHERE $2 = ANY(events)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`, event.ID, event.Type, payload)
+	if err != nil {
+		log.Error().Err(err).Msg("enqueue deliveries failed")
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// dueDeliveriesQuery takes at most $3 pending deliveries due at $1 and leases them until $2.
+// Rows locked by another dispatcher are skipped.
+const dueDeliveriesQuery = `WITH due AS (
+	SELECT id FROM deliveries WHERE status = 'pending' AND next_attempt_at <= $1
+	ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED),
+leased AS (UPDATE deliveries d SET next_attempt_at = $2 FROM due WHERE d.id = due.id RETURNING d.*)
+SELECT l.id, l.webhook_id, l.event_id, l.event_type, l.payload, l.status, l.attempts, l.next_attempt_at,
+	l.last_error, l.created_at, l.delivered_at, w.url, w.secret
+FROM leased l JOIN webhooks w ON w.id = l.webhook_id ORDER BY l.id`
+
+// DueDeliveries returns the pending deliveries due at the given time. They are not returned again
+// before consts.WebhookLease passes, so a crashed dispatcher does not lose them.
+func (dbs *DBStorage) DueDeliveries(now time.Time, limit int) ([]models.DeliveryTask, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, dueDeliveriesQuery, now, now.Add(consts.WebhookLease), limit)
+	if err != nil {
+		log.Error().Err(err).Msg("get due deliveries failed")
+		return nil, err
+	}
+	defer rows.Close()
+	var tasks []models.DeliveryTask
+	for rows.Next() {
+		var task models.DeliveryTask
+		task.Delivery, err = scanDelivery(rows, &task.URL, &task.Secret)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// SaveAttempt records the attempt and the state of the delivery after it.
+func (dbs *DBStorage) SaveAttempt(delivery models.Delivery, attempt models.DeliveryAttempt) error {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return err
+	}
+	defer rollback(ctx, tx)
+	_, err = tx.Exec(ctx, `INSERT INTO delivery_attempts (delivery_id, at, status_code, error, duration)
+		VALUES ($1, $2, $3, $4, $5)`,
+		delivery.ID, attempt.At, attempt.StatusCode, attempt.Error, attempt.Duration)
+	if err != nil {
+		log.Error().Err(err).Msg("save delivery attempt failed")
+		return err
+	}
+	_, err = tx.Exec(ctx, `UPDATE deliveries SET status=$2, attempts=$3, next_attempt_at=$4, last_error=$5,
+		delivered_at=$6 WHERE id=$1`, delivery.ID, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		delivery.LastError, delivery.DeliveredAt)
+	if err != nil {
+		log.Error().Err(err).Msg("update delivery failed")
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetDeliveries returns the latest deliveries of the webhook, the newest first. Empty status matches any.
+func (dbs *DBStorage) GetDeliveries(webhookID string, status models.DeliveryStatus, limit int) ([]models.Delivery, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT `+deliveryColumns+` FROM deliveries
+		WHERE webhook_id=$1 AND ($2 = '' OR status = $2) ORDER BY id DESC LIMIT $3`, webhookID, status, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("get deliveries failed")
+		return nil, err
+	}
+	defer rows.Close()
+	deliveries := []models.Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func (dbs *DBStorage) GetDelivery(id int64) (models.Delivery, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	delivery, err := scanDelivery(dbs.conn.QueryRow(ctx, `SELECT `+deliveryColumns+` FROM deliveries WHERE id=$1`, id))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Delivery{}, storerrros.ErrDeliveryNoExist
+		}
+		log.Error().Err(err).Msg("get delivery failed")
+		return models.Delivery{}, err
+	}
+	return delivery, nil
+}
+
+// GetDeliveryAttempts returns the attempts of the delivery in the order they were made.
+func (dbs *DBStorage) GetDeliveryAttempts(id int64) ([]models.DeliveryAttempt, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	rows, err := dbs.conn.Query(ctx, `SELECT id, delivery_id, at, status_code, error, duration
+		FROM delivery_attempts WHERE delivery_id=$1 ORDER BY id`, id)
+	if err != nil {
+		log.Error().Err(err).Msg("get delivery attempts failed")
+		return nil, err
+	}
+	defer rows.Close()
+	attempts := []models.DeliveryAttempt{}
+	for rows.Next() {
+		var attempt models.DeliveryAttempt
+		err = rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.At, &attempt.StatusCode, &attempt.Error,
+			&attempt.Duration)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to scan data from db")
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// ReplayDelivery sends a failed delivery again right away with a fresh set of attempts.
+// The attempts made before are kept in the history.
+func (dbs *DBStorage) ReplayDelivery(id int64) (models.Delivery, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tx, err := dbs.conn.Begin(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to start tx")
+		return models.Delivery{}, err
+	}
+	defer rollback(ctx, tx)
+	var status models.DeliveryStatus
+	if err = tx.QueryRow(ctx, "SELECT status FROM deliveries WHERE id=$1 FOR UPDATE", id).Scan(&status); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Delivery{}, storerrros.ErrDeliveryNoExist
+		}
+		log.Error().Err(err).Msg("get delivery failed")
+		return models.Delivery{}, err
+	}
+	if status != models.DeliveryFailed {
+		return models.Delivery{}, storerrros.ErrDeliveryNotFailed
+	}
+	delivery, err := scanDelivery(tx.QueryRow(ctx, `UPDATE deliveries SET status=$2, attempts=0, next_attempt_at=now()
+		WHERE id=$1 RETURNING `+deliveryColumns, id, models.DeliveryPending))
+	if err != nil {
+		log.Error().Err(err).Msg("replay delivery failed")
+		return models.Delivery{}, err
+	}
+	return delivery, tx.Commit(ctx)
+}
+
+// overdueLoansQuery marks the open loans due before $1 as overdue and writes an event per loan.
+// A loan is reported once, marked loans are skipped by the next runs.
+const overdueLoansQuery = `WITH overdue AS (
+	UPDATE loans SET overdue_at = $1 WHERE returned_at IS NULL AND overdue_at IS NULL AND due_at < $1
+	RETURNING lid, barcode, uid, issued_at, due_at)
+INSERT INTO outbox (type, subject, data)
+	SELECT '` + string(models.EventLoanOverdue) + `', o.lid, jsonb_build_object('lid', o.lid, 'barcode', o.barcode,
+		'bid', i.bid, 'uid', o.uid, 'issued_at', o.issued_at, 'due_at', o.due_at)
+	FROM overdue o JOIN items i ON i.barcode = o.barcode`
+
+// MarkOverdueLoans reports the loans which became overdue since the last call and returns how many.
+func (dbs *DBStorage) MarkOverdueLoans(now time.Time) (int, error) {
+	log := logger.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), dbs.timeout)
+	defer cancel()
+	tag, err := dbs.conn.Exec(ctx, overdueLoansQuery, now)
+	if err != nil {
+		log.Error().Err(err).Msg("mark overdue loans failed")
+		return 0, err
+	}
+	return int(tag.RowsAffected()), nil
+}
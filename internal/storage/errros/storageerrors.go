@@ -10,4 +10,33 @@ var (
 
 	ErrBookNoExist    = errors.New("book does not exists")
 	ErrEmptyBooksList = errors.New("empty books list")
+	ErrBookNotTrashed = errors.New("book is not in the trash")
+	ErrBookOnLoan     = errors.New("book has copies on loan")
+	ErrInvalidBook    = errors.New("book is rejected by the database")
+
+	ErrItemNoExist     = errors.New("item does not exists")
+	ErrItemExists      = errors.New("item with this barcode alredy exists")
+	ErrItemUnavailable = errors.New("item is not available for checkout")
+	ErrItemNotOnLoan   = errors.New("item is not on loan")
+	ErrItemAtBranch    = errors.New("item is alredy at this branch")
+
+	ErrBranchNoExist = errors.New("branch does not exists")
+	ErrBranchExists  = errors.New("branch alredy exists")
+
+	ErrHoldNoExist       = errors.New("hold does not exists")
+	ErrHoldClosed        = errors.New("hold is alredy closed")
+	ErrTransferNoExist   = errors.New("transfer does not exists")
+	ErrTransferBadStatus = errors.New("transfer can not change status from current one")
+
+	ErrImportNoExist = errors.New("import does not exists")
+
+	ErrWebhookNoExist    = errors.New("webhook does not exists")
+	ErrDeliveryNoExist   = errors.New("delivery does not exists")
+	ErrDeliveryNotFailed = errors.New("only failed deliveries can be replayed")
+
+	ErrAlertNoExist = errors.New("alert does not exists")
+	ErrAlertExists  = errors.New("alert alredy exists")
+
+	ErrNoticeExists        = errors.New("notice alredy sent")
+	ErrNotificationNoExist = errors.New("notification does not exists")
 )
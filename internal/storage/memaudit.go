@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// addAudit records the change, the caller holds the lock.
+func (ms *MemStorage) addAudit(origin models.AuditEntry, action models.AuditAction, entity, id string,
+	changes []models.FieldChange,
+) {
+	entry := auditEntry(origin, action, entity, id, changes)
+	ms.auditSeq++
+	entry.ID = ms.auditSeq
+	ms.auditStor = append(ms.auditStor, entry)
+}
+
+func (ms *MemStorage) GetAudit(entity, id string, limit int) ([]models.AuditEntry, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	entries := []models.AuditEntry{}
+	for i := len(ms.auditStor) - 1; i >= 0 && len(entries) < limit; i-- {
+		entry := ms.auditStor[i]
+		if (entity == "" || entry.Entity == entity) && (id == "" || entry.EntityID == id) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (ms *MemStorage) PurgeAudit(before time.Time) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	kept := ms.auditStor[:0]
+	for _, entry := range ms.auditStor {
+		if !entry.At.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	purged := len(ms.auditStor) - len(kept)
+	ms.auditStor = kept
+	return purged, nil
+}
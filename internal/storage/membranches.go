@@ -0,0 +1,301 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/google/uuid"
+)
+
+func (ms *MemStorage) SaveBranch(branch models.Branch) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.branchStor[branch.ID]; ok {
+		return storerrros.ErrBranchExists
+	}
+	ms.branchStor[branch.ID] = branch
+	return nil
+}
+
+func (ms *MemStorage) GetBranches() ([]models.Branch, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	branches := make([]models.Branch, 0, len(ms.branchStor))
+	for _, branch := range ms.branchStor {
+		branches = append(branches, branch)
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].Name < branches[j].Name })
+	return branches, nil
+}
+
+func (ms *MemStorage) GetBranchBooks(branch string) ([]models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	counted := make(map[string]models.Book)
+	for _, item := range ms.itemStor {
+		if _, deleted := ms.deletedStor[item.BID]; item.CurrentBranch != branch || deleted {
+			continue
+		}
+		book, ok := counted[item.BID]
+		if !ok {
+			book = ms.bookStor[item.BID]
+			book.Count, book.Available = 0, 0
+		}
+		if item.Status != models.ItemLost {
+			book.Count++
+		}
+		if item.Status == models.ItemAvailable {
+			book.Available++
+		}
+		counted[item.BID] = book
+	}
+	if len(counted) < 1 {
+		return nil, storerrros.ErrEmptyBooksList
+	}
+	books := make([]models.Book, 0, len(counted))
+	for _, book := range counted {
+		books = append(books, book)
+	}
+	return books, nil
+}
+
+func (ms *MemStorage) GetAvailability(bid string) ([]models.Availability, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	byBranch := make(map[string]models.Availability)
+	for _, item := range ms.itemStor {
+		if item.BID != bid {
+			continue
+		}
+		av := byBranch[item.CurrentBranch]
+		av.Branch = item.CurrentBranch
+		if item.Status != models.ItemLost {
+			av.Count++
+		}
+		if item.Status == models.ItemAvailable {
+			av.Available++
+		}
+		byBranch[item.CurrentBranch] = av
+	}
+	if len(byBranch) < 1 {
+		return nil, storerrros.ErrItemNoExist
+	}
+	res := make([]models.Availability, 0, len(byBranch))
+	for _, av := range byBranch {
+		res = append(res, av)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Branch < res[j].Branch })
+	return res, nil
+}
+
+func (ms *MemStorage) PlaceHold(hold models.Hold) (models.Hold, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.bookStor[hold.BID]; !ok {
+		return models.Hold{}, storerrros.ErrBookNoExist
+	}
+	if _, ok := ms.branchStor[hold.PickupBranch]; !ok {
+		return models.Hold{}, storerrros.ErrBranchNoExist
+	}
+	hold.HID = uuid.New().String()
+	hold.Status = models.HoldWaiting
+	hold.CreatedAt = time.Now()
+	var free *models.Item
+	for _, item := range ms.itemStor {
+		if item.BID != hold.BID || item.Status != models.ItemAvailable {
+			continue
+		}
+		if free == nil || (item.CurrentBranch == hold.PickupBranch && free.CurrentBranch != hold.PickupBranch) {
+			free = &item
+		}
+	}
+	ms.holdStor[hold.HID] = hold
+	if free != nil {
+		ms.allocateItem(hold, *free)
+	}
+	return ms.holdStor[hold.HID], nil
+}
+
+func (ms *MemStorage) GetHolds(uid string) ([]models.Hold, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var holds []models.Hold
+	for _, hold := range ms.holdStor {
+		if hold.UID == uid {
+			holds = append(holds, hold)
+		}
+	}
+	sort.Slice(holds, func(i, j int) bool { return holds[i].CreatedAt.After(holds[j].CreatedAt) })
+	return holds, nil
+}
+
+func (ms *MemStorage) CancelHold(hid, uid string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	hold, ok := ms.holdStor[hid]
+	if !ok || hold.UID != uid {
+		return storerrros.ErrHoldNoExist
+	}
+	if hold.Status == models.HoldFulfilled || hold.Status == models.HoldCancelled {
+		return storerrros.ErrHoldClosed
+	}
+	prev := hold.Status
+	hold.Status = models.HoldCancelled
+	ms.holdStor[hid] = hold
+	release := prev == models.HoldReady
+	if prev == models.HoldInTransit {
+		for tid, tr := range ms.transferStor {
+			if tr.HID == hid && tr.Status == models.TransferRequested {
+				tr.Status = models.TransferCancelled
+				ms.transferStor[tid] = tr
+				release = true
+			}
+		}
+	}
+	if release {
+		ms.shelveItem(hold.Barcode)
+	}
+	return nil
+}
+
+func (ms *MemStorage) SaveTransfer(tr models.Transfer) (models.Transfer, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	item, ok := ms.itemStor[tr.Barcode]
+	if !ok {
+		return models.Transfer{}, storerrros.ErrItemNoExist
+	}
+	if item.Status != models.ItemAvailable {
+		return models.Transfer{}, storerrros.ErrItemUnavailable
+	}
+	if item.CurrentBranch == tr.ToBranch {
+		return models.Transfer{}, storerrros.ErrItemAtBranch
+	}
+	if _, ok = ms.branchStor[tr.ToBranch]; !ok {
+		return models.Transfer{}, storerrros.ErrBranchNoExist
+	}
+	tr.TID = uuid.New().String()
+	tr.FromBranch = item.CurrentBranch
+	tr.Status = models.TransferRequested
+	tr.RequestedAt = time.Now()
+	ms.transferStor[tr.TID] = tr
+	return tr, nil
+}
+
+func (ms *MemStorage) GetTransfers(status string) ([]models.Transfer, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var transfers []models.Transfer
+	for _, tr := range ms.transferStor {
+		if status == "" || string(tr.Status) == status {
+			transfers = append(transfers, tr)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool { return transfers[i].RequestedAt.Before(transfers[j].RequestedAt) })
+	return transfers, nil
+}
+
+func (ms *MemStorage) ShipTransfer(tid string) (models.Transfer, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	tr, ok := ms.transferStor[tid]
+	if !ok {
+		return models.Transfer{}, storerrros.ErrTransferNoExist
+	}
+	if tr.Status != models.TransferRequested {
+		return models.Transfer{}, storerrros.ErrTransferBadStatus
+	}
+	item := ms.itemStor[tr.Barcode]
+	if (tr.HID == "" && item.Status != models.ItemAvailable) || (tr.HID != "" && item.Status != models.ItemOnHold) {
+		return models.Transfer{}, storerrros.ErrItemUnavailable
+	}
+	now := time.Now()
+	tr.Status = models.TransferInTransit
+	tr.ShippedAt = &now
+	ms.transferStor[tid] = tr
+	item.Status = models.ItemInTransit
+	ms.putItem(item)
+	return tr, nil
+}
+
+func (ms *MemStorage) ReceiveTransfer(tid string) (models.Transfer, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	tr, ok := ms.transferStor[tid]
+	if !ok {
+		return models.Transfer{}, storerrros.ErrTransferNoExist
+	}
+	if tr.Status != models.TransferInTransit {
+		return models.Transfer{}, storerrros.ErrTransferBadStatus
+	}
+	now := time.Now()
+	tr.Status = models.TransferReceived
+	tr.ReceivedAt = &now
+	ms.transferStor[tid] = tr
+	item := ms.itemStor[tr.Barcode]
+	item.CurrentBranch = tr.ToBranch
+	ms.putItem(item)
+	if hold, ok := ms.holdStor[tr.HID]; ok && hold.Status == models.HoldInTransit {
+		hold.Status = models.HoldReady
+		hold.ReadyAt = &now
+		ms.holdStor[hold.HID] = hold
+		ms.addEvent(models.EventHoldReady, hold.HID, hold)
+		item.Status = models.ItemOnHold
+		ms.putItem(item)
+		return tr, nil
+	}
+	ms.shelveItem(tr.Barcode)
+	return tr, nil
+}
+
+// shelveItem hands a free copy to the oldest waiting hold for its book or puts it back on the shelf.
+// The caller must hold the lock.
+func (ms *MemStorage) shelveItem(barcode string) {
+	item := ms.itemStor[barcode]
+	var next *models.Hold
+	for _, hold := range ms.holdStor {
+		if hold.BID != item.BID || hold.Status != models.HoldWaiting {
+			continue
+		}
+		if next == nil || hold.CreatedAt.Before(next.CreatedAt) {
+			next = &hold
+		}
+	}
+	if next == nil {
+		item.Status = models.ItemAvailable
+		ms.putItem(item)
+		return
+	}
+	ms.allocateItem(*next, item)
+}
+
+// allocateItem reserves the copy for the hold. The caller must hold the lock.
+func (ms *MemStorage) allocateItem(hold models.Hold, item models.Item) {
+	item.Status = models.ItemOnHold
+	hold.Barcode = item.Barcode
+	if item.CurrentBranch == "" || item.CurrentBranch == hold.PickupBranch {
+		now := time.Now()
+		item.CurrentBranch = hold.PickupBranch
+		hold.Status = models.HoldReady
+		hold.ReadyAt = &now
+	} else {
+		hold.Status = models.HoldInTransit
+		tr := models.Transfer{
+			TID:         uuid.New().String(),
+			Barcode:     item.Barcode,
+			FromBranch:  item.CurrentBranch,
+			ToBranch:    hold.PickupBranch,
+			HID:         hold.HID,
+			Status:      models.TransferRequested,
+			RequestedAt: time.Now(),
+		}
+		ms.transferStor[tr.TID] = tr
+	}
+	ms.putItem(item)
+	ms.holdStor[hold.HID] = hold
+	if hold.Status == models.HoldReady {
+		ms.addEvent(models.EventHoldReady, hold.HID, hold)
+	}
+}
@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+func (ms *MemStorage) SaveDeskAlert(alert models.DeskAlert) (models.DeskAlert, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, saved := range ms.alertStor {
+		if saved.EventID == alert.EventID {
+			return models.DeskAlert{}, storerrros.ErrAlertExists
+		}
+	}
+	ms.alertSeq++
+	alert.ID = ms.alertSeq
+	alert.CreatedAt = time.Now()
+	alert.AckedBy, alert.AckedAt = "", nil
+	ms.alertStor = append(ms.alertStor, alert)
+	return alert, nil
+}
+
+func (ms *MemStorage) GetDeskAlerts(branch string) ([]models.DeskAlert, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	alerts := []models.DeskAlert{}
+	for _, alert := range ms.alertStor {
+		if alert.Branch == branch && alert.AckedAt == nil {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (ms *MemStorage) AckDeskAlert(id int64, branch, uid string) (models.DeskAlert, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for i, alert := range ms.alertStor {
+		if alert.ID != id || alert.Branch != branch {
+			continue
+		}
+		if alert.AckedAt == nil {
+			now := time.Now()
+			alert.AckedBy, alert.AckedAt = uid, &now
+			ms.alertStor[i] = alert
+		}
+		return alert, nil
+	}
+	return models.DeskAlert{}, storerrros.ErrAlertNoExist
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+// history keeps every version of the values stored under a key, like the history tables of the database.
+type history[T any] map[string][]version[T]
+
+type version[T any] struct {
+	from  time.Time
+	value T
+	gone  bool
+}
+
+func (h history[T]) record(key string, value T) {
+	h[key] = append(h[key], version[T]{from: time.Now(), value: value})
+}
+
+func (h history[T]) remove(key string) {
+	h[key] = append(h[key], version[T]{from: time.Now(), gone: true})
+}
+
+// at returns the values as they were at the given time.
+func (h history[T]) at(t time.Time) map[string]T {
+	values := make(map[string]T)
+	for key, versions := range h {
+		for i := len(versions) - 1; i >= 0; i-- {
+			if versions[i].from.After(t) {
+				continue
+			}
+			if !versions[i].gone {
+				values[key] = versions[i].value
+			}
+			break
+		}
+	}
+	return values
+}
+
+type bookVersion struct {
+	book    models.Book
+	deleted bool
+}
+
+func (ms *MemStorage) putBook(book models.Book) {
+	ms.bookStor[book.BID] = book
+	ms.recordBook(book.BID)
+}
+
+// recordBook saves the current state of the book into its history.
+func (ms *MemStorage) recordBook(bid string) {
+	_, deleted := ms.deletedStor[bid]
+	ms.bookHist.record(bid, bookVersion{book: ms.bookStor[bid], deleted: deleted})
+}
+
+func (ms *MemStorage) putItem(item models.Item) {
+	ms.itemStor[item.Barcode] = item
+	ms.itemHist.record(item.Barcode, item)
+}
+
+func (ms *MemStorage) GetBooksAt(at time.Time) ([]models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	items := ms.itemHist.at(at)
+	var books []models.Book
+	for _, version := range ms.bookHist.at(at) {
+		if !version.deleted {
+			books = append(books, withCopiesOf(version.book, items))
+		}
+	}
+	if len(books) < 1 {
+		return nil, storerrros.ErrEmptyBooksList
+	}
+	return books, nil
+}
+
+func (ms *MemStorage) GetBookAt(bid string, at time.Time) (models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	version, ok := ms.bookHist.at(at)[bid]
+	if !ok || version.deleted {
+		return models.Book{}, storerrros.ErrBookNoExist
+	}
+	return withCopiesOf(version.book, ms.itemHist.at(at)), nil
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+func (ms *MemStorage) SaveImport(job models.ImportJob) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.importStor[job.ID] = job
+	return nil
+}
+
+func (ms *MemStorage) UpdateImport(job models.ImportJob, rowErrs []models.ImportError) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.importStor[job.ID]; !ok {
+		return storerrros.ErrImportNoExist
+	}
+	ms.importStor[job.ID] = job
+	ms.importErrStor[job.ID] = append(ms.importErrStor[job.ID], rowErrs...)
+	return nil
+}
+
+func (ms *MemStorage) GetImport(id string) (models.ImportJob, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	job, ok := ms.importStor[id]
+	if !ok {
+		return models.ImportJob{}, storerrros.ErrImportNoExist
+	}
+	return job, nil
+}
+
+func (ms *MemStorage) GetImportErrors(id string) ([]models.ImportError, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return append([]models.ImportError(nil), ms.importErrStor[id]...), nil
+}
+
+func (ms *MemStorage) UnfinishedImports(before time.Time) ([]models.ImportJob, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var jobs []models.ImportJob
+	for _, job := range ms.importStor {
+		if (job.Status == models.ImportQueued || job.Status == models.ImportRunning) && job.CreatedAt.Before(before) {
+			jobs = append(jobs, job)
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs, nil
+}
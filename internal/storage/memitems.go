@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+	"github.com/google/uuid"
+)
+
+func (ms *MemStorage) SaveItem(item models.Item, origin models.AuditEntry) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.bookStor[item.BID]; !ok {
+		return "", storerrros.ErrBookNoExist
+	}
+	if _, ok := ms.itemStor[item.Barcode]; ok {
+		return "", storerrros.ErrItemExists
+	}
+	if !ms.branchExists(item.HomeBranch) || !ms.branchExists(item.CurrentBranch) {
+		return "", storerrros.ErrBranchNoExist
+	}
+	if item.CurrentBranch == "" {
+		item.CurrentBranch = item.HomeBranch
+	}
+	before := ms.withCopies(ms.bookStor[item.BID])
+	barcode := ms.addItem(item)
+	ms.addAudit(origin, models.AuditCount, models.AuditBook, item.BID,
+		diff(before, ms.withCopies(ms.bookStor[item.BID])))
+	return barcode, nil
+}
+
+func (ms *MemStorage) GetItems(bid string) ([]models.Item, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var items []models.Item
+	for _, item := range ms.itemStor {
+		if item.BID == bid {
+			items = append(items, item)
+		}
+	}
+	if len(items) < 1 {
+		return nil, storerrros.ErrItemNoExist
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Barcode < items[j].Barcode })
+	return items, nil
+}
+
+func (ms *MemStorage) GetItem(barcode string) (models.Item, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	item, ok := ms.itemStor[barcode]
+	if !ok {
+		return models.Item{}, storerrros.ErrItemNoExist
+	}
+	return item, nil
+}
+
+func (ms *MemStorage) UpdateItem(item models.Item, origin models.AuditEntry) (models.Item, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	memItem, ok := ms.itemStor[item.Barcode]
+	if !ok {
+		return models.Item{}, storerrros.ErrItemNoExist
+	}
+	if !ms.branchExists(item.HomeBranch) || !ms.branchExists(item.CurrentBranch) {
+		return models.Item{}, storerrros.ErrBranchNoExist
+	}
+	before := memItem
+	memItem.Condition = item.Condition
+	memItem.Location = item.Location
+	memItem.Status = item.Status
+	memItem.HomeBranch = item.HomeBranch
+	memItem.CurrentBranch = item.CurrentBranch
+	ms.putItem(memItem)
+	if memItem.Status == models.ItemAvailable {
+		ms.shelveItem(memItem.Barcode)
+	}
+	after := ms.itemStor[memItem.Barcode]
+	ms.addAudit(origin, models.AuditUpdate, models.AuditItem, after.Barcode, diff(before, after))
+	return after, nil
+}
+
+func (ms *MemStorage) CheckoutItem(barcode, uid string, period time.Duration) (models.Loan, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	item, ok := ms.itemStor[barcode]
+	if !ok {
+		return models.Loan{}, storerrros.ErrItemNoExist
+	}
+	switch item.Status {
+	case models.ItemAvailable:
+	case models.ItemOnHold:
+		if !ms.fulfilHold(barcode, uid) {
+			return models.Loan{}, storerrros.ErrItemUnavailable
+		}
+	default:
+		return models.Loan{}, storerrros.ErrItemUnavailable
+	}
+	now := time.Now()
+	loan := models.Loan{
+		LID:      uuid.New().String(),
+		Barcode:  barcode,
+		BID:      item.BID,
+		UID:      uid,
+		IssuedAt: now,
+		DueAt:    now.Add(period),
+	}
+	item.Status = models.ItemCheckedOut
+	ms.putItem(item)
+	ms.loanStor[loan.LID] = loan
+	ms.addEvent(models.EventLoanCreated, loan.LID, loan)
+	return loan, nil
+}
+
+// ReturnItem closes the active loan of the copy. A non empty branch becomes the current branch of the copy.
+func (ms *MemStorage) ReturnItem(barcode, branch string) (models.Loan, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for lid, loan := range ms.loanStor {
+		if loan.Barcode != barcode || loan.ReturnedAt != nil {
+			continue
+		}
+		now := time.Now()
+		loan.ReturnedAt = &now
+		if !ms.branchExists(branch) {
+			return models.Loan{}, storerrros.ErrBranchNoExist
+		}
+		ms.loanStor[lid] = loan
+		if branch != "" {
+			item := ms.itemStor[barcode]
+			item.CurrentBranch = branch
+			ms.putItem(item)
+		}
+		ms.shelveItem(barcode)
+		ms.addEvent(models.EventLoanReturned, loan.LID, loan)
+		return loan, nil
+	}
+	return models.Loan{}, storerrros.ErrItemNotOnLoan
+}
+
+// fulfilHold closes the ready hold of the member for the copy. The caller must hold the lock.
+func (ms *MemStorage) fulfilHold(barcode, uid string) bool {
+	for hid, hold := range ms.holdStor {
+		if hold.Barcode == barcode && hold.UID == uid && hold.Status == models.HoldReady {
+			hold.Status = models.HoldFulfilled
+			ms.holdStor[hid] = hold
+			return true
+		}
+	}
+	return false
+}
+
+func (ms *MemStorage) branchExists(id string) bool {
+	if id == "" {
+		return true
+	}
+	_, ok := ms.branchStor[id]
+	return ok
+}
+
+// addItem stores a copy of the book, generating a barcode when it is empty. The caller must hold the lock.
+func (ms *MemStorage) addItem(item models.Item) string {
+	for item.Barcode == "" {
+		ms.barcodeSeq++
+		barcode := fmt.Sprintf("BK%08d", ms.barcodeSeq)
+		if _, ok := ms.itemStor[barcode]; !ok {
+			item.Barcode = barcode
+		}
+	}
+	if item.AcquiredAt.IsZero() {
+		item.AcquiredAt = time.Now()
+	}
+	if item.Condition == "" {
+		item.Condition = consts.DefaultCondition
+	}
+	if item.Status == "" {
+		item.Status = models.ItemAvailable
+	}
+	ms.putItem(item)
+	ms.addEvent(models.EventCopyAdded, item.BID, item)
+	return item.Barcode
+}
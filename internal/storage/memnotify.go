@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+func (ms *MemStorage) GetNotifyPrefs(uid string) (models.NotifyPrefs, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	if _, ok := ms.usersStor[uid]; !ok {
+		return models.NotifyPrefs{}, storerrros.ErrUserNotFound
+	}
+	return ms.prefsOf(uid), nil
+}
+
+func (ms *MemStorage) prefsOf(uid string) models.NotifyPrefs {
+	prefs, ok := ms.prefsStor[uid]
+	if !ok {
+		prefs = models.NotifyPrefs{UID: uid, Channel: models.NotifyEmail, Locale: consts.DefaultLocale}
+	}
+	return prefs
+}
+
+func (ms *MemStorage) SetNotifyPrefs(prefs models.NotifyPrefs) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.usersStor[prefs.UID]; !ok {
+		return storerrros.ErrUserNotFound
+	}
+	ms.prefsStor[prefs.UID] = prefs
+	return nil
+}
+
+func (ms *MemStorage) LoansDue(before time.Time) ([]models.LoanDue, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var loans []models.LoanDue
+	for _, loan := range ms.loanStor {
+		if loan.ReturnedAt != nil || !loan.DueAt.Before(before) {
+			continue
+		}
+		loans = append(loans, models.LoanDue{
+			Loan:  loan,
+			Email: ms.usersStor[loan.UID].Email,
+			Lable: ms.bookStor[loan.BID].Lable,
+			Prefs: ms.prefsOf(loan.UID),
+		})
+	}
+	sort.Slice(loans, func(i, j int) bool { return loans[i].DueAt.Before(loans[j].DueAt) })
+	return loans, nil
+}
+
+func (ms *MemStorage) ClaimNotice(notice models.NoticeLog) (models.NoticeLog, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for i, saved := range ms.noticeStor {
+		if saved.LID != notice.LID || saved.Kind != notice.Kind || saved.Stage != notice.Stage {
+			continue
+		}
+		if saved.Status != models.NoticeFailed || saved.Attempts >= consts.NoticeMaxAttempts {
+			return models.NoticeLog{}, storerrros.ErrNoticeExists
+		}
+		saved.Status, saved.Attempts, saved.Error = models.NoticePending, saved.Attempts+1, ""
+		saved.Channel, saved.Subject = notice.Channel, notice.Subject
+		ms.noticeStor[i] = saved
+		return saved, nil
+	}
+	ms.noticeSeq++
+	notice.ID = ms.noticeSeq
+	notice.Status, notice.Attempts, notice.Error = models.NoticePending, 1, ""
+	notice.CreatedAt, notice.SentAt = time.Now(), nil
+	ms.noticeStor = append(ms.noticeStor, notice)
+	return notice, nil
+}
+
+func (ms *MemStorage) FinishNotice(id int64, status models.NoticeStatus, errText string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for i, notice := range ms.noticeStor {
+		if notice.ID != id {
+			continue
+		}
+		notice.Status, notice.Error, notice.SentAt = status, errText, nil
+		if status == models.NoticeSent {
+			now := time.Now()
+			notice.SentAt = &now
+		}
+		ms.noticeStor[i] = notice
+		return nil
+	}
+	return nil
+}
+
+func (ms *MemStorage) GetNoticeLog(uid string, limit int) ([]models.NoticeLog, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	notices := []models.NoticeLog{}
+	for i := len(ms.noticeStor) - 1; i >= 0 && len(notices) < limit; i-- {
+		if uid == "" || ms.noticeStor[i].UID == uid {
+			notices = append(notices, ms.noticeStor[i])
+		}
+	}
+	return notices, nil
+}
+
+func (ms *MemStorage) SaveNotification(notification models.Notification) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if notification.Source != "" {
+		for _, saved := range ms.notificationStor {
+			if saved.Source == notification.Source {
+				return nil
+			}
+		}
+	}
+	ms.notificationSeq++
+	notification.ID = ms.notificationSeq
+	notification.CreatedAt, notification.ReadAt = time.Now(), nil
+	ms.notificationStor = append(ms.notificationStor, notification)
+	return nil
+}
+
+func (ms *MemStorage) GetNotifications(uid string, before int64, limit int, unread bool) ([]models.Notification, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	notifications := []models.Notification{}
+	for i := len(ms.notificationStor) - 1; i >= 0 && len(notifications) < limit; i-- {
+		notification := ms.notificationStor[i]
+		if notification.UID != uid || (before > 0 && notification.ID >= before) ||
+			(unread && notification.ReadAt != nil) {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
+
+func (ms *MemStorage) CountUnread(uid string) (int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	unread := 0
+	for _, notification := range ms.notificationStor {
+		if notification.UID == uid && notification.ReadAt == nil {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (ms *MemStorage) ReadNotification(id int64, uid string) (models.Notification, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for i, notification := range ms.notificationStor {
+		if notification.ID != id || notification.UID != uid {
+			continue
+		}
+		if notification.ReadAt == nil {
+			now := time.Now()
+			notification.ReadAt = &now
+			ms.notificationStor[i] = notification
+		}
+		return notification, nil
+	}
+	return models.Notification{}, storerrros.ErrNotificationNoExist
+}
+
+func (ms *MemStorage) ReadAllNotifications(uid string) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now()
+	read := 0
+	for i, notification := range ms.notificationStor {
+		if notification.UID == uid && notification.ReadAt == nil {
+			ms.notificationStor[i].ReadAt = &now
+			read++
+		}
+	}
+	return read, nil
+}
+
+func (ms *MemStorage) PurgeNotifications(readBefore time.Time) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	before := len(ms.notificationStor)
+	ms.notificationStor = slices.DeleteFunc(ms.notificationStor, func(n models.Notification) bool {
+		return n.ReadAt != nil && n.ReadAt.Before(readBefore)
+	})
+	return before - len(ms.notificationStor), nil
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+// addEvent puts a domain event into the outbox. The caller must hold the lock.
+func (ms *MemStorage) addEvent(typ models.EventType, subject string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	ms.eventSeq++
+	ms.outbox = append(ms.outbox, models.Event{
+		ID:        ms.eventSeq,
+		Type:      typ,
+		Subject:   subject,
+		Data:      payload,
+		CreatedAt: time.Now(),
+	})
+}
+
+func (ms *MemStorage) PendingEvents(limit int) ([]models.Event, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	events := ms.outbox[:min(limit, len(ms.outbox))]
+	return slices.Clone(events), nil
+}
+
+// MarkPublished moves the events out of the outbox. The memory storage keeps only
+// the last consts.StreamReplayLimit published events, enough for resumed streams.
+func (ms *MemStorage) MarkPublished(ids []int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.outbox = slices.DeleteFunc(ms.outbox, func(event models.Event) bool {
+		if !slices.Contains(ids, event.ID) {
+			return false
+		}
+		ms.published = append(ms.published, event)
+		return true
+	})
+	if extra := len(ms.published) - consts.StreamReplayLimit; extra > 0 {
+		ms.published = slices.Delete(ms.published, 0, extra)
+	}
+	return nil
+}
+
+func (ms *MemStorage) EventsAfter(after int64, limit int) ([]models.Event, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var events []models.Event
+	for _, event := range ms.published {
+		if event.ID > after && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
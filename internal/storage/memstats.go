@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+)
+
+func (ms *MemStorage) LibraryStats() (models.LibraryStats, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	var stats models.LibraryStats
+	for bid := range ms.bookStor {
+		if _, deleted := ms.deletedStor[bid]; !deleted {
+			stats.Titles++
+		}
+	}
+	for _, item := range ms.itemStor {
+		if _, deleted := ms.deletedStor[item.BID]; deleted || item.Status == models.ItemLost {
+			continue
+		}
+		stats.Copies++
+		if item.Status == models.ItemAvailable {
+			stats.AvailableCopies++
+		}
+	}
+	now := time.Now()
+	for _, loan := range ms.loanStor {
+		if loan.ReturnedAt != nil {
+			continue
+		}
+		stats.ActiveLoans++
+		if loan.DueAt.Before(now) {
+			stats.OverdueLoans++
+		}
+	}
+	return stats, nil
+}
@@ -1,6 +1,11 @@
 package storage
 
 import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/Dorrrke/g3-bookly/internal/domain/models"
 	"github.com/Dorrrke/g3-bookly/internal/logger"
 	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
@@ -9,19 +14,80 @@ import (
 )
 
 type MemStorage struct {
+	mu        sync.RWMutex
 	usersStor map[string]models.User
 	bookStor  map[string]models.Book
+	// deletedStor keeps who deleted the soft deleted books and when.
+	deletedStor map[string]deletion
+	bookHist    history[bookVersion]
+	itemHist    history[models.Item]
+	itemStor    map[string]models.Item
+	loanStor    map[string]models.Loan
+	barcodeSeq  int
+
+	branchStor   map[string]models.Branch
+	holdStor     map[string]models.Hold
+	transferStor map[string]models.Transfer
+
+	importStor    map[string]models.ImportJob
+	importErrStor map[string][]models.ImportError
+
+	auditStor []models.AuditEntry
+	auditSeq  int64
+
+	outbox    []models.Event
+	published []models.Event
+	eventSeq  int64
+	// overdueStor keeps the loans already reported as overdue.
+	overdueStor map[string]bool
+
+	webhookStor  map[string]models.Webhook
+	deliveryStor map[int64]models.Delivery
+	attemptStor  map[int64][]models.DeliveryAttempt
+	deliverySeq  int64
+	attemptSeq   int64
+
+	alertStor []models.DeskAlert
+	alertSeq  int64
+
+	prefsStor        map[string]models.NotifyPrefs
+	noticeStor       []models.NoticeLog
+	noticeSeq        int64
+	notificationStor []models.Notification
+	notificationSeq  int64
 }
 
 func New() *MemStorage {
 	return &MemStorage{
-		usersStor: make(map[string]models.User),
-		bookStor:  make(map[string]models.Book),
+		usersStor:   make(map[string]models.User),
+		bookStor:    make(map[string]models.Book),
+		deletedStor: make(map[string]deletion),
+		bookHist:    make(history[bookVersion]),
+		itemHist:    make(history[models.Item]),
+		itemStor:    make(map[string]models.Item),
+		loanStor:    make(map[string]models.Loan),
+
+		branchStor:   make(map[string]models.Branch),
+		holdStor:     make(map[string]models.Hold),
+		transferStor: make(map[string]models.Transfer),
+
+		importStor:    make(map[string]models.ImportJob),
+		importErrStor: make(map[string][]models.ImportError),
+
+		overdueStor: make(map[string]bool),
+
+		webhookStor:  make(map[string]models.Webhook),
+		deliveryStor: make(map[int64]models.Delivery),
+		attemptStor:  make(map[int64][]models.DeliveryAttempt),
+
+		prefsStor: make(map[string]models.NotifyPrefs),
 	}
 }
 
-func (ms *MemStorage) SaveUser(user models.User) (string, error) {
+func (ms *MemStorage) SaveUser(user models.User, origin models.AuditEntry) (string, error) {
 	log := logger.Get()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 	uuid := uuid.New().String()
 	if _, err := ms.findUser(user.Email); err == nil {
 		return "", storerrros.ErrUserExists
@@ -31,16 +97,23 @@ func (ms *MemStorage) SaveUser(user models.User) (string, error) {
 		log.Error().Err(err).Msg("save user failed")
 		return "", err
 	}
-	log.Debug().Str("hash", string(hash)).Send()
 	user.Pass = string(hash)
 	user.UID = uuid
+	if user.Role == "" {
+		user.Role = models.RoleMember
+	}
 	ms.usersStor[uuid] = user
+	ms.addEvent(models.EventUserRegistered, uuid, map[string]string{"uid": uuid, "role": user.Role})
+	origin.Actor = uuid
+	ms.addAudit(origin, models.AuditCreate, models.AuditUser, uuid, diff(nil, user))
 	log.Debug().Any("storage", ms.usersStor).Send()
 	return uuid, nil
 }
 
 func (ms *MemStorage) ValidUser(user models.User) (string, error) {
 	log := logger.Get()
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
 	log.Debug().Any("storage", ms.usersStor).Send()
 	memUser, err := ms.findUser(user.Email)
 	if err != nil {
@@ -54,6 +127,8 @@ func (ms *MemStorage) ValidUser(user models.User) (string, error) {
 
 func (ms *MemStorage) GetUser(uid string) (models.User, error) {
 	log := logger.Get()
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
 	user, ok := ms.usersStor[uid]
 	if !ok {
 		log.Error().Str("uid", uid).Msg("user not found")
@@ -62,27 +137,47 @@ func (ms *MemStorage) GetUser(uid string) (models.User, error) {
 	return user, nil
 }
 
-func (ms *MemStorage) SaveBook(book models.Book) error {
-	memBook, err := ms.findBook(book)
-	if err == nil {
-		memBook.Count++
-		ms.bookStor[memBook.BID] = memBook
-		return nil
-	}
-	book.Count = 1
-	bid := uuid.New().String()
-	ms.bookStor[bid] = book
+func (ms *MemStorage) SetUserRole(uid, role string, origin models.AuditEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	user, ok := ms.usersStor[uid]
+	if !ok {
+		return storerrros.ErrUserNotFound
+	}
+	before := user.Role
+	user.Role = role
+	ms.usersStor[uid] = user
+	ms.addEvent(models.EventUserRoleChanged, uid, map[string]string{"uid": uid, "role": role})
+	ms.addAudit(origin, models.AuditUpdate, models.AuditUser, uid,
+		[]models.FieldChange{{Field: "role", Before: before, After: role}})
+	return nil
+}
+
+func (ms *MemStorage) SaveBook(book models.Book, origin models.AuditEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.saveBook(book, origin)
 	return nil
 }
 
-func (ms *MemStorage) SaveBooks(_ []models.Book) error {
+func (ms *MemStorage) SaveBooks(books []models.Book, origin models.AuditEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	for _, book := range books {
+		ms.saveBook(book, origin)
+	}
 	return nil
 }
 
 func (ms *MemStorage) GetBooks() ([]models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
 	var books []models.Book
-	for _, book := range ms.bookStor {
-		books = append(books, book)
+	for bid, book := range ms.bookStor {
+		if _, deleted := ms.deletedStor[bid]; deleted {
+			continue
+		}
+		books = append(books, ms.withCopies(book))
 	}
 	if len(books) < 1 {
 		return nil, storerrros.ErrEmptyBooksList
@@ -90,14 +185,103 @@ func (ms *MemStorage) GetBooks() ([]models.Book, error) {
 	return books, nil
 }
 
+func (ms *MemStorage) GetBooksPage(after string, limit int) ([]models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	bids := make([]string, 0, len(ms.bookStor))
+	for bid := range ms.bookStor {
+		if _, deleted := ms.deletedStor[bid]; !deleted && bid > after {
+			bids = append(bids, bid)
+		}
+	}
+	sort.Strings(bids)
+	if len(bids) > limit {
+		bids = bids[:limit]
+	}
+	books := make([]models.Book, 0, len(bids))
+	for _, bid := range bids {
+		books = append(books, ms.withCopies(ms.bookStor[bid]))
+	}
+	return books, nil
+}
+
 func (ms *MemStorage) GetBook(bid string) (models.Book, error) {
 	log := logger.Get()
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
 	book, ok := ms.bookStor[bid]
-	if !ok {
+	if _, deleted := ms.deletedStor[bid]; !ok || deleted {
 		log.Error().Str("bid", bid).Msg("user not found")
 		return models.Book{}, storerrros.ErrBookNoExist
 	}
-	return book, nil
+	return ms.withCopies(book), nil
+}
+
+func (ms *MemStorage) SetCover(bid, version, contentType string, origin models.AuditEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	book, ok := ms.bookStor[bid]
+	if _, deleted := ms.deletedStor[bid]; !ok || deleted {
+		return storerrros.ErrBookNoExist
+	}
+	before := book.Cover
+	book.Cover, book.CoverType = version, contentType
+	ms.putBook(book)
+	ms.addAudit(origin, models.AuditUpdate, models.AuditBook, bid,
+		diff(models.Book{Cover: before}, models.Book{Cover: version}))
+	return nil
+}
+
+func (ms *MemStorage) FindBook(lable, author string) (models.Book, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	book, err := ms.findBook(models.Book{Lable: lable, Author: author})
+	if err != nil {
+		return models.Book{}, err
+	}
+	return ms.withCopies(book), nil
+}
+
+// saveBook adds a copy of the book like saveBookCopy of the database, the caller holds the lock.
+func (ms *MemStorage) saveBook(book models.Book, origin models.AuditEntry) {
+	var before any
+	memBook, err := ms.findBook(book)
+	if err != nil {
+		memBook = book
+		memBook.BID = uuid.New().String()
+		memBook.Count, memBook.Available = 0, 0
+		ms.putBook(memBook)
+		ms.addEvent(models.EventBookAdded, memBook.BID, memBook)
+	} else {
+		before = ms.withCopies(memBook)
+	}
+	ms.addItem(models.Item{BID: memBook.BID})
+	action := models.AuditCount
+	if before == nil {
+		action = models.AuditCreate
+	}
+	ms.addAudit(origin, action, models.AuditBook, memBook.BID, diff(before, ms.withCopies(ms.bookStor[memBook.BID])))
+}
+
+// withCopies fills the copy counters of the book from its items statuses.
+func (ms *MemStorage) withCopies(book models.Book) models.Book {
+	return withCopiesOf(book, ms.itemStor)
+}
+
+func withCopiesOf(book models.Book, items map[string]models.Item) models.Book {
+	book.Count, book.Available = 0, 0
+	for _, item := range items {
+		if item.BID != book.BID {
+			continue
+		}
+		if item.Status != models.ItemLost {
+			book.Count++
+		}
+		if item.Status == models.ItemAvailable {
+			book.Available++
+		}
+	}
+	return book
 }
 
 func (ms *MemStorage) findUser(login string) (models.User, error) {
@@ -109,8 +293,12 @@ func (ms *MemStorage) findUser(login string) (models.User, error) {
 	return models.User{}, storerrros.ErrUserNoExist
 }
 
+// findBook returns the book the value is merged into, books in the trash are left out.
 func (ms *MemStorage) findBook(value models.Book) (models.Book, error) {
-	for _, book := range ms.bookStor {
+	for bid, book := range ms.bookStor {
+		if _, deleted := ms.deletedStor[bid]; deleted {
+			continue
+		}
 		if book.Lable == value.Lable && book.Author == value.Author {
 			return book, nil
 		}
@@ -118,10 +306,116 @@ func (ms *MemStorage) findBook(value models.Book) (models.Book, error) {
 	return models.Book{}, storerrros.ErrBookNoExist
 }
 
-func (ms *MemStorage) DeleteBooks() error {
+type deletion struct {
+	by string
+	at time.Time
+}
+
+func (ms *MemStorage) SetDeleteStatus(bid string, origin models.AuditEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	_, ok := ms.bookStor[bid]
+	if _, deleted := ms.deletedStor[bid]; !ok || deleted {
+		return storerrros.ErrBookNoExist
+	}
+	ms.deletedStor[bid] = deletion{by: origin.Actor, at: time.Now()}
+	ms.recordBook(bid)
+	ms.addEvent(models.EventBookDeleted, bid, map[string]string{"bid": bid, "deleted_by": origin.Actor})
+	ms.addAudit(origin, models.AuditDelete, models.AuditBook, bid,
+		[]models.FieldChange{{Field: "deleted", Before: false, After: true}})
 	return nil
 }
 
-func (ms *MemStorage) SetDeleteStatus(_ string) error {
+func (ms *MemStorage) GetTrash() ([]models.TrashedBook, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	trash := make([]models.TrashedBook, 0, len(ms.deletedStor))
+	for bid, del := range ms.deletedStor {
+		trash = append(trash, models.TrashedBook{
+			Book:      ms.withCopies(ms.bookStor[bid]),
+			DeletedBy: del.by,
+			DeletedAt: del.at,
+		})
+	}
+	sort.Slice(trash, func(i, j int) bool { return trash[i].DeletedAt.After(trash[j].DeletedAt) })
+	return trash, nil
+}
+
+func (ms *MemStorage) RestoreBook(bid string, origin models.AuditEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, deleted := ms.deletedStor[bid]; !deleted {
+		return storerrros.ErrBookNotTrashed
+	}
+	delete(ms.deletedStor, bid)
+	ms.recordBook(bid)
+	ms.addEvent(models.EventBookRestored, bid, map[string]string{"bid": bid})
+	ms.addAudit(origin, models.AuditRestore, models.AuditBook, bid,
+		[]models.FieldChange{{Field: "deleted", Before: true, After: false}})
 	return nil
 }
+
+func (ms *MemStorage) PurgeBook(bid string, origin models.AuditEntry) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, deleted := ms.deletedStor[bid]; !deleted {
+		return storerrros.ErrBookNotTrashed
+	}
+	for _, loan := range ms.loanStor {
+		if loan.BID == bid && loan.ReturnedAt == nil {
+			return storerrros.ErrBookOnLoan
+		}
+	}
+	ms.purgeBook(bid)
+	ms.addAudit(origin, models.AuditPurge, models.AuditBook, bid, nil)
+	return nil
+}
+
+func (ms *MemStorage) PurgeBooks(before time.Time, limit int) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	onLoan := make(map[string]bool)
+	for _, loan := range ms.loanStor {
+		if loan.ReturnedAt == nil {
+			onLoan[loan.BID] = true
+		}
+	}
+	purged := 0
+	for bid, del := range ms.deletedStor {
+		if purged == limit {
+			break
+		}
+		if !del.at.Before(before) || onLoan[bid] {
+			continue
+		}
+		ms.purgeBook(bid)
+		purged++
+	}
+	return purged, nil
+}
+
+// purgeBook removes the book with its items, loans and holds like the cascades of the database do.
+func (ms *MemStorage) purgeBook(bid string) {
+	for barcode, item := range ms.itemStor {
+		if item.BID == bid {
+			delete(ms.itemStor, barcode)
+			ms.itemHist.remove(barcode)
+		}
+	}
+	for lid, loan := range ms.loanStor {
+		if loan.BID == bid {
+			delete(ms.loanStor, lid)
+			delete(ms.overdueStor, lid)
+			ms.noticeStor = slices.DeleteFunc(ms.noticeStor, func(n models.NoticeLog) bool { return n.LID == lid })
+		}
+	}
+	for hid, hold := range ms.holdStor {
+		if hold.BID == bid {
+			delete(ms.holdStor, hid)
+		}
+	}
+	delete(ms.bookStor, bid)
+	delete(ms.deletedStor, bid)
+	ms.bookHist.remove(bid)
+	ms.addEvent(models.EventBookPurged, bid, map[string]string{"bid": bid})
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveBookAfterTrash(t *testing.T) {
+	logger.Get(false)
+	stor := New()
+	book := models.Book{Lable: "Dune", Author: "Frank Herbert", Desc: "Desert planet", Age: 12}
+	require.NoError(t, stor.SaveBook(book, models.AuditEntry{}))
+	books, err := stor.GetBooks()
+	require.NoError(t, err)
+	trashed := books[0].BID
+	require.NoError(t, stor.SetDeleteStatus(trashed, models.AuditEntry{Actor: "UID1"}))
+
+	require.NoError(t, stor.SaveBook(book, models.AuditEntry{}))
+	books, err = stor.GetBooks()
+	require.NoError(t, err)
+	require.Len(t, books, 1, "the book is added again, not merged into the trashed one")
+	assert.NotEqual(t, trashed, books[0].BID)
+	assert.Equal(t, 1, books[0].Count)
+	found, err := stor.FindBook(book.Lable, book.Author)
+	require.NoError(t, err)
+	assert.Equal(t, books[0].BID, found.BID)
+
+	trash, err := stor.GetTrash()
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, trashed, trash[0].BID)
+	assert.Equal(t, 1, trash[0].Count)
+}
+
+func TestUpdateItemFillsHold(t *testing.T) {
+	logger.Get(false)
+	stor := New()
+	require.NoError(t, stor.SaveBranch(models.Branch{ID: "BR1", Name: "Main"}))
+	require.NoError(t, stor.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", Desc: "Desert planet"},
+		models.AuditEntry{}))
+	books, err := stor.GetBooks()
+	require.NoError(t, err)
+	items, err := stor.GetItems(books[0].BID)
+	require.NoError(t, err)
+	item := items[0]
+	item.Status = models.ItemLost
+	_, err = stor.UpdateItem(item, models.AuditEntry{})
+	require.NoError(t, err)
+	hold, err := stor.PlaceHold(models.Hold{BID: books[0].BID, UID: "UID1", PickupBranch: "BR1"})
+	require.NoError(t, err)
+	require.Equal(t, models.HoldWaiting, hold.Status)
+
+	item.Status = models.ItemAvailable
+	item, err = stor.UpdateItem(item, models.AuditEntry{})
+	require.NoError(t, err)
+	assert.Equal(t, models.ItemOnHold, item.Status)
+	assert.Equal(t, "BR1", item.CurrentBranch)
+	holds, err := stor.GetHolds("UID1")
+	require.NoError(t, err)
+	require.Len(t, holds, 1)
+	assert.Equal(t, models.HoldReady, holds[0].Status)
+	assert.Equal(t, item.Barcode, holds[0].Barcode)
+}
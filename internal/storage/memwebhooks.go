@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/consts"
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	storerrros "github.com/Dorrrke/g3-bookly/internal/storage/errros"
+)
+
+func (ms *MemStorage) SaveWebhook(hook models.Webhook) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.webhookStor[hook.ID] = hook
+	return nil
+}
+
+func (ms *MemStorage) GetWebhooks() ([]models.Webhook, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	hooks := make([]models.Webhook, 0, len(ms.webhookStor))
+	for _, hook := range ms.webhookStor {
+		hooks = append(hooks, hook)
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].CreatedAt.Before(hooks[j].CreatedAt) })
+	return hooks, nil
+}
+
+func (ms *MemStorage) GetWebhook(id string) (models.Webhook, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	hook, ok := ms.webhookStor[id]
+	if !ok {
+		return models.Webhook{}, storerrros.ErrWebhookNoExist
+	}
+	return hook, nil
+}
+
+func (ms *MemStorage) DeleteWebhook(id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.webhookStor[id]; !ok {
+		return storerrros.ErrWebhookNoExist
+	}
+	delete(ms.webhookStor, id)
+	for did, delivery := range ms.deliveryStor {
+		if delivery.WebhookID == id {
+			delete(ms.deliveryStor, did)
+			delete(ms.attemptStor, did)
+		}
+	}
+	return nil
+}
+
+func (ms *MemStorage) EnqueueDeliveries(event models.Event, payload []byte) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := time.Now()
+	created := 0
+	for _, hook := range ms.webhookStor {
+		if !slices.Contains(hook.Events, event.Type) || ms.hasDelivery(hook.ID, event.ID) {
+			continue
+		}
+		ms.deliverySeq++
+		ms.deliveryStor[ms.deliverySeq] = models.Delivery{
+			ID:            ms.deliverySeq,
+			WebhookID:     hook.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       slices.Clone(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		}
+		created++
+	}
+	return created, nil
+}
+
+// hasDelivery tells if the event was already enqueued for the webhook. The caller must hold the lock.
+func (ms *MemStorage) hasDelivery(webhookID string, eventID int64) bool {
+	for _, delivery := range ms.deliveryStor {
+		if delivery.WebhookID == webhookID && delivery.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+func (ms *MemStorage) DueDeliveries(now time.Time, limit int) ([]models.DeliveryTask, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	var due []models.Delivery
+	for _, delivery := range ms.deliveryStor {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	lease := now.Add(consts.WebhookLease)
+	tasks := make([]models.DeliveryTask, 0, len(due))
+	for _, delivery := range due {
+		delivery.NextAttemptAt = &lease
+		ms.deliveryStor[delivery.ID] = delivery
+		hook := ms.webhookStor[delivery.WebhookID]
+		tasks = append(tasks, models.DeliveryTask{Delivery: delivery, URL: hook.URL, Secret: hook.Secret})
+	}
+	return tasks, nil
+}
+
+func (ms *MemStorage) SaveAttempt(delivery models.Delivery, attempt models.DeliveryAttempt) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if _, ok := ms.deliveryStor[delivery.ID]; !ok {
+		return storerrros.ErrDeliveryNoExist
+	}
+	ms.attemptSeq++
+	attempt.ID = ms.attemptSeq
+	attempt.DeliveryID = delivery.ID
+	ms.attemptStor[delivery.ID] = append(ms.attemptStor[delivery.ID], attempt)
+	ms.deliveryStor[delivery.ID] = delivery
+	return nil
+}
+
+func (ms *MemStorage) GetDeliveries(webhookID string, status models.DeliveryStatus, limit int) ([]models.Delivery, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	deliveries := []models.Delivery{}
+	for _, delivery := range ms.deliveryStor {
+		if delivery.WebhookID == webhookID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (ms *MemStorage) GetDelivery(id int64) (models.Delivery, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	delivery, ok := ms.deliveryStor[id]
+	if !ok {
+		return models.Delivery{}, storerrros.ErrDeliveryNoExist
+	}
+	return delivery, nil
+}
+
+func (ms *MemStorage) GetDeliveryAttempts(id int64) ([]models.DeliveryAttempt, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+	return append([]models.DeliveryAttempt{}, ms.attemptStor[id]...), nil
+}
+
+func (ms *MemStorage) ReplayDelivery(id int64) (models.Delivery, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	delivery, ok := ms.deliveryStor[id]
+	if !ok {
+		return models.Delivery{}, storerrros.ErrDeliveryNoExist
+	}
+	if delivery.Status != models.DeliveryFailed {
+		return models.Delivery{}, storerrros.ErrDeliveryNotFailed
+	}
+	now := time.Now()
+	delivery.Status, delivery.Attempts, delivery.NextAttemptAt = models.DeliveryPending, 0, &now
+	ms.deliveryStor[id] = delivery
+	return delivery, nil
+}
+
+func (ms *MemStorage) MarkOverdueLoans(now time.Time) (int, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	marked := 0
+	for lid, loan := range ms.loanStor {
+		if loan.ReturnedAt != nil || ms.overdueStor[lid] || !loan.DueAt.Before(now) {
+			continue
+		}
+		ms.overdueStor[lid] = true
+		ms.addEvent(models.EventLoanOverdue, lid, loan)
+		marked++
+	}
+	return marked, nil
+}
@@ -0,0 +1,192 @@
+// Package webhook delivers domain events to the URLs admins subscribed, signing every request
+// and retrying failed ones with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/worker"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	EventHeader     = "X-Bookly-Event"
+	DeliveryHeader  = "X-Bookly-Delivery"
+	SignatureHeader = "X-Bookly-Signature"
+	// TimestampHeader carries the Unix time in seconds the request was signed at.
+	TimestampHeader = "X-Bookly-Timestamp"
+
+	signaturePrefix = "sha256="
+)
+
+// Store keeps the webhooks and their deliveries.
+type Store interface {
+	EnqueueDeliveries(event models.Event, payload []byte) (int, error)
+	DueDeliveries(now time.Time, limit int) ([]models.DeliveryTask, error)
+	SaveAttempt(models.Delivery, models.DeliveryAttempt) error
+}
+
+// Sign returns the signature sent in the SignatureHeader: "sha256=" followed by the hex encoded
+// HMAC-SHA256 of the timestamp from the TimestampHeader, a dot and the body with the webhook secret as the key.
+// Signing the timestamp keeps a captured request from being replayed later with a fresh one.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify tells if the signature was made for the timestamp and the body with the secret and the timestamp
+// is within the tolerance from now, a few minutes is usual. Receivers use it to check requests.
+func Verify(secret, timestamp string, body []byte, signature string, tolerance time.Duration) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := time.Since(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Sink is the events.Sink turning published events into deliveries for the subscribed webhooks.
+type Sink struct {
+	store Store
+}
+
+func NewSink(store Store) *Sink {
+	return &Sink{store: store}
+}
+
+func (s *Sink) Publish(_ context.Context, events []models.Event) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err = s.store.EnqueueDeliveries(event, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dispatcher sends the due deliveries. A delivery succeeds on a 2xx response, otherwise it is
+// retried after a delay starting at Backoff.Initial and doubling up to Backoff.Max.
+// After Backoff.Attempts attempts it fails and waits for a replay.
+type Dispatcher struct {
+	store   Store
+	client  *http.Client
+	backoff worker.Backoff
+	batch   int
+	workers int
+}
+
+func NewDispatcher(store Store, client *http.Client, backoff worker.Backoff, batch, workers int) *Dispatcher {
+	if backoff.Attempts < 1 {
+		backoff.Attempts = 1
+	}
+	return &Dispatcher{store: store, client: client, backoff: backoff, batch: batch, workers: max(workers, 1)}
+}
+
+// Run sends the deliveries due now batch by batch until none is left.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	for ctx.Err() == nil {
+		tasks, err := d.store.DueDeliveries(time.Now(), d.batch)
+		if err != nil {
+			return err
+		}
+		group, gCtx := errgroup.WithContext(ctx)
+		group.SetLimit(d.workers)
+		for _, task := range tasks {
+			group.Go(func() error {
+				return d.deliver(gCtx, task)
+			})
+		}
+		if err = group.Wait(); err != nil {
+			return err
+		}
+		if len(tasks) < d.batch {
+			return nil
+		}
+	}
+	return ctx.Err()
+}
+
+// deliver makes one attempt of the delivery and records it. Only a failure to record is returned,
+// a failed request is a normal outcome handled by the retries.
+func (d *Dispatcher) deliver(ctx context.Context, task models.DeliveryTask) error {
+	log := logger.Get()
+	delivery := task.Delivery
+	attempt := models.DeliveryAttempt{DeliveryID: delivery.ID, At: time.Now()}
+	code, err := d.send(ctx, task)
+	if ctx.Err() != nil {
+		// Shutting down, the delivery is sent again once its lease runs out.
+		return ctx.Err()
+	}
+	attempt.StatusCode, attempt.Duration = code, time.Since(attempt.At)
+	delivery.Attempts++
+	switch {
+	case err == nil:
+		delivery.Status, delivery.LastError = models.DeliverySucceeded, ""
+		delivery.NextAttemptAt, delivery.DeliveredAt = nil, &attempt.At
+	case delivery.Attempts >= d.backoff.Attempts:
+		attempt.Error, delivery.LastError = err.Error(), err.Error()
+		delivery.Status, delivery.NextAttemptAt = models.DeliveryFailed, nil
+	default:
+		attempt.Error, delivery.LastError = err.Error(), err.Error()
+		next := time.Now().Add(d.delay(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	log.Debug().Int64("delivery", delivery.ID).Str("status", string(delivery.Status)).
+		Int("attempts", delivery.Attempts).Err(err).Msg("webhook delivery attempted")
+	return d.store.SaveAttempt(delivery, attempt)
+}
+
+// delay is the wait before the next attempt after the given number of failed ones.
+func (d *Dispatcher) delay(failed int) time.Duration {
+	delay := d.backoff.Initial
+	for i := 1; i < failed && delay < d.backoff.Max; i++ {
+		delay *= 2 //nolint:mnd //exponential backoff
+	}
+	return min(delay, d.backoff.Max)
+}
+
+func (d *Dispatcher) send(ctx context.Context, task models.DeliveryTask) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, task.URL, bytes.NewReader(task.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(task.EventType))
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(task.ID, 10))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(task.Secret, timestamp, task.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16)) //nolint:mnd //drain for reuse
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
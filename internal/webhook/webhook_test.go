@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/domain/models"
+	"github.com/Dorrrke/g3-bookly/internal/events"
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/storage"
+	"github.com/Dorrrke/g3-bookly/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef"
+
+// receiver is the test endpoint. It answers with the queued status codes, then with 200.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func setup(t *testing.T, statuses ...int) (*storage.MemStorage, *receiver) {
+	t.Helper()
+	logger.Get(false)
+	recv := &receiver{statuses: statuses}
+	srv := httptest.NewServer(recv)
+	t.Cleanup(srv.Close)
+	stor := storage.New()
+	require.NoError(t, stor.SaveWebhook(models.Webhook{
+		ID:        "WH1",
+		URL:       srv.URL,
+		Secret:    testSecret,
+		Events:    []models.EventType{models.EventBookAdded},
+		CreatedAt: time.Now(),
+	}))
+	require.NoError(t, stor.SaveBook(models.Book{Lable: "Dune", Author: "Frank Herbert", Desc: "Desert planet", Age: 12},
+		models.AuditEntry{}))
+	relay := events.NewRelay(stor, 10, NewSink(stor))
+	require.NoError(t, relay.Run(context.Background()))
+	return stor, recv
+}
+
+func dispatcher(stor *storage.MemStorage, attempts int) *Dispatcher {
+	return NewDispatcher(stor, http.DefaultClient,
+		worker.Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond, Attempts: attempts}, 10, 2)
+}
+
+// runUntil runs the dispatcher until the delivery leaves the pending status.
+func runUntil(t *testing.T, d *Dispatcher, stor *storage.MemStorage, id int64) models.Delivery {
+	t.Helper()
+	for range 50 {
+		require.NoError(t, d.Run(context.Background()))
+		delivery, err := stor.GetDelivery(id)
+		require.NoError(t, err)
+		if delivery.Status != models.DeliveryPending {
+			return delivery
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("delivery is still pending")
+	return models.Delivery{}
+}
+
+func TestDeliver(t *testing.T) {
+	stor, recv := setup(t)
+	deliveries, err := stor.GetDeliveries("WH1", "", 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.EventBookAdded, deliveries[0].EventType)
+
+	delivery := runUntil(t, dispatcher(stor, 3), stor, deliveries[0].ID)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.NotNil(t, delivery.DeliveredAt)
+
+	require.Len(t, recv.requests, 1)
+	req, body := recv.requests[0], recv.bodies[0]
+	assert.Equal(t, "BookAdded", req.Header.Get(EventHeader))
+	assert.Equal(t, strconv.FormatInt(delivery.ID, 10), req.Header.Get(DeliveryHeader))
+	timestamp, signature := req.Header.Get(TimestampHeader), req.Header.Get(SignatureHeader)
+	assert.True(t, Verify(testSecret, timestamp, body, signature, time.Minute))
+	assert.False(t, Verify("another secret", timestamp, body, signature, time.Minute))
+	assert.False(t, Verify(testSecret, timestamp+"0", body, signature, time.Minute), "timestamp is signed")
+	var event models.Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, models.EventBookAdded, event.Type)
+}
+
+func TestVerifyTolerance(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	for name, tc := range map[string]struct {
+		at   time.Time
+		want bool
+	}{
+		"fresh":  {at: time.Now().Add(-time.Minute), want: true},
+		"stale":  {at: time.Now().Add(-10 * time.Minute), want: false},
+		"future": {at: time.Now().Add(10 * time.Minute), want: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			timestamp := strconv.FormatInt(tc.at.Unix(), 10)
+			signature := Sign(testSecret, timestamp, body)
+			assert.Equal(t, tc.want, Verify(testSecret, timestamp, body, signature, 5*time.Minute))
+		})
+	}
+	assert.False(t, Verify(testSecret, "yesterday", body, Sign(testSecret, "yesterday", body), 5*time.Minute))
+}
+
+func TestRetry(t *testing.T) {
+	stor, recv := setup(t, http.StatusInternalServerError, http.StatusBadGateway)
+	deliveries, err := stor.GetDeliveries("WH1", "", 10)
+	require.NoError(t, err)
+
+	delivery := runUntil(t, dispatcher(stor, 3), stor, deliveries[0].ID)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.Equal(t, 3, delivery.Attempts)
+	assert.Len(t, recv.requests, 3)
+
+	attempts, err := stor.GetDeliveryAttempts(delivery.ID)
+	require.NoError(t, err)
+	require.Len(t, attempts, 3)
+	assert.Equal(t, http.StatusInternalServerError, attempts[0].StatusCode)
+	assert.Equal(t, "unexpected status 500", attempts[0].Error)
+	assert.Equal(t, http.StatusBadGateway, attempts[1].StatusCode)
+	assert.Equal(t, http.StatusOK, attempts[2].StatusCode)
+	assert.Empty(t, attempts[2].Error)
+}
+
+func TestFailAndReplay(t *testing.T) {
+	stor, recv := setup(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	deliveries, err := stor.GetDeliveries("WH1", "", 10)
+	require.NoError(t, err)
+	d := dispatcher(stor, 2)
+
+	delivery := runUntil(t, d, stor, deliveries[0].ID)
+	assert.Equal(t, models.DeliveryFailed, delivery.Status)
+	assert.Equal(t, "unexpected status 500", delivery.LastError)
+	failed, err := stor.GetDeliveries("WH1", models.DeliveryFailed, 10)
+	require.NoError(t, err)
+	assert.Len(t, failed, 1)
+
+	_, err = stor.ReplayDelivery(delivery.ID)
+	require.NoError(t, err)
+	delivery = runUntil(t, d, stor, delivery.ID)
+	assert.Equal(t, models.DeliverySucceeded, delivery.Status)
+	assert.Len(t, recv.requests, 3)
+	attempts, err := stor.GetDeliveryAttempts(delivery.ID)
+	require.NoError(t, err)
+	assert.Len(t, attempts, 3)
+}
+
+func TestNotSubscribed(t *testing.T) {
+	stor, recv := setup(t)
+	require.NoError(t, stor.SetDeleteStatus(mustFirstBook(t, stor), models.AuditEntry{Actor: "UID"}))
+	require.NoError(t, events.NewRelay(stor, 10, NewSink(stor)).Run(context.Background()))
+	deliveries, err := stor.GetDeliveries("WH1", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1, "BookDeleted is not subscribed")
+	assert.Empty(t, recv.requests)
+}
+
+func mustFirstBook(t *testing.T, stor *storage.MemStorage) string {
+	t.Helper()
+	books, err := stor.GetBooks()
+	require.NoError(t, err)
+	return books[0].BID
+}
+
+func TestDelay(t *testing.T) {
+	d := NewDispatcher(nil, nil, worker.Backoff{Initial: time.Second, Max: 5 * time.Second, Attempts: 10}, 1, 1)
+	assert.Equal(t, time.Second, d.delay(1))
+	assert.Equal(t, 2*time.Second, d.delay(2))
+	assert.Equal(t, 4*time.Second, d.delay(3))
+	assert.Equal(t, 5*time.Second, d.delay(4))
+	assert.Equal(t, 5*time.Second, d.delay(9))
+}
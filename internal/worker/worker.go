@@ -0,0 +1,239 @@
+// Package worker runs background jobs on a schedule or on demand, retrying failed runs with backoff.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/Dorrrke/g3-bookly/internal/tracing"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrUnknownJob = errors.New("unknown job")
+
+type Job struct {
+	Name string
+	// Interval between scheduled runs. Jobs with zero interval run only on demand.
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Backoff controls retries of a failed run: the delay starts at Initial and doubles up to Max.
+// After Attempts failed attempts the run is given up until the next scheduled or requested one.
+type Backoff struct {
+	Initial  time.Duration
+	Max      time.Duration
+	Attempts int
+}
+
+// Stats describe the runs of a job since start. Failures count failed attempts, retries included.
+type Stats struct {
+	Name         string        `json:"name"`
+	Interval     time.Duration `json:"interval"`
+	Running      bool          `json:"running"`
+	Runs         int64         `json:"runs"`
+	Failures     int64         `json:"failures"`
+	LastRun      *time.Time    `json:"last_run,omitempty"`
+	LastSuccess  *time.Time    `json:"last_success,omitempty"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	NextRun      *time.Time    `json:"next_run,omitempty"`
+}
+
+// Observer is told about every attempt of every job, e.g. to export metrics.
+type Observer func(job string, duration time.Duration, err error)
+
+type jobState struct {
+	job     Job
+	trigger chan struct{}
+	stats   Stats
+}
+
+type Scheduler struct {
+	backoff  Backoff
+	observer Observer
+
+	mu      sync.Mutex
+	jobs    map[string]*jobState
+	running bool
+}
+
+func New(backoff Backoff) *Scheduler {
+	if backoff.Attempts < 1 {
+		backoff.Attempts = 1
+	}
+	return &Scheduler{backoff: backoff, jobs: make(map[string]*jobState)}
+}
+
+// Observe sets the observer. It must be called before Run.
+func (s *Scheduler) Observe(observer Observer) {
+	s.observer = observer
+}
+
+// Add registers the job. Jobs must be added before Run.
+func (s *Scheduler) Add(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[job.Name] = &jobState{
+		job:     job,
+		trigger: make(chan struct{}, 1),
+		stats:   Stats{Name: job.Name, Interval: job.Interval},
+	}
+}
+
+// Run starts all jobs and blocks until the context is done and running jobs have returned.
+func (s *Scheduler) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	s.mu.Lock()
+	s.running = true
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+	for _, st := range s.jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, st)
+		}()
+	}
+	s.mu.Unlock()
+	wg.Wait()
+}
+
+// Trigger asks for a run of the job as soon as possible. Requests made while a run is pending are merged.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	st, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrUnknownJob
+	}
+	select {
+	case st.trigger <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Running tells whether Run is going.
+func (s *Scheduler) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+func (s *Scheduler) Stats() []Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make([]Stats, 0, len(s.jobs))
+	for _, st := range s.jobs {
+		stats = append(stats, st.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+func (s *Scheduler) loop(ctx context.Context, st *jobState) {
+	log := logger.Get()
+	var tick <-chan time.Time
+	if st.job.Interval > 0 {
+		ticker := time.NewTicker(st.job.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+		s.setNextRun(st, time.Now().Add(st.job.Interval))
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			log.Debug().Str("job", st.job.Name).Msg("job stopped")
+			return
+		case <-tick:
+		case <-st.trigger:
+		}
+		s.runWithRetry(ctx, st)
+		if st.job.Interval > 0 {
+			s.setNextRun(st, time.Now().Add(st.job.Interval))
+		}
+	}
+}
+
+func (s *Scheduler) runWithRetry(ctx context.Context, st *jobState) {
+	log := logger.Get()
+	delay := s.backoff.Initial
+	for attempt := 1; ; attempt++ {
+		err := s.attempt(ctx, st)
+		if err == nil {
+			return
+		}
+		log.Error().Err(err).Str("job", st.job.Name).Int("attempt", attempt).Msg("job failed")
+		if attempt >= s.backoff.Attempts || ctx.Err() != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, s.backoff.Max) //nolint:mnd //exponential backoff
+	}
+}
+
+func (s *Scheduler) attempt(ctx context.Context, st *jobState) error {
+	start := time.Now()
+	s.mu.Lock()
+	st.stats.Running = true
+	st.stats.Runs++
+	st.stats.LastRun = &start
+	s.mu.Unlock()
+
+	// Every attempt is a trace of its own, the storage calls of the job are its spans.
+	ctx, span := tracing.Tracer().Start(ctx, "job "+st.job.Name, trace.WithNewRoot())
+	err := safeRun(ctx, st.job.Run)
+	duration := time.Since(start)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	s.mu.Lock()
+	st.stats.Running = false
+	st.stats.LastDuration = duration
+	if err != nil {
+		st.stats.Failures++
+		st.stats.LastError = err.Error()
+	} else {
+		end := start.Add(duration)
+		st.stats.LastSuccess = &end
+		st.stats.LastError = ""
+	}
+	s.mu.Unlock()
+	if s.observer != nil {
+		s.observer(st.job.Name, duration, err)
+	}
+	return err
+}
+
+func (s *Scheduler) setNextRun(st *jobState, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.stats.NextRun = &next
+}
+
+// safeRun turns a panic of the job into an error, so a broken job can not take the process down.
+func safeRun(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return run(ctx)
+}
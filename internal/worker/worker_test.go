@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dorrrke/g3-bookly/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func start(t *testing.T, s *Scheduler) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestTriggerRetriesWithBackoff(t *testing.T) {
+	logger.Get(false)
+	var calls atomic.Int32
+	var observed atomic.Int32
+	s := New(Backoff{Initial: time.Millisecond, Max: 2 * time.Millisecond, Attempts: 3})
+	s.Observe(func(string, time.Duration, error) { observed.Add(1) })
+	s.Add(Job{Name: "flaky", Run: func(context.Context) error {
+		if calls.Add(1) < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}})
+	assert.False(t, s.Running())
+	start(t, s)
+	require.Eventually(t, s.Running, time.Second, time.Millisecond)
+
+	require.NoError(t, s.Trigger("flaky"))
+	require.Eventually(t, func() bool { return s.Stats()[0].LastSuccess != nil }, time.Second, time.Millisecond)
+	stats := s.Stats()[0]
+	assert.Equal(t, int64(3), stats.Runs)
+	assert.Equal(t, int64(2), stats.Failures)
+	assert.Empty(t, stats.LastError)
+	assert.Equal(t, int32(3), observed.Load())
+	assert.ErrorIs(t, s.Trigger("missing"), ErrUnknownJob)
+}
+
+func TestGivesUpAndSurvivesPanics(t *testing.T) {
+	logger.Get(false)
+	s := New(Backoff{Initial: time.Millisecond, Max: time.Millisecond, Attempts: 2})
+	s.Add(Job{Name: "broken", Run: func(context.Context) error { panic("boom") }})
+	start(t, s)
+
+	require.NoError(t, s.Trigger("broken"))
+	require.Eventually(t, func() bool {
+		st := s.Stats()[0]
+		return st.Failures == 2 && !st.Running
+	}, time.Second, time.Millisecond)
+	stats := s.Stats()[0]
+	assert.Equal(t, "job panicked: boom", stats.LastError)
+	assert.Nil(t, stats.LastSuccess)
+}
+
+func TestScheduledRuns(t *testing.T) {
+	logger.Get(false)
+	var calls atomic.Int32
+	s := New(Backoff{Attempts: 1})
+	s.Add(Job{Name: "tick", Interval: 5 * time.Millisecond, Run: func(context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+	start(t, s)
+	require.Eventually(t, func() bool { return calls.Load() >= 2 }, time.Second, time.Millisecond)
+	assert.NotNil(t, s.Stats()[0].NextRun)
+}
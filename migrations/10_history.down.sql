@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS items_versioning ON items;
+DROP TRIGGER IF EXISTS books_versioning ON books;
+DROP FUNCTION IF EXISTS versioning();
+DROP TABLE IF EXISTS items_history;
+DROP TABLE IF EXISTS books_history;
+ALTER TABLE items DROP COLUMN IF EXISTS sys_period;
+ALTER TABLE books DROP COLUMN IF EXISTS sys_period;
@@ -0,0 +1,37 @@
+-- System-versioned books and items: every row carries the period it is valid in, the versioning
+-- trigger copies the replaced or deleted row into the history table with its period closed.
+-- The history of rows existing before this migration starts with it.
+-- Columns added to books or items later must be added to their history tables as well.
+ALTER TABLE books ADD COLUMN IF NOT EXISTS sys_period tstzrange NOT NULL DEFAULT tstzrange(now(), null);
+ALTER TABLE items ADD COLUMN IF NOT EXISTS sys_period tstzrange NOT NULL DEFAULT tstzrange(now(), null);
+
+CREATE TABLE IF NOT EXISTS books_history (LIKE books);
+CREATE INDEX IF NOT EXISTS books_history_bid_idx ON books_history (bid);
+CREATE INDEX IF NOT EXISTS books_history_period_idx ON books_history USING gist (sys_period);
+
+CREATE TABLE IF NOT EXISTS items_history (LIKE items);
+CREATE INDEX IF NOT EXISTS items_history_bid_idx ON items_history (bid);
+CREATE INDEX IF NOT EXISTS items_history_period_idx ON items_history USING gist (sys_period);
+
+CREATE OR REPLACE FUNCTION versioning() RETURNS trigger AS $$
+BEGIN
+    -- a row changed again in the transaction that made it was never visible, it has no history
+    IF lower(OLD.sys_period) < now() THEN
+        OLD.sys_period := tstzrange(lower(OLD.sys_period), now());
+        EXECUTE format('INSERT INTO %I SELECT ($1).*', TG_ARGV[0]) USING OLD;
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        RETURN OLD;
+    END IF;
+    NEW.sys_period := tstzrange(now(), null);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS books_versioning ON books;
+CREATE TRIGGER books_versioning BEFORE UPDATE OR DELETE ON books
+    FOR EACH ROW EXECUTE FUNCTION versioning('books_history');
+
+DROP TRIGGER IF EXISTS items_versioning ON items;
+CREATE TRIGGER items_versioning BEFORE UPDATE OR DELETE ON items
+    FOR EACH ROW EXECUTE FUNCTION versioning('items_history');
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS outbox(
+    id bigserial PRIMARY KEY,
+    type TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    data jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    published_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (id) WHERE published_at IS NULL;
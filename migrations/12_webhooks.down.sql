@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS delivery_attempts;
+DROP TABLE IF EXISTS deliveries;
+DROP TABLE IF EXISTS webhooks;
+DROP INDEX IF EXISTS loans_due_idx;
+ALTER TABLE loans DROP COLUMN IF EXISTS overdue_at;
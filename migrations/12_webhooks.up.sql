@@ -0,0 +1,34 @@
+ALTER TABLE loans ADD COLUMN IF NOT EXISTS overdue_at timestamptz;
+CREATE INDEX IF NOT EXISTS loans_due_idx ON loans (due_at) WHERE returned_at IS NULL AND overdue_at IS NULL;
+CREATE TABLE IF NOT EXISTS webhooks(
+    id varchar(36) NOT NULL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT[] NOT NULL,
+    created_by varchar(36) REFERENCES users (uid) ON DELETE SET NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS deliveries(
+    id bigserial PRIMARY KEY,
+    webhook_id varchar(36) NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event_id bigint NOT NULL,
+    event_type TEXT NOT NULL,
+    payload jsonb NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    delivered_at timestamptz,
+    UNIQUE (webhook_id, event_id)
+);
+CREATE INDEX IF NOT EXISTS deliveries_due_idx ON deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE TABLE IF NOT EXISTS delivery_attempts(
+    id bigserial PRIMARY KEY,
+    delivery_id bigint NOT NULL REFERENCES deliveries (id) ON DELETE CASCADE,
+    at timestamptz NOT NULL,
+    status_code int NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    duration bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS delivery_attempts_delivery_idx ON delivery_attempts (delivery_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS desk_alerts(
+    id bigserial PRIMARY KEY,
+    event_id bigint NOT NULL UNIQUE,
+    branch TEXT NOT NULL DEFAULT '',
+    kind TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    data jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    acked_by varchar(36) REFERENCES users (uid) ON DELETE SET NULL,
+    acked_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS desk_alerts_open_idx ON desk_alerts (branch, id) WHERE acked_at IS NULL;
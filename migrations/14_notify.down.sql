@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS notifications;
+DROP TABLE IF EXISTS notice_log;
+DROP TABLE IF EXISTS notify_prefs;
+DROP INDEX IF EXISTS loans_open_due_idx;
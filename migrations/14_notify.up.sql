@@ -0,0 +1,32 @@
+CREATE INDEX IF NOT EXISTS loans_open_due_idx ON loans (due_at) WHERE returned_at IS NULL;
+CREATE TABLE IF NOT EXISTS notify_prefs(
+    uid varchar(36) NOT NULL PRIMARY KEY REFERENCES users (uid) ON DELETE CASCADE,
+    channel TEXT NOT NULL,
+    locale TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS notice_log(
+    id bigserial PRIMARY KEY,
+    uid varchar(36) NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+    lid varchar(36) NOT NULL REFERENCES loans (lid) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    stage int NOT NULL,
+    channel TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 1,
+    subject TEXT NOT NULL DEFAULT '',
+    error TEXT NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    sent_at timestamptz,
+    UNIQUE (lid, kind, stage)
+);
+CREATE INDEX IF NOT EXISTS notice_log_uid_idx ON notice_log (uid, id);
+CREATE TABLE IF NOT EXISTS notifications(
+    id bigserial PRIMARY KEY,
+    uid varchar(36) NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    title TEXT NOT NULL,
+    body TEXT NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    read_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS notifications_uid_idx ON notifications (uid, id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS notifications_read_idx;
+DROP INDEX IF EXISTS notifications_unread_idx;
+DROP INDEX IF EXISTS notifications_source_idx;
+ALTER TABLE notifications DROP COLUMN IF EXISTS source;
@@ -0,0 +1,4 @@
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS source TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS notifications_source_idx ON notifications (source);
+CREATE INDEX IF NOT EXISTS notifications_unread_idx ON notifications (uid) WHERE read_at IS NULL;
+CREATE INDEX IF NOT EXISTS notifications_read_idx ON notifications (read_at) WHERE read_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS rate_limits_updated_idx;
+DROP TABLE IF EXISTS rate_limits;
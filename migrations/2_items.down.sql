@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS loans;
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS count integer NOT NULL DEFAULT 0;
+UPDATE books SET count = (SELECT COUNT(*) FROM items WHERE items.bid = books.bid AND items.status <> 'lost');
+
+DROP TABLE IF EXISTS items;
+DROP SEQUENCE IF EXISTS item_barcode_seq;
@@ -0,0 +1,28 @@
+CREATE SEQUENCE IF NOT EXISTS item_barcode_seq;
+
+CREATE TABLE IF NOT EXISTS items(
+    barcode TEXT NOT NULL PRIMARY KEY,
+    bid varchar(36) NOT NULL REFERENCES books (bid) ON DELETE CASCADE,
+    acquired_at timestamptz NOT NULL DEFAULT now(),
+    condition TEXT NOT NULL DEFAULT 'good',
+    location TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'available'
+);
+CREATE INDEX IF NOT EXISTS items_bid_idx ON items (bid);
+
+INSERT INTO items (barcode, bid)
+    SELECT 'BK' || lpad(nextval('item_barcode_seq')::text, 8, '0'), books.bid
+    FROM books CROSS JOIN LATERAL generate_series(1, books.count);
+
+ALTER TABLE books DROP COLUMN IF EXISTS count;
+
+CREATE TABLE IF NOT EXISTS loans(
+    lid varchar(36) NOT NULL PRIMARY KEY,
+    barcode TEXT NOT NULL REFERENCES items (barcode) ON DELETE CASCADE,
+    uid varchar(36) NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+    issued_at timestamptz NOT NULL DEFAULT now(),
+    due_at timestamptz NOT NULL,
+    returned_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS loans_active_idx ON loans (barcode) WHERE returned_at IS NULL;
+CREATE INDEX IF NOT EXISTS loans_uid_idx ON loans (uid);
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS transfers;
+DROP TABLE IF EXISTS holds;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+DROP INDEX IF EXISTS items_current_branch_idx;
+ALTER TABLE items DROP COLUMN IF EXISTS current_branch;
+ALTER TABLE items DROP COLUMN IF EXISTS home_branch;
+
+DROP TABLE IF EXISTS branches;
@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS branches(
+    id varchar(36) NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT ''
+);
+
+ALTER TABLE items ADD COLUMN IF NOT EXISTS home_branch varchar(36) REFERENCES branches (id);
+ALTER TABLE items ADD COLUMN IF NOT EXISTS current_branch varchar(36) REFERENCES branches (id);
+CREATE INDEX IF NOT EXISTS items_current_branch_idx ON items (current_branch, bid);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member';
+
+CREATE TABLE IF NOT EXISTS holds(
+    hid varchar(36) NOT NULL PRIMARY KEY,
+    bid varchar(36) NOT NULL REFERENCES books (bid) ON DELETE CASCADE,
+    uid varchar(36) NOT NULL REFERENCES users (uid) ON DELETE CASCADE,
+    pickup_branch varchar(36) NOT NULL REFERENCES branches (id),
+    barcode TEXT REFERENCES items (barcode) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'waiting',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    ready_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS holds_queue_idx ON holds (bid, status, created_at);
+CREATE INDEX IF NOT EXISTS holds_uid_idx ON holds (uid);
+
+CREATE TABLE IF NOT EXISTS transfers(
+    tid varchar(36) NOT NULL PRIMARY KEY,
+    barcode TEXT NOT NULL REFERENCES items (barcode) ON DELETE CASCADE,
+    from_branch varchar(36) REFERENCES branches (id),
+    to_branch varchar(36) NOT NULL REFERENCES branches (id),
+    hid varchar(36) REFERENCES holds (hid) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'requested',
+    requested_by varchar(36),
+    requested_at timestamptz NOT NULL DEFAULT now(),
+    shipped_at timestamptz,
+    received_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS transfers_status_idx ON transfers (status, requested_at);
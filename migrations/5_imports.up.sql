@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS imports(
+    id varchar(36) NOT NULL PRIMARY KEY,
+    format TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    processed integer NOT NULL DEFAULT 0,
+    imported integer NOT NULL DEFAULT 0,
+    failed integer NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_by varchar(36) REFERENCES users (uid) ON DELETE SET NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    finished_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS import_errors(
+    import_id varchar(36) NOT NULL REFERENCES imports (id) ON DELETE CASCADE,
+    row integer NOT NULL,
+    error TEXT NOT NULL,
+    PRIMARY KEY (import_id, row)
+);
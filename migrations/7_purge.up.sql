@@ -0,0 +1,3 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+UPDATE books SET deleted_at = now() WHERE deleted = true AND deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS books_deleted_at_idx ON books (deleted_at) WHERE deleted = true;
@@ -0,0 +1 @@
+ALTER TABLE books ADD COLUMN IF NOT EXISTS deleted_by varchar(36) REFERENCES users (uid) ON DELETE SET NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS audit;
+DROP FUNCTION IF EXISTS audit_append_only();
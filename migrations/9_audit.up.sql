@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS audit(
+    id bigserial PRIMARY KEY,
+    entity TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    action TEXT NOT NULL,
+    actor TEXT NOT NULL DEFAULT '',
+    request_id TEXT NOT NULL DEFAULT '',
+    at timestamptz NOT NULL DEFAULT now(),
+    changes jsonb NOT NULL DEFAULT '[]'
+);
+CREATE INDEX IF NOT EXISTS audit_entity_idx ON audit (entity, entity_id, at);
+CREATE INDEX IF NOT EXISTS audit_at_idx ON audit (at);
+
+CREATE OR REPLACE FUNCTION audit_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit entries can not be changed';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_no_update ON audit;
+CREATE TRIGGER audit_no_update BEFORE UPDATE ON audit
+    FOR EACH ROW EXECUTE FUNCTION audit_append_only();